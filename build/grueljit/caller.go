@@ -14,27 +14,53 @@ import (
 
 const no_local_marker = "FIXME: EDIT THIS TO ADD IN: NO_LOCAL_POINTERS"
 const tls_marker = "FIXME: EDIT THIS TO ADD IN: MOVQ (TLS), DI"
+const call_marker = "FIXME: EDIT THIS TO ADD IN: CALL AX"
 
 func main() {
-	alignment := 16
-	TEXT("CallJit", NOSPLIT|NOFRAME, "func(f uint64, args []uint64, stack uint64) uint64")
-	Doc(
+	trampoline("CallJit", "jit", "func(f uint64, args []uint64, stack uint64) uint64", []string{
 		"Calls a jit_function_t without locking an OS thread.",
 		"- f: a jit_function value",
 		"- args: a pointer to an []uint64 argument array, probably from unsafe.Pointer(&args[0])",
 		"- stack: stack size needed",
-	)
+	}, func() {
+		Comment("System V calling conventions")
+		Load(Param("f"), reg.RDI)
+		Load(Param("args").Base(), reg.RSI)
+	}, func() {
+		Comment("Call relative")
+		CALL(operand.LabelRef("call_jit_function+0x00(SB)"))
+	})
+	trampoline("CallNative", "native", "func(entry uint64, args []uint64, stack uint64) uint64", []string{
+		"Calls machine code taking a pointer to the arguments, like CallJit but without LibJIT.",
+		"- entry: the address of the code",
+		"- args: a pointer to an []uint64 argument array, probably from unsafe.Pointer(&args[0])",
+		"- stack: stack size needed",
+	}, func() {
+		Comment("System V calling conventions")
+		Load(Param("entry"), reg.RAX)
+		Load(Param("args").Base(), reg.RDI)
+	}, func() {
+		Comment("Call absolute")
+		Comment(call_marker)
+	})
+
+	Generate()
+	replaceMarkers()
+}
+
+// Generates a function calling into code with System V calling conventions,
+// growing the goroutine stack beforehand if needed
+func trampoline(name, prefix, signature string, doc []string, load, call func()) {
+	alignment := 16
+	TEXT(name, NOSPLIT|NOFRAME, signature)
+	Doc(doc...)
 	Comment(no_local_marker)
 	// Reserves space for stack alignment
 	AllocLocal(alignment)
 
-	// System V
-	arg1 := reg.RDI
-	arg2 := reg.RSI
-
-	Label("jit_entry")
-	g := arg1
-	top := arg2
+	Label(prefix + "_entry")
+	g := reg.RDI
+	top := reg.RSI
 	Comment("top(SI) = SP - max_stack_size")
 	MOVQ(reg.RSP, top)
 	Load(Param("stack"), g)
@@ -42,19 +68,16 @@ func main() {
 	Comment("if top <= g(DI).stackguard1 { goto stack_grow }")
 	Comment(tls_marker)
 	CMPQ(top, operand.Mem{Base: g, Disp: 16})
-	JBE(operand.LabelRef("jit_stack_grow"))
+	JBE(operand.LabelRef(prefix + "_stack_grow"))
 
-	Comment("System V calling conventions")
-	Load(Param("f"), arg1)
-	Load(Param("args").Base(), arg2)
+	load()
 
 	Comment("Align the stack")
 	MOVQ(reg.RSP, reg.RBX)
 	ORQ(operand.I8(alignment-1), reg.RSP)
 	INCQ(reg.RSP)
 
-	Comment("Call relative")
-	CALL(operand.LabelRef("call_jit_function+0x00(SB)"))
+	call()
 
 	Comment("Restore stack")
 	MOVQ(reg.RBX, reg.RSP)
@@ -63,12 +86,9 @@ func main() {
 	Store(reg.RAX, ReturnIndex(0))
 	RET()
 
-	Label("jit_stack_grow")
+	Label(prefix + "_stack_grow")
 	CALL(operand.LabelRef("runtime·morestack_noctxt<>+0x00(SB)"))
-	JMP(operand.LabelRef("jit_entry"))
-
-	Generate()
-	replaceMarkers()
+	JMP(operand.LabelRef(prefix + "_entry"))
 }
 
 const file = "caller.s"
@@ -90,6 +110,8 @@ func replaceMarkers() {
 			output.WriteString("\tNO_LOCAL_POINTERS")
 		case strings.Contains(line, tls_marker):
 			output.WriteString("\tMOVQ (TLS), DI")
+		case strings.Contains(line, call_marker):
+			output.WriteString("\tCALL AX")
 		default:
 			output.WriteString(line)
 		}
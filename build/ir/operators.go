@@ -68,15 +68,8 @@ func populateC(cFile string) {
 }
 
 func validate() {
-	opcodes := make(map[int]string)
-	for name, ops := range ir.Operators {
-		for _, op := range ops {
-			other, ok := opcodes[op.Opcode]
-			if ok {
-				log.Fatalf("conflicting opcode for %s and %s", name, other)
-			}
-			opcodes[op.Opcode] = name
-		}
+	if err := ir.ValidateOperators(ir.Operators); err != nil {
+		log.Fatalln(err)
 	}
 }
 
@@ -100,6 +93,9 @@ func (p Pairs) Less(i, j int) bool {
 var type_map = map[string]string{
 	"i": "long",
 	"s": "void_ptr",
+	"f": "float64",
+	"u": "ulong",
+	"F": "float32",
 }
 
 func writeC(b *bytes.Buffer, indent string) {
@@ -110,6 +106,29 @@ func writeC(b *bytes.Buffer, indent string) {
 			line.WriteString(fmt.Sprintf("%s// `%s`(%d)\n", indent, name, op.Argc))
 			line.WriteString(indent)
 			switch {
+			case op.JitFunction[0] == '$' || op.JitFunction[0] == '&':
+				// Scratch buffers are passed as an extra first argument.
+				argc := op.Argc
+				if op.JitFunction[0] == '&' {
+					argc++
+				}
+				fields := strings.Split(op.JitFunction[1:], ":")
+				if len(fields) != argc+2 {
+					log.Fatalf("invalid function %s\n", op.JitFunction)
+				}
+				types := make([]string, 0, argc+1)
+				for _, v := range fields[:argc+1] {
+					typeName, ok := type_map[v]
+					if !ok {
+						log.Fatalf("invalid type %s in %s\n", v, op.JitFunction)
+					}
+					types = append(types, typeName)
+				}
+				line.WriteString(fmt.Sprintf("NATIVE%d_OP(0x%02x, %s, %s);",
+					argc, op.Opcode, fields[argc+1], strings.Join(types, ", ")))
+			case op.Argc == 1 && op.JitFunction[0] == '%':
+				line.WriteString(fmt.Sprintf("CONVERT_OP(0x%02x, %s);",
+					op.Opcode, op.JitFunction[1:]))
 			case op.Argc == 1 && !unicode.IsPunct(rune(op.JitFunction[0])):
 				line.WriteString(fmt.Sprintf("UNARY_OP (0x%02x, %s);",
 					op.Opcode, op.JitFunction))
@@ -119,6 +138,9 @@ func writeC(b *bytes.Buffer, indent string) {
 			case op.Argc == 2 && op.JitFunction[0] == '!':
 				line.WriteString(fmt.Sprintf("LOGIC_OP (0x%02x, %s);",
 					op.Opcode, op.JitFunction[1:]))
+			case op.Argc == 1 && op.JitFunction[0] == '@':
+				line.WriteString(fmt.Sprintf("TIME_OP  (0x%02x, %s);",
+					op.Opcode, op.JitFunction[1:]))
 			case op.Argc == 1 && op.JitFunction[0] == ':':
 				fields := strings.Split(op.JitFunction, ":")
 				if len(fields) != 3 {
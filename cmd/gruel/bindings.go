@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/internal/ir"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+// Variables bound to typed values
+type env struct {
+	types   map[string]byte
+	values  map[string]any
+	options []grueljit.Option
+}
+
+func newEnv() env {
+	return env{types: make(map[string]byte), values: make(map[string]any)}
+}
+
+// Binds a variable to the value of an expression, cast into the named type if any
+//
+// Bare words that are neither literals nor variables are taken as strings,
+// so that `s:string=abc` works without quotes.
+func (e *env) bind(name, typeName, expr string) error {
+	var target byte
+	if typeName != "" {
+		t, err := grueljit.ParseType(typeName)
+		if err != nil {
+			return err
+		}
+		target = t
+	}
+	if _, ok := e.types[expr]; !ok && isBareWord(expr) &&
+		(target == 0 || target == grueljit.TypeString) {
+		expr = strconv.Quote(expr)
+	}
+	if target != 0 && target != grueljit.TypeTime && target != grueljit.TypeDuration {
+		// Converts like the cast operators
+		expr = "(->" + typeName + " " + expr + ")"
+	}
+	f, err := grueljit.Compile(expr, e.types, e.options...)
+	if err != nil {
+		return err
+	}
+	defer f.Free()
+	t := f.ResultType()
+	if target != 0 && t != target {
+		return fmt.Errorf("expecting %s but got %s", typeLabel(target), typeLabel(t))
+	}
+	value, err := f.Call(e.values)
+	if err != nil {
+		return err
+	}
+	if t == grueljit.TypeBool {
+		value = value != uint64(0)
+	} else if t == grueljit.TypeInt {
+		value = int64(value.(uint64))
+	}
+	e.types[name] = t
+	e.values[name] = value
+	return nil
+}
+
+// Whether the text is a single symbol, like abc but not true or (f x)
+func isBareWord(text string) bool {
+	reader := gruelparser.NewTokenReader(text)
+	_, tokenType, err := reader.NextToken()
+	if err != nil || tokenType != gruelparser.TypeSymbol {
+		return false
+	}
+	_, _, err = reader.NextToken()
+	return err == io.EOF
+}
+
+// Binds variables from a JSON object
+//
+// Keys are names optionally followed by types, like "x" or "x:int8",
+// and nested objects declare records.
+func (e *env) bindJSON(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var object map[string]any
+	decoder := json.NewDecoder(file)
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil {
+		return err
+	}
+	return e.bindObject("", object)
+}
+
+func (e *env) bindObject(prefix string, object map[string]any) error {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name, typeName, _ := strings.Cut(key, ":")
+		name = prefix + name
+		var literal string
+		switch v := object[key].(type) {
+		case map[string]any:
+			if err := e.bindObject(name+".", v); err != nil {
+				return err
+			}
+			continue
+		case json.Number:
+			literal = v.String()
+		case bool:
+			literal = strconv.FormatBool(v)
+		case string:
+			switch typeName {
+			case "time":
+				literal = "#t" + strconv.Quote(v)
+			case "", "string":
+				literal = strconv.Quote(v)
+			default:
+				literal = v
+			}
+		default:
+			return fmt.Errorf("unsupported value for %s", name)
+		}
+		if err := e.bind(name, typeName, literal); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Lists the variables sorted by name
+func (e *env) describe(w io.Writer, indent string) {
+	names := make([]string, 0, len(e.types))
+	for name := range e.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := e.types[name]
+		fmt.Fprintf(w, "%s%s %s = %s\n", indent, name, typeLabel(t), display(e.values[name], t))
+	}
+}
+
+func typeLabel(t byte) string {
+	return ir.TypeName(gruelparser.TokenType(t))
+}
+
+// Formats results of Call by their types
+func display(value any, t byte) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case uint64:
+		if t == grueljit.TypeBool {
+			return strconv.FormatBool(v != 0)
+		} else if t == grueljit.TypeInt {
+			return strconv.FormatInt(int64(v), 10)
+		}
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/internal/ir"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+// A problem found in a rule file
+type diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	pos      int
+}
+
+// Parses, type-checks and lints rule files, exiting with 1 if anything is found
+//
+//	gruel check [-schema schema.json] [-json] <file> [...]
+//
+// Files hold either a single expression, typed by the schema, or declarations
+// and rules as loaded by grueljit.LoadRules, typed by their declarations.
+func runCheck(args []string) {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	schemaPath := flags.String("schema", "", "JSON file mapping variables to types, with objects for records")
+	jsonOutput := flags.Bool("json", false, "write the diagnostics as a JSON array")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s check [flags] <file> [...]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	symbols := make(map[string]byte)
+	if *schemaPath != "" {
+		var err error
+		if symbols, err = readSchema(*schemaPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	files := make([]sourceFile, flags.NArg())
+	for i, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		files[i] = sourceFile{path: path, source: string(source)}
+	}
+	diagnostics := checkFiles(files, *schemaPath, symbols)
+
+	if *jsonOutput {
+		if err := writeDiagnosticsJSON(os.Stdout, diagnostics); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		writeDiagnostics(os.Stdout, diagnostics)
+	}
+	if len(diagnostics) != 0 {
+		os.Exit(1)
+	}
+}
+
+type sourceFile struct {
+	path   string
+	source string
+}
+
+// Checks files against the symbols of a schema, warning about the symbols
+// no file uses unless schemaPath is empty
+func checkFiles(files []sourceFile, schemaPath string, symbols map[string]byte) []diagnostic {
+	diagnostics := []diagnostic{}
+	used := make(map[string]bool)
+	for _, file := range files {
+		c := checker{source: file.source, symbols: symbols, used: used}
+		c.check()
+		for _, d := range c.diagnostics {
+			d.File = file.path
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	if schemaPath != "" {
+		for _, name := range sortedNames(symbols) {
+			if !used[name] {
+				diagnostics = append(diagnostics, diagnostic{File: schemaPath, Severity: "warning",
+					Message: fmt.Sprintf("symbol %s is never used", name)})
+			}
+		}
+	}
+	return diagnostics
+}
+
+func writeDiagnosticsJSON(w io.Writer, diagnostics []diagnostic) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(diagnostics)
+}
+
+func writeDiagnostics(w io.Writer, diagnostics []diagnostic) {
+	for _, d := range diagnostics {
+		if d.Line == 0 {
+			fmt.Fprintf(w, "%s: %s: %s\n", d.File, d.Severity, d.Message)
+		} else {
+			fmt.Fprintf(w, "%s:%d:%d: %s: %s\n", d.File, d.Line, d.Column, d.Severity, d.Message)
+		}
+	}
+}
+
+func sortedNames(symbols map[string]byte) []string {
+	names := make([]string, 0, len(symbols))
+	for name := range symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type checker struct {
+	source      string
+	symbols     map[string]byte
+	used        map[string]bool
+	diagnostics []diagnostic
+}
+
+func (c *checker) report(pos int, severity, format string, args ...any) {
+	line := strings.Count(c.source[:pos], "\n") + 1
+	column := pos - strings.LastIndexByte(c.source[:pos], '\n')
+	c.diagnostics = append(c.diagnostics, diagnostic{
+		Line: line, Column: column, Severity: severity,
+		Message: fmt.Sprintf(format, args...), pos: pos,
+	})
+}
+
+func (c *checker) check() {
+	if forms, _ := gruelparser.ParseAll(c.source); isRuleFile(forms) {
+		c.checkRules(forms)
+	} else {
+		c.checkExpression()
+	}
+	sort.SliceStable(c.diagnostics, func(i, j int) bool {
+		return c.diagnostics[i].pos < c.diagnostics[j].pos
+	})
+}
+
+// Whether the forms include declarations, constants or rules
+func isRuleFile(forms []gruelparser.GruelAstNode) bool {
+	for _, form := range forms {
+		if form.Type == gruelparser.TypeParenthesis &&
+			(form.Value == "declare" || form.Value == "const" || form.Value == "rule") {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks the rules of a rule file, typed by its declarations instead of the schema
+func (c *checker) checkRules(forms []gruelparser.GruelAstNode) {
+	set, err := grueljit.ParseRules(strings.NewReader(c.source))
+	if err != nil {
+		var syntaxErr *gruelparser.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			c.report(syntaxErr.Pos, "error", "%s", syntaxErr.Msg)
+		} else {
+			c.report(0, "error", "%v", err)
+		}
+		return
+	}
+	c.symbols, c.used = set.Symbols, make(map[string]bool)
+	for _, rule := range set.Rules {
+		c.typeCheck(&rule.Body)
+		c.lint(&rule.Body)
+	}
+	for _, form := range forms {
+		if form.Value != "declare" {
+			continue
+		}
+		for _, param := range form.Parameters {
+			if !c.used[param.Value] {
+				c.report(param.Pos, "warning", "symbol %s is never used", param.Value)
+			}
+		}
+	}
+}
+
+// Checks a file of a single expression
+func (c *checker) checkExpression() {
+	ast, err := gruelparser.Parse(c.source)
+	if err != nil {
+		pos := ast.Pos
+		var syntaxErr *gruelparser.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			pos, err = syntaxErr.Pos, errors.New(syntaxErr.Msg)
+		} else if err == io.EOF {
+			pos = len(strings.TrimRight(c.source, " \t\r\n"))
+			err = fmt.Errorf("unexpected end of rule")
+		}
+		c.report(pos, "error", "%v", err)
+		return
+	}
+	// Lints rely on evaluating constants, which skips code failing to compile.
+	c.typeCheck(&ast)
+	c.lint(&ast)
+}
+
+// Compiles sub-expressions bottom-up, reporting the innermost ones that fail
+func (c *checker) typeCheck(node *gruelparser.GruelAstNode) bool {
+	ok := true
+	record := node.Type == gruelparser.TypeParenthesis && node.Value == "get"
+	if !record {
+		for i := range node.Parameters {
+			ok = c.typeCheck(&node.Parameters[i]) && ok
+		}
+	}
+	if !ok {
+		return false
+	}
+	switch {
+	case record:
+		if path, err := ir.FieldPath(node); err == nil {
+			c.used[path] = true
+		}
+	case node.Type == gruelparser.TypeSymbol:
+		c.used[node.Value] = true
+	}
+	if _, err := ir.Compile(node, c.symbols, ir.Options{}); err != nil {
+		c.report(node.Pos, "error", "%v", err)
+		return false
+	}
+	return true
+}
+
+var comparisons = map[string]bool{"<": true, "<=": true, ">": true, ">=": true, "=": true, "==": true, "!=": true}
+
+// Lints suspicious patterns
+func (c *checker) lint(node *gruelparser.GruelAstNode) {
+	if node.Type != gruelparser.TypeParenthesis || node.Value == "get" {
+		return
+	}
+	params := node.Parameters
+	if node.Value == "=" {
+		c.report(node.Pos, "warning", "= duplicates ==, prefer ==")
+	}
+	switch {
+	case comparisons[node.Value] && isConstant(node):
+		if v, _, ok := evaluate(node); ok {
+			c.report(node.Pos, "warning", "comparison is always %v", v != uint64(0))
+		}
+	case comparisons[node.Value] && len(params) == 2 && params[0].String() == params[1].String():
+		c.report(node.Pos, "warning", "%s compared with itself", params[0].String())
+	case node.Value == "/" && len(params) == 2 && isConstant(&params[0]) && isConstant(&params[1]):
+		a, t, ok := evaluate(&params[0])
+		b, u, ok2 := evaluate(&params[1])
+		if !ok || !ok2 || !isInteger(t) || !isInteger(u) {
+			break
+		}
+		x, y := asInt(a), asInt(b)
+		if y == 0 {
+			c.report(node.Pos, "warning", "division by zero")
+		} else if x%y != 0 {
+			c.report(node.Pos, "warning", "integer division %d/%d truncates to %d", x, y, x/y)
+		}
+	case (node.Value == "<<" || node.Value == ">>" || node.Value == ">>>") && len(params) == 2 &&
+		isConstant(&params[1]):
+		count, t, ok := evaluate(&params[1])
+		if !ok || !isInteger(t) {
+			break
+		}
+		width := int64(64)
+		if builder, err := ir.Compile(&params[0], c.symbols, ir.Options{}); err == nil {
+			width = bitWidth(byte(builder.ResultType()))
+		}
+		if n := asInt(count); n < 0 || n >= width {
+			c.report(params[1].Pos, "warning", "shift count %d is beyond %d bits", n, width-1)
+		}
+	case node.Value == "&&" || node.Value == "||":
+		// A constant false in && (or true in ||) decides the result alone.
+		decisive := node.Value == "||"
+		for i := range params {
+			if !isConstant(&params[i]) {
+				continue
+			}
+			if v, t, ok := evaluate(&params[i]); ok && t == grueljit.TypeBool && (v == uint64(1)) == decisive {
+				for j := range params {
+					if j != i {
+						c.report(params[j].Pos, "warning", "sub-expression never affects the result")
+					}
+				}
+				break
+			}
+		}
+	}
+	for i := range params {
+		c.lint(&params[i])
+	}
+}
+
+// Whether the sub-expression uses no symbols
+func isConstant(node *gruelparser.GruelAstNode) bool {
+	if node.Type == gruelparser.TypeSymbol || node.Type == gruelparser.TypeParenthesis && node.Value == "get" {
+		return false
+	}
+	for i := range node.Parameters {
+		if !isConstant(&node.Parameters[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Evaluates a constant sub-expression
+func evaluate(node *gruelparser.GruelAstNode) (any, byte, bool) {
+	f, err := grueljit.Compile(node.String(), nil)
+	if err != nil {
+		return nil, 0, false
+	}
+	defer f.Free()
+	v, err := f.Call(nil)
+	return v, f.ResultType(), err == nil
+}
+
+func isInteger(t byte) bool {
+	return t == grueljit.TypeInt || grueljit.TypeInt8 <= t && t <= grueljit.TypeUint64
+}
+
+// Converts integer results of Call
+func asInt(v any) int64 {
+	switch n := v.(type) {
+	case int8:
+		return int64(n)
+	case int16:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case uint8:
+		return int64(n)
+	case uint16:
+		return int64(n)
+	case uint32:
+		return int64(n)
+	case uint64:
+		return int64(n)
+	}
+	return 0
+}
+
+func bitWidth(t byte) int64 {
+	switch t {
+	case grueljit.TypeInt8, grueljit.TypeUint8:
+		return 8
+	case grueljit.TypeInt16, grueljit.TypeUint16:
+		return 16
+	case grueljit.TypeInt32, grueljit.TypeUint32:
+		return 32
+	default:
+		return 64
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+var checkSymbols = map[string]byte{
+	"a":        grueljit.TypeInt,
+	"s":        grueljit.TypeString,
+	"x":        grueljit.TypeInt8,
+	"user.age": grueljit.TypeInt,
+}
+
+func checkOutput(files []sourceFile, schemaPath string) string {
+	buf := bytes.Buffer{}
+	writeDiagnostics(&buf, checkFiles(files, schemaPath, checkSymbols))
+	return buf.String()
+}
+
+func TestCheckLints(t *testing.T) {
+	for rule, expected := range map[string]string{
+		"(< 1 2)":                  "1:1: warning: comparison is always true",
+		"(>= (+ a 0) a)":           "",
+		"(== a a)":                 "1:1: warning: a compared with itself",
+		"(= a 1)":                  "1:1: warning: = duplicates ==, prefer ==",
+		"(+ a (/ 7 2))":            "1:6: warning: integer division 7/2 truncates to 3",
+		"(+ a (/ 1 0))":            "1:6: warning: division by zero",
+		"(+ a (/ 8 2))":            "",
+		"(<< a 64)":                "1:7: warning: shift count 64 is beyond 63 bits",
+		"(<< a 63)":                "",
+		"(>> x 8)":                 "1:7: warning: shift count 8 is beyond 7 bits",
+		"(>> x 7)":                 "",
+		"(&& false (> a 1))":       "1:11: warning: sub-expression never affects the result",
+		"(|| (> a 1) true)":        "1:5: warning: sub-expression never affects the result",
+		"(&& true (> a 1))":        "",
+		"(> a 1.5":                 "1:9: error: unexpected end of rule",
+		"(> s 1)":                  "1:1: error: operator > does not accept string and int",
+		"(foo a)":                  "1:1: error: operator foo not found",
+		"(> (get user \"age\") 1)": "",
+	} {
+		output := checkOutput([]sourceFile{{path: "rule.gruel", source: rule}}, "")
+		if expected != "" {
+			expected = "rule.gruel:" + expected + "\n"
+		}
+		assert.Equal(t, expected, output, rule)
+	}
+}
+
+func TestCheckUnusedSymbols(t *testing.T) {
+	files := []sourceFile{
+		{path: "a.gruel", source: "(> a 1)"},
+		{path: "b.gruel", source: "(== (get user \"age\") 1)"},
+	}
+	assert.Equal(t, "schema.json: warning: symbol s is never used\n"+
+		"schema.json: warning: symbol x is never used\n", checkOutput(files, "schema.json"))
+	// Without a schema file, symbols only come from the caller.
+	assert.Equal(t, "", checkOutput(files, ""))
+}
+
+func TestCheckRuleFiles(t *testing.T) {
+	source := strings.Join([]string{
+		"(declare (amount float) (country string) (age int))",
+		"(const limit 100.0)",
+		"(rule big (&& false (> amount limit)))",
+		"(rule adult (>= age 18))",
+	}, "\n")
+	assert.Equal(t, "rules.gruel:1:25: warning: symbol country is never used\n"+
+		"rules.gruel:3:21: warning: sub-expression never affects the result\n",
+		checkOutput([]sourceFile{{path: "rules.gruel", source: source}}, ""))
+
+	assert.Equal(t, "rules.gruel:2:1: error: expecting (rule name [:priority n] [:tags (tag ...)] body)\n",
+		checkOutput([]sourceFile{{path: "rules.gruel", source: "(declare (a int))\n(rule x)"}}, ""))
+}
+
+func TestCheckJSON(t *testing.T) {
+	files := []sourceFile{{path: "rule.gruel", source: "(&& (< 1 2)\n  (> a 1))"}}
+	buf := bytes.Buffer{}
+	assert.Nil(t, writeDiagnosticsJSON(&buf, checkFiles(files, "schema.json", map[string]byte{
+		"a": grueljit.TypeInt,
+		"b": grueljit.TypeInt,
+	})))
+	assert.Equal(t, `[
+  {
+    "file": "rule.gruel",
+    "line": 1,
+    "column": 5,
+    "severity": "warning",
+    "message": "comparison is always true"
+  },
+  {
+    "file": "schema.json",
+    "severity": "warning",
+    "message": "symbol b is never used"
+  }
+]
+`, buf.String())
+
+	buf.Reset()
+	assert.Nil(t, writeDiagnosticsJSON(&buf, checkFiles(nil, "", nil)))
+	assert.Equal(t, "[]\n", buf.String())
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+// Evaluates a rule against every line of JSONL events
+//
+//	gruel eval -rule rule.gruel [-schema schema.json] [flags] < events.jsonl
+//
+// Rule files with declarations and rules are typed by their declarations,
+// and results are keyed by the rule names. Single expressions need a schema.
+func runEval(args []string) {
+	flags := flag.NewFlagSet("eval", flag.ExitOnError)
+	rulePath := flags.String("rule", "", "file containing the rule, or declarations and rules")
+	schemaPath := flags.String("schema", "", "JSON file mapping variables to types, with objects for records")
+	filter := flags.Bool("filter", false, "only write the events matching the rule (or any rule), as they are")
+	withInput := flags.Bool("with-input", false, "include the events in the results")
+	workers := flags.Int("workers", runtime.NumCPU(), "number of parallel workers")
+	zone := flags.String("location", "", "time zone for timestamps and calendar operators")
+	native := flags.Bool("native", false, "use the native backend instead of LibJIT")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s eval -rule <file> [-schema <file>] [flags] < events.jsonl\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *rulePath == "" || *workers < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	source, err := os.ReadFile(*rulePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var opts []grueljit.Option
+	if *zone != "" {
+		loc, err := time.LoadLocation(*zone)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, grueljit.WithLocation(loc))
+	}
+	if *native {
+		opts = append(opts, grueljit.WithNativeBackend())
+	}
+	e, err := newEvaluator(*rulePath, string(source), *schemaPath, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer e.free()
+	e.filter, e.withInput = *filter, *withInput
+	if *filter {
+		for _, rule := range e.rules {
+			if t := rule.Function.ResultType(); t != grueljit.TypeBool {
+				log.Fatalf("filtering needs bool rules, not %s", typeLabel(t))
+			}
+		}
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	if err := e.run(os.Stdin, out, *workers); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Reads variable types like {"amount": "decimal", "user": {"age": "int"}}
+func readSchema(path string) (map[string]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]any
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	symbols := make(map[string]byte)
+	var walk func(prefix string, object map[string]any) error
+	walk = func(prefix string, object map[string]any) error {
+		for key, value := range object {
+			switch v := value.(type) {
+			case string:
+				t, err := grueljit.ParseType(v)
+				if err != nil {
+					return fmt.Errorf("%s%s: %w", prefix, key, err)
+				}
+				symbols[prefix+key] = t
+			case map[string]any:
+				if err := walk(prefix+key+".", v); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("%s%s: expecting a type name or an object", prefix, key)
+			}
+		}
+		return nil
+	}
+	return symbols, walk("", object)
+}
+
+type evaluator struct {
+	// The rules of a rule file, or a single unnamed one
+	rules     []*grueljit.Rule
+	named     bool
+	schema    map[string]byte
+	filter    bool
+	withInput bool
+}
+
+// Compiles a rule file, or a single expression typed by the schema
+func newEvaluator(path, source, schemaPath string, opts []grueljit.Option) (*evaluator, error) {
+	if forms, _ := gruelparser.ParseAll(source); isRuleFile(forms) {
+		if schemaPath != "" {
+			return nil, fmt.Errorf("%s declares its variables, which -schema cannot add to", path)
+		}
+		set, err := grueljit.LoadRules(strings.NewReader(source), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", path, err)
+		}
+		if len(set.Rules) == 0 {
+			return nil, fmt.Errorf("%s has no rules", path)
+		}
+		return &evaluator{rules: set.Rules, named: true, schema: set.Symbols}, nil
+	}
+	if schemaPath == "" {
+		return nil, fmt.Errorf("expressions need -schema, unlike rule files with declarations")
+	}
+	schema, err := readSchema(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+	f, err := grueljit.Compile(source, schema, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &evaluator{rules: []*grueljit.Rule{{Function: f}}, schema: schema}, nil
+}
+
+func (e *evaluator) free() {
+	for _, rule := range e.rules {
+		rule.Function.Free()
+	}
+}
+
+// A line being evaluated, with its output once done
+type job struct {
+	line   []byte
+	output chan []byte
+}
+
+// Evaluates lines in parallel, writing the outputs in the input order
+func (e *evaluator) run(in io.Reader, out io.Writer, workers int) error {
+	jobs := make(chan *job, workers)
+	pending := make(chan *job, 4*workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := range jobs {
+				j.output <- e.evaluate(j.line)
+			}
+		}()
+	}
+
+	read := make(chan error, 1)
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(nil, 64<<20)
+		for scanner.Scan() {
+			if len(strings.TrimSpace(scanner.Text())) == 0 {
+				continue
+			}
+			j := &job{line: append([]byte(nil), scanner.Bytes()...), output: make(chan []byte, 1)}
+			pending <- j
+			jobs <- j
+		}
+		read <- scanner.Err()
+	}()
+
+	var err error
+	for j := range pending {
+		if output := <-j.output; output != nil && err == nil {
+			_, err = out.Write(output)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return <-read
+}
+
+// Evaluates a line, returning the output line or nil if filtered out
+//
+// Outputs hold either the result and any error of a single rule, or the
+// results and errors keyed by the rule names.
+func (e *evaluator) evaluate(line []byte) []byte {
+	args, err := e.parse(line)
+	if e.filter {
+		if err != nil {
+			return nil
+		}
+		for _, rule := range e.rules {
+			if result, err := rule.Function.Call(args); err == nil && result == uint64(1) {
+				return append(line, '\n')
+			}
+		}
+		return nil
+	}
+
+	output := make(map[string]any, 3)
+	switch {
+	case err != nil:
+		output["error"] = err.Error()
+	case !e.named:
+		f := e.rules[0].Function
+		if result, err := f.Call(args); err != nil {
+			output["error"] = err.Error()
+		} else {
+			output["result"] = jsonValue(result, f.ResultType())
+		}
+	default:
+		results := make(map[string]any, len(e.rules))
+		errs := make(map[string]string)
+		for _, rule := range e.rules {
+			if result, err := rule.Function.Call(args); err != nil {
+				errs[rule.Name] = err.Error()
+			} else {
+				results[rule.Name] = jsonValue(result, rule.Function.ResultType())
+			}
+		}
+		output["results"] = results
+		if len(errs) != 0 {
+			output["errors"] = errs
+		}
+	}
+	// Lines failing to decode would fail the encoding as well.
+	if e.withInput && json.Valid(line) {
+		output["input"] = json.RawMessage(line)
+	}
+	data, err := json.Marshal(output)
+	if err != nil {
+		data, _ = json.Marshal(map[string]any{"error": err.Error()})
+	}
+	return append(data, '\n')
+}
+
+// Converts the fields of an event into arguments by the schema
+func (e *evaluator) parse(line []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(strings.NewReader(string(line)))
+	decoder.UseNumber()
+	var event map[string]any
+	if err := decoder.Decode(&event); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the event")
+	}
+	args := make(map[string]any, len(e.schema))
+	for name, t := range e.schema {
+		value, ok := field(event, name)
+		if !ok {
+			// Reported by Call if used
+			continue
+		}
+		converted, err := jsonArg(value, t)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		args[name] = converted
+	}
+	return args, nil
+}
+
+// Looks up a dotted path in nested objects
+func field(event map[string]any, name string) (any, bool) {
+	var value any = event
+	for _, key := range strings.Split(name, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// Converts a decoded JSON value into an argument of the type
+func jsonArg(value any, t byte) (any, error) {
+	switch v := value.(type) {
+	case bool:
+		if t == grueljit.TypeBool {
+			return v, nil
+		}
+	case string:
+		switch t {
+		case grueljit.TypeString, grueljit.TypeDecimal:
+			return v, nil
+		case grueljit.TypeTime:
+			return time.Parse(time.RFC3339Nano, v)
+		case grueljit.TypeDuration:
+			return time.ParseDuration(v)
+		}
+	case json.Number:
+		switch t {
+		case grueljit.TypeDecimal:
+			return v.String(), nil
+		case grueljit.TypeFloat, grueljit.TypeFloat32:
+			return v.Float64()
+		case grueljit.TypeUint64:
+			return strconv.ParseUint(v.String(), 10, 64)
+		case grueljit.TypeDuration:
+			n, err := v.Int64()
+			return time.Duration(n), err
+		case grueljit.TypeInt, grueljit.TypeInt8, grueljit.TypeInt16, grueljit.TypeInt32,
+			grueljit.TypeUint8, grueljit.TypeUint16, grueljit.TypeUint32:
+			return v.Int64()
+		}
+	}
+	return nil, fmt.Errorf("unsupported conversion into %s", typeLabel(t))
+}
+
+// Converts results of Call into values encoded as JSON
+func jsonValue(value any, t byte) any {
+	switch v := value.(type) {
+	case uint64:
+		if t == grueljit.TypeBool {
+			return v != 0
+		} else if t == grueljit.TypeInt {
+			return int64(v)
+		}
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return strconv.FormatFloat(v, 'g', -1, 64)
+		}
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return strconv.FormatFloat(float64(v), 'g', -1, 32)
+		}
+	case time.Duration:
+		return v.String()
+	case grueljit.Decimal:
+		return json.Number(v.String())
+	}
+	return value
+}
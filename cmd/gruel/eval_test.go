@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+func newTestEvaluator(t *testing.T, rule, schema string) *evaluator {
+	schemaPath := ""
+	if schema != "" {
+		schemaPath = filepath.Join(t.TempDir(), "schema.json")
+		assert.Nil(t, os.WriteFile(schemaPath, []byte(schema), 0o644))
+	}
+	e, err := newEvaluator("rule.gruel", rule, schemaPath, nil)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(e.free)
+	return e
+}
+
+func evalLines(t *testing.T, e *evaluator, input string, workers int) string {
+	out := bytes.Buffer{}
+	assert.Nil(t, e.run(strings.NewReader(input), &out, workers))
+	return out.String()
+}
+
+func TestEvalOrder(t *testing.T) {
+	e := newTestEvaluator(t, "(* a 2)", `{"a": "int"}`)
+	input, expected := strings.Builder{}, strings.Builder{}
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&input, "{\"a\": %d}\n", i)
+		if i%100 == 0 {
+			input.WriteString("\n")
+		}
+		fmt.Fprintf(&expected, "{\"result\":%d}\n", 2*i)
+	}
+	for _, workers := range []int{1, 3, 16} {
+		assert.Equal(t, expected.String(), evalLines(t, e, input.String(), workers), workers)
+	}
+}
+
+func TestEvalOutputs(t *testing.T) {
+	e := newTestEvaluator(t, "(/ a b)", `{"a": "int", "b": "int"}`)
+	assert.Equal(t, `{"result":2}
+{"error":"runtime error at 1:1: division by zero in /"}
+{"error":"parameter b not found"}
+{"error":"field a: unsupported conversion into int"}
+{"error":"invalid character 'o' in literal null (expecting 'u')"}
+`, evalLines(t, e, `{"a": 4, "b": 2}
+{"a": 4, "b": 0}
+{"a": 4}
+{"a": "4", "b": 2}
+not json
+`, 2))
+
+	e.withInput = true
+	assert.Equal(t, `{"input":{"a":4,"b":2},"result":2}
+{"error":"field a: unsupported conversion into int","input":{"a":"4","b":2}}
+{"error":"invalid character 'o' in literal null (expecting 'u')"}
+{"error":"invalid character '}' looking for beginning of object key string"}
+`, evalLines(t, e, `{"a": 4, "b": 2}
+{"a": "4", "b": 2}
+not json
+{"a": 4,}
+`, 2))
+
+	e.withInput = false
+	assert.Equal(t, `{"error":"unexpected data after the event"}
+{"error":"unexpected data after the event"}
+{"result":2}
+`, evalLines(t, e, `{"a": 4, "b": 2} {"a": 1, "b": 1}
+{"a": 4, "b": 2} garbage
+{"a": 4, "b": 2}
+`, 1))
+}
+
+func TestJSONValue(t *testing.T) {
+	assert.Equal(t, "NaN", jsonValue(math.NaN(), grueljit.TypeFloat))
+	assert.Equal(t, "-Inf", jsonValue(float32(math.Inf(-1)), grueljit.TypeFloat32))
+	assert.Equal(t, "NaN", jsonValue(float32(math.NaN()), grueljit.TypeFloat32))
+	assert.Equal(t, float32(1.5), jsonValue(float32(1.5), grueljit.TypeFloat32))
+}
+
+func TestEvalFilter(t *testing.T) {
+	e := newTestEvaluator(t, "(> amount 10)", `{"amount": "int"}`)
+	e.filter = true
+	assert.Equal(t, `{"amount": 11}
+{"amount": 20, "other": "x"}
+`, evalLines(t, e, `{"amount": 11}
+{"amount": 10}
+{"amount": "12"}
+{"other": 1}
+not json
+{"amount": 20, "other": "x"}
+`, 4))
+}
+
+func TestEvalRuleFiles(t *testing.T) {
+	source := `(declare (amount int) (user.age int))
+(rule big (> amount 10))
+(rule adult (>= user.age 18))`
+	e := newTestEvaluator(t, source, "")
+	assert.Equal(t, `{"results":{"adult":false,"big":true}}
+{"errors":{"adult":"parameter user.age not found"},"results":{"big":false}}
+`, evalLines(t, e, `{"amount": 11, "user": {"age": 17}}
+{"amount": 1}
+`, 2))
+
+	e.filter = true
+	assert.Equal(t, `{"amount": 1, "user": {"age": 18}}
+`, evalLines(t, e, `{"amount": 1, "user": {"age": 18}}
+{"amount": 1, "user": {"age": 17}}
+`, 2))
+
+	_, err := newEvaluator("rule.gruel", source, "schema.json", nil)
+	assert.EqualError(t, err, "rule.gruel declares its variables, which -schema cannot add to")
+	_, err = newEvaluator("rule.gruel", "(> a 1)", "", nil)
+	assert.EqualError(t, err, "expressions need -schema, unlike rule files with declarations")
+}
+
+func TestJSONArg(t *testing.T) {
+	at := time.Date(2024, 2, 29, 12, 30, 0, 0, time.UTC)
+	for _, c := range []struct {
+		value    string
+		t        byte
+		expected any
+	}{
+		{`true`, grueljit.TypeBool, true},
+		{`"x"`, grueljit.TypeString, "x"},
+		{`"1.25"`, grueljit.TypeDecimal, "1.25"},
+		{`1.25`, grueljit.TypeDecimal, "1.25"},
+		{`"2024-02-29T12:30:00Z"`, grueljit.TypeTime, at},
+		{`"1h30m"`, grueljit.TypeDuration, 90 * time.Minute},
+		{`1000`, grueljit.TypeDuration, time.Microsecond},
+		{`1.5`, grueljit.TypeFloat, 1.5},
+		{`2`, grueljit.TypeFloat32, 2.0},
+		{`-3`, grueljit.TypeInt, int64(-3)},
+		{`-3`, grueljit.TypeInt8, int64(-3)},
+		{`18446744073709551615`, grueljit.TypeUint64, uint64(18446744073709551615)},
+	} {
+		value, err := parseJSONValue(c.value)
+		assert.Nil(t, err)
+		arg, err := jsonArg(value, c.t)
+		assert.Nil(t, err, c.value)
+		assert.Equal(t, c.expected, arg, c.value)
+	}
+
+	for _, c := range []struct {
+		value string
+		t     byte
+		msg   string
+	}{
+		{`1`, grueljit.TypeBool, "unsupported conversion into bool"},
+		{`"1"`, grueljit.TypeInt, "unsupported conversion into int"},
+		{`true`, grueljit.TypeString, "unsupported conversion into string"},
+		{`1.5`, grueljit.TypeInt, `strconv.ParseInt: parsing "1.5": invalid syntax`},
+		{`-1`, grueljit.TypeUint64, `strconv.ParseUint: parsing "-1": invalid syntax`},
+		{`"tomorrow"`, grueljit.TypeTime, `parsing time "tomorrow" as "2006-01-02T15:04:05.999999999Z07:00": cannot parse "tomorrow" as "2006"`},
+		{`{}`, grueljit.TypeInt, "unsupported conversion into int"},
+	} {
+		value, err := parseJSONValue(c.value)
+		assert.Nil(t, err)
+		_, err = jsonArg(value, c.t)
+		assert.EqualError(t, err, c.msg, c.value)
+	}
+}
+
+// Decodes a value like the evaluator does for events
+func parseJSONValue(s string) (any, error) {
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	var value any
+	err := decoder.Decode(&value)
+	return value, err
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/yesh0/gruel/pkg/gruelfmt"
+)
+
+// Formats rule files canonically, or the standard input if no files are given
+//
+//	gruel fmt [-w|-d|-l] [file ...]
+func runFmt(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the results to the files instead of the standard output")
+	showDiff := flags.Bool("d", false, "display diffs instead of the formatted rules")
+	list := flags.Bool("l", false, "list the files whose formatting differs")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s fmt [flags] [file ...]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		if *write {
+			log.Fatal("cannot use -w with the standard input")
+		}
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := formatFile("<standard input>", source, false, *showDiff, *list); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	failed := false
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+		if err == nil {
+			err = formatFile(path, source, *write, *showDiff, *list)
+		}
+		if err != nil {
+			log.Print(err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func formatFile(path string, source []byte, write, showDiff, list bool) error {
+	formatted, err := gruelfmt.Format(source)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	changed := !bytes.Equal(source, formatted)
+	if list && changed {
+		fmt.Println(path)
+	}
+	if write && changed {
+		if err := replaceFile(path, formatted); err != nil {
+			return err
+		}
+	}
+	if showDiff && changed {
+		d, err := diff(path, source, formatted)
+		if err != nil {
+			return fmt.Errorf("computing diff: %w", err)
+		}
+		os.Stdout.Write(d)
+	}
+	if !list && !write && !showDiff {
+		os.Stdout.Write(formatted)
+	}
+	return nil
+}
+
+// Writes the file through a temporary one, keeping its permissions
+func replaceFile(path string, content []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(info.Mode().Perm()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Runs `diff -u` on the two versions
+func diff(path string, before, after []byte) ([]byte, error) {
+	var names []string
+	for _, content := range [][]byte{before, after} {
+		f, err := os.CreateTemp("", "gruelfmt")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(f.Name())
+		_, err = f.Write(content)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, f.Name())
+	}
+	data, err := exec.Command("diff", "-u", "--label", path+".orig", "--label", path,
+		names[0], names[1]).CombinedOutput()
+	if len(data) != 0 {
+		// diff exits with 1 when the files differ.
+		return data, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFmtWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rule.gruel")
+	assert.Nil(t, os.WriteFile(path, []byte("(+ 1\n2)"), 0o600))
+	assert.Nil(t, os.Chmod(path, 0o640))
+	source, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Nil(t, formatFile(path, source, true, false, false))
+
+	formatted, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "(+ 1 2)\n", string(formatted))
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yesh0/gruel/internal/gogen"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+// Translates a rule into a Go function
+//
+//	gruel gen-go [flags] <expr> [var1=type1] [var2=type2] [...]
+func genGo(args []string) {
+	flags := flag.NewFlagSet("gen-go", flag.ExitOnError)
+	pkg := flags.String("pkg", "rules", "name of the generated package")
+	name := flags.String("func", "Rule", "name of the generated function")
+	output := flags.String("o", "", "output file, stdout if empty")
+	zone := flags.String("location", "", "time zone for timestamps and calendar operators")
+	strictNaN := flags.Bool("strict-nan", false, "report NaN results as runtime errors")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s gen-go [flags] <expr> [var1=type1] [...]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	rule := gogen.Rule{
+		Name:      *name,
+		Code:      flags.Arg(0),
+		Symbols:   make(map[string]byte, flags.NArg()-1),
+		StrictNaN: *strictNaN,
+	}
+	for _, arg := range flags.Args()[1:] {
+		k, v, ok := strings.Cut(arg, "=")
+		if !ok {
+			log.Fatal("Malformed pairs ", arg)
+		}
+		t, err := grueljit.ParseType(strings.TrimSpace(v))
+		if err != nil {
+			log.Fatal(err)
+		}
+		rule.Symbols[strings.TrimSpace(k)] = t
+	}
+	if *zone != "" {
+		loc, err := time.LoadLocation(*zone)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rule.Location = loc
+	}
+
+	code, err := gogen.Generate(*pkg, []gogen.Rule{rule})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(code)
+	} else {
+		err = os.WriteFile(*output, code, 0o644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
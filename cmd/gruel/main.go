@@ -1,48 +1,78 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/yesh0/gruel/pkg/grueljit"
 )
 
 func main() {
-	if len(os.Args) == 1 {
-		log.Fatalf("Usage: %s <expr> [var1=value1] [var2=value2] [...]\n", os.Args[0])
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "gen-go":
+			genGo(os.Args[2:])
+			return
+		case "repl":
+			runRepl(os.Args[2:])
+			return
+		case "eval":
+			runEval(os.Args[2:])
+			return
+		case "check":
+			runCheck(os.Args[2:])
+			return
+		case "fmt":
+			runFmt(os.Args[2:])
+			return
+		}
 	}
-	expr := os.Args[1]
-	types := make(map[string]byte, len(os.Args)-2)
-	values := make(map[string]any, len(os.Args)-2)
 
-	log.Println("Environment:")
-	for _, arg := range os.Args[2:] {
-		split := strings.Split(arg, "=")
-		if len(split) != 2 {
-			log.Fatal("Malformed pairs", arg)
-		}
-		k, v := split[0], split[1]
-		k = strings.TrimSpace(k)
-		v = strings.TrimSpace(v)
-		log.Println("    ", k, "=", v)
-		n, err := strconv.ParseFloat(v, 64)
-		if err != nil {
+	flags := flag.NewFlagSet("gruel", flag.ExitOnError)
+	vars := flags.String("vars", "", "JSON file of variables, keyed by name or name:type")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(),
+			"Usage: %s [gen-go|repl|eval|check|fmt] [flags] <expr> [var1[:type1]=value1] [...]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	expr := flags.Arg(0)
+
+	env := newEnv()
+	if *vars != "" {
+		if err := env.bindJSON(*vars); err != nil {
 			log.Fatal(err)
 		}
-		types[k] = grueljit.TypeFloat
-		values[k] = n
 	}
+	for _, arg := range flags.Args()[1:] {
+		k, v, ok := strings.Cut(arg, "=")
+		if !ok {
+			log.Fatal("Malformed pairs ", arg)
+		}
+		name, typeName, _ := strings.Cut(strings.TrimSpace(k), ":")
+		if err := env.bind(name, typeName, strings.TrimSpace(v)); err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+	}
+	sb := strings.Builder{}
+	env.describe(&sb, "     ")
+	log.Print("Environment:\n", sb.String())
 	log.Println("Evaluating:\n    ", expr)
 
-	f, err := grueljit.Compile(expr, types)
+	f, err := grueljit.Compile(expr, env.types)
 	if err != nil {
 		log.Fatal(err)
 	}
-	out, err := f.Call(values)
+	out, err := f.Call(env.values)
 	if err != nil {
 		log.Fatal(err)
 	}
-	log.Println("Result:\n    ", out)
+	log.Println("Result:\n    ", display(out, f.ResultType()), ":", typeLabel(f.ResultType()))
 }
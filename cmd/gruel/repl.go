@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/internal/ir"
+	"github.com/yesh0/gruel/pkg/grueljit"
+	"golang.org/x/term"
+)
+
+const replHelp = `Enter expressions to evaluate them, spanning lines until parentheses balance.
+  :set <name> [type] <expr>  binds a variable to the value of the expression
+  :unset <name>              removes a variable
+  :vars                      lists the variables
+  :type <expr>               shows the type of the expression
+  :ir <expr>                 shows the compiled IR of the expression
+  :help                      shows this help
+  :quit                      exits
+`
+
+// Variables and options kept between inputs
+type repl struct {
+	env
+	out io.Writer
+	ir  ir.Options
+}
+
+// Evaluates rules interactively
+//
+//	gruel repl [flags]
+func runRepl(args []string) {
+	flags := flag.NewFlagSet("repl", flag.ExitOnError)
+	zone := flags.String("location", "", "time zone for timestamps and calendar operators")
+	lenient := flags.Bool("lenient", false, "disable runtime checks")
+	native := flags.Bool("native", false, "use the native backend instead of LibJIT")
+	flags.Parse(args)
+
+	r := &repl{env: newEnv()}
+	if *zone != "" {
+		loc, err := time.LoadLocation(*zone)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r.options = append(r.options, grueljit.WithLocation(loc))
+		r.ir.Location = loc
+	}
+	if *lenient {
+		r.options = append(r.options, grueljit.WithLenientMath())
+		r.ir.Lenient = true
+	}
+	if *native {
+		r.options = append(r.options, grueljit.WithNativeBackend())
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		// Piped input, without prompts or line editing
+		r.out = os.Stdout
+		scanner := bufio.NewScanner(os.Stdin)
+		r.loop(func(string) (string, error) {
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return "", err
+				}
+				return "", io.EOF
+			}
+			return scanner.Text(), nil
+		})
+		return
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer term.Restore(fd, state)
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "")
+	r.out = t
+	fmt.Fprint(t, "Gruel REPL, :help for commands\r\n")
+	r.loop(func(prompt string) (string, error) {
+		t.SetPrompt(prompt)
+		return t.ReadLine()
+	})
+}
+
+// Reads inputs until EOF, joining lines until parentheses balance
+func (r *repl) loop(readLine func(prompt string) (string, error)) {
+	var input strings.Builder
+	for {
+		prompt := "gruel> "
+		if input.Len() != 0 {
+			prompt = "   ... "
+		}
+		line, err := readLine(prompt)
+		if err != nil {
+			return
+		}
+		input.WriteString(line)
+		input.WriteByte('\n')
+		if !balanced(input.String()) {
+			continue
+		}
+		command := strings.TrimSpace(input.String())
+		input.Reset()
+		if command == "" {
+			continue
+		}
+		if command == ":quit" || command == ":q" {
+			return
+		}
+		if err := r.execute(command); err != nil {
+			fmt.Fprintf(r.out, "error: %v\n", err)
+		}
+	}
+}
+
+// Whether the input has no unclosed parentheses or strings
+func balanced(input string) bool {
+	reader := gruelparser.NewTokenReader(input)
+	depth := 0
+	for {
+		token, tokenType, err := reader.NextToken()
+		if errors.Is(err, io.EOF) {
+			return depth <= 0
+		}
+		if err != nil {
+			// Unterminated strings need more lines, while other errors are reported.
+			return !strings.Contains(err.Error(), "unterminated")
+		}
+		if tokenType == gruelparser.TypeParenthesis {
+			if token == "(" {
+				depth++
+			} else {
+				depth--
+			}
+		}
+	}
+}
+
+func (r *repl) execute(command string) error {
+	if !strings.HasPrefix(command, ":") {
+		return r.evaluate(command)
+	}
+	name, rest, _ := strings.Cut(command, " ")
+	rest = strings.TrimSpace(rest)
+	switch name {
+	case ":set":
+		return r.set(rest)
+	case ":unset":
+		if _, ok := r.types[rest]; !ok {
+			return fmt.Errorf("variable %s not found", rest)
+		}
+		delete(r.types, rest)
+		delete(r.values, rest)
+		return nil
+	case ":vars":
+		r.describe(r.out, "")
+		return nil
+	case ":type":
+		f, err := grueljit.Compile(rest, r.types, r.options...)
+		if err != nil {
+			return err
+		}
+		defer f.Free()
+		fmt.Fprintln(r.out, typeLabel(f.ResultType()))
+		return nil
+	case ":ir":
+		return r.dumpIr(rest)
+	case ":help":
+		fmt.Fprint(r.out, replHelp)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %s, see :help", name)
+	}
+}
+
+// Compiles and calls the expression, timing both steps
+func (r *repl) evaluate(expr string) error {
+	start := time.Now()
+	f, err := grueljit.Compile(expr, r.types, r.options...)
+	if err != nil {
+		return err
+	}
+	defer f.Free()
+	compiled := time.Now()
+	result, err := f.Call(r.values)
+	called := time.Now()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(r.out, "%s : %s  (compile %v, call %v)\n", display(result, f.ResultType()),
+		typeLabel(f.ResultType()), compiled.Sub(start), called.Sub(compiled))
+	return nil
+}
+
+// Binds a variable like `:set x 5` or `:set x int8 (+ y 1)`
+func (r *repl) set(args string) error {
+	name, expr, _ := strings.Cut(args, " ")
+	expr = strings.TrimSpace(expr)
+	if name == "" || expr == "" {
+		return fmt.Errorf("usage: :set <name> [type] <expr>")
+	}
+	typeName := ""
+	if word, rest, ok := strings.Cut(expr, " "); ok {
+		if _, err := grueljit.ParseType(word); err == nil {
+			typeName, expr = word, strings.TrimSpace(rest)
+		}
+	}
+	return r.bind(name, typeName, expr)
+}
+
+// Prints the instructions, one per line
+func (r *repl) dumpIr(expr string) error {
+	ast, err := gruelparser.Parse(expr)
+	if err != nil {
+		return err
+	}
+	symbols := make(map[string]byte, len(r.types))
+	for name, t := range r.types {
+		symbols[name] = t
+	}
+	builder, err := ir.Compile(&ast, symbols, r.ir)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(builder.ArgMap()))
+	for name, index := range builder.ArgMap() {
+		names[index] = name
+	}
+	for i, in := range builder.Instructions() {
+		var text string
+		switch {
+		case in.Host:
+			text = "call host"
+		case in.Type == gruelparser.TypeParenthesis && in.Operator == nil:
+			text = fmt.Sprintf("call intrinsic %d", in.Value)
+		case in.Type == gruelparser.TypeParenthesis:
+			text = fmt.Sprintf("op %s/%d", in.Name, in.Operator.Argc)
+		case in.Type == gruelparser.TypeSymbol:
+			text = fmt.Sprintf("load %s", names[in.Value])
+		case in.Hidden:
+			text = "push hidden"
+		case in.Type == gruelparser.TypeString:
+			text = "push string " + strconv.Quote(in.String)
+		default:
+			text = fmt.Sprintf("push %s %#x", ir.TypeName(in.Type), in.Value)
+		}
+		if in.Site != 0 {
+			text += fmt.Sprintf(" (check %d)", in.Check)
+		}
+		fmt.Fprintf(r.out, "%4d  %s\n", i, text)
+	}
+	fmt.Fprintf(r.out, "stack %d bytes, scratch %d words\n", builder.MaxStack(), builder.ScratchSize())
+	return nil
+}
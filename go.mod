@@ -6,13 +6,14 @@ require (
 	github.com/Knetic/govaluate v3.0.0+incompatible
 	github.com/mmcloughlin/avo v0.5.0
 	github.com/stretchr/testify v1.8.1
+	golang.org/x/term v0.10.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/mod v0.6.0 // indirect
-	golang.org/x/sys v0.1.0 // indirect
+	golang.org/x/sys v0.10.0 // indirect
 	golang.org/x/tools v0.2.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -7,3 +7,9 @@ package caller
 // - args: a pointer to an []uint64 argument array, probably from unsafe.Pointer(&args[0])
 // - stack: stack size needed
 func CallJit(f uint64, args []uint64, stack uint64) uint64
+
+// Calls machine code taking a pointer to the arguments, like CallJit but without LibJIT.
+// - entry: the address of the code
+// - args: a pointer to an []uint64 argument array, probably from unsafe.Pointer(&args[0])
+// - stack: stack size needed
+func CallNative(entry uint64, args []uint64, stack uint64) uint64
@@ -39,3 +39,39 @@ jit_entry:
 jit_stack_grow:
 	CALL runtime·morestack_noctxt<>+0x00(SB)
 	JMP  jit_entry
+
+// func CallNative(entry uint64, args []uint64, stack uint64) uint64
+TEXT ·CallNative(SB), NOSPLIT|NOFRAME, $16-48
+	NO_LOCAL_POINTERS
+native_entry:
+	// top(SI) = SP - max_stack_size
+	MOVQ SP, SI
+	MOVQ stack+32(FP), DI
+	SUBQ DI, SI
+
+	// if top <= g(DI).stackguard1 { goto stack_grow }
+	MOVQ (TLS), DI
+	CMPQ SI, 16(DI)
+	JBE  native_stack_grow
+
+	// System V calling conventions
+	MOVQ entry+0(FP), AX
+	MOVQ args_base+8(FP), DI
+
+	// Align the stack
+	MOVQ SP, BX
+	ORQ  $+15, SP
+	INCQ SP
+
+	// Call absolute
+	CALL AX
+	// Restore stack
+	MOVQ BX, SP
+
+	// System V: Return value
+	MOVQ AX, ret+40(FP)
+	RET
+
+native_stack_grow:
+	CALL runtime·morestack_noctxt<>+0x00(SB)
+	JMP  native_entry
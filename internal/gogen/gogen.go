@@ -0,0 +1,731 @@
+// This package translates rules into plain Go functions, see `gruel gen-go`.
+//
+// Rules are compiled into IR like for the JIT, and the IR is then translated
+// instruction by instruction, so that generated functions evaluate operands
+// and run checks in the very same order as compiled ones.
+package gogen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/internal/ir"
+)
+
+// A rule to translate into a Go function
+type Rule struct {
+	// The name of the function, a Go identifier
+	Name    string
+	Code    string
+	Symbols map[string]byte
+	// The time zone for timestamp literals and calendar operators (UTC if nil)
+	Location *time.Location
+	// Reports NaN results of floating point operators as runtime errors
+	StrictNaN bool
+}
+
+// Import paths of packages used by generated code
+var imports = map[string]string{
+	"decimal": "github.com/yesh0/gruel/pkg/decimal",
+	"gruelrt": "github.com/yesh0/gruel/pkg/gruelrt",
+	"math":    "math",
+	"strconv": "strconv",
+	"strings": "strings",
+	"time":    "time",
+}
+
+// Generates a Go source file with a function for each rule
+//
+// Functions take the symbols as parameters sorted by name, and return
+// the result along with a *gruelrt.RuntimeError if a runtime check fails.
+// Host functions and native intrinsics are not supported.
+func Generate(pkg string, rules []Rule) ([]byte, error) {
+	if !token.IsIdentifier(pkg) {
+		return nil, fmt.Errorf("invalid package name %s", pkg)
+	}
+	used := make(map[string]bool)
+	names := make(map[string]bool, len(rules))
+	body := bytes.Buffer{}
+	for _, rule := range rules {
+		if !token.IsIdentifier(rule.Name) || names[rule.Name] {
+			return nil, fmt.Errorf("invalid function name %s", rule.Name)
+		}
+		names[rule.Name] = true
+		f := function{rule: rule, used: used}
+		if err := f.generate(&body); err != nil {
+			return nil, fmt.Errorf("%s: %w", rule.Name, err)
+		}
+	}
+
+	out := bytes.Buffer{}
+	out.WriteString("// Code generated by gruel gen-go. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	if len(used) != 0 {
+		paths := make([]string, 0, len(used))
+		for name := range used {
+			paths = append(paths, imports[name])
+		}
+		sort.Slice(paths, func(i, j int) bool {
+			a, b := strings.Contains(paths[i], "."), strings.Contains(paths[j], ".")
+			return a != b && b || a == b && paths[i] < paths[j]
+		})
+		out.WriteString("import (\n")
+		for i, path := range paths {
+			// Standard packages go first.
+			if i > 0 && !strings.Contains(paths[i-1], ".") && strings.Contains(path, ".") {
+				out.WriteString("\n")
+			}
+			fmt.Fprintf(&out, "\t%q\n", path)
+		}
+		out.WriteString(")\n")
+	}
+	out.Write(body.Bytes())
+	return format.Source(out.Bytes())
+}
+
+// A value on the IR stack
+type value struct {
+	// A Go expression without side effects
+	expr string
+	t    gruelparser.TokenType
+	// Constants may need temporaries so that Go does not fold them
+	constant  bool
+	temporary bool
+}
+
+// Hidden operands of calendar and formatting operators
+const typeHidden gruelparser.TokenType = -1
+
+type function struct {
+	rule    Rule
+	program *ir.IrBuilder
+	// Packages referred to by the file
+	used   map[string]bool
+	body   strings.Builder
+	stack  []value
+	temps  int
+	result gruelparser.TokenType
+}
+
+func (f *function) pkg(name string) string {
+	f.used[name] = true
+	return name
+}
+
+func (f *function) generate(out *bytes.Buffer) error {
+	ast, err := gruelparser.Parse(f.rule.Code)
+	if err != nil {
+		return err
+	}
+	program, err := ir.Compile(&ast, f.rule.Symbols, ir.Options{
+		Location:  f.rule.Location,
+		StrictNaN: f.rule.StrictNaN,
+	})
+	if err != nil {
+		return err
+	}
+	f.program = program
+	f.result = program.ResultType()
+
+	symbols := make([]string, 0, len(f.rule.Symbols))
+	for name := range f.rule.Symbols {
+		symbols = append(symbols, name)
+	}
+	sort.Strings(symbols)
+	params := make(map[string]string, len(symbols))
+	taken := make(map[string]bool, len(symbols))
+	signature := make([]string, len(symbols))
+	for i, name := range symbols {
+		param := identifier(name, taken)
+		params[name] = param
+		signature[i] = param + " " + f.paramType(gruelparser.TokenType(f.rule.Symbols[name]))
+	}
+
+	names := make([]string, len(program.ArgMap()))
+	for name, index := range program.ArgMap() {
+		names[index] = name
+	}
+	for _, in := range program.Instructions() {
+		switch {
+		case in.Hidden:
+			// Time zone tables and scratch buffers
+			f.push(value{t: typeHidden})
+		case in.Type == gruelparser.TypeParenthesis:
+			if err := f.operator(in); err != nil {
+				return err
+			}
+		case in.Type == gruelparser.TypeSymbol:
+			name := names[in.Value]
+			f.push(value{expr: f.param(params[name], gruelparser.TokenType(f.rule.Symbols[name])),
+				t: gruelparser.TokenType(f.rule.Symbols[name])})
+		case in.Type == gruelparser.TypeString:
+			f.push(value{expr: strconv.Quote(in.String), t: in.Type, constant: true})
+		default:
+			literal, err := f.literal(in.Type, in.Value)
+			if err != nil {
+				return err
+			}
+			f.push(value{expr: literal, t: in.Type, constant: true})
+		}
+	}
+	if len(f.stack) != 1 {
+		return fmt.Errorf("unexpected stack size %d", len(f.stack))
+	}
+
+	out.WriteString("\n")
+	fmt.Fprintf(out, "// %s is generated from the rule:\n//\n", f.rule.Name)
+	for _, line := range strings.Split(strings.TrimSpace(f.rule.Code), "\n") {
+		fmt.Fprintf(out, "//\t%s\n", strings.TrimRight(line, " \t\r"))
+	}
+	fmt.Fprintf(out, "func %s(%s) (%s, error) {\n", f.rule.Name, strings.Join(signature, ", "),
+		f.paramType(f.result))
+	out.WriteString(f.body.String())
+	fmt.Fprintf(out, "return %s, nil\n}\n", f.output(f.stack[0]))
+	return nil
+}
+
+func (f *function) push(v value) {
+	f.stack = append(f.stack, v)
+}
+
+// Pops operands, the first one being the top
+func (f *function) pop(n int) []value {
+	operands := make([]value, n)
+	for i := range operands {
+		operands[i] = f.stack[len(f.stack)-1-i]
+	}
+	f.stack = f.stack[:len(f.stack)-n]
+	return operands
+}
+
+// Assigns the expression to a new temporary
+func (f *function) temp(expr string, t gruelparser.TokenType) value {
+	name := fmt.Sprintf("v%d", f.temps)
+	f.temps++
+	fmt.Fprintf(&f.body, "%s := %s\n", name, expr)
+	return value{expr: name, t: t, temporary: true}
+}
+
+func (f *function) operator(in ir.Instruction) error {
+	name, op, check, site := in.Name, in.Operator, in.Check, in.Site
+	if op == nil {
+		return fmt.Errorf("native intrinsics are not supported")
+	}
+	if kind := op.JitFunction[0]; kind == '@' || kind == '&' {
+		f.pop(1)
+	}
+	operands := f.pop(op.Argc)
+	types := make([]gruelparser.TokenType, len(operands))
+	for i, operand := range operands {
+		types[i] = operand.t
+	}
+	result, err := ir.OperatorType(name, types)
+	if err != nil {
+		return err
+	}
+
+	// Go rejects constant expressions that overflow or divide by zero,
+	// so constants only appear next to variables.
+	for i, operand := range operands {
+		if !operand.constant {
+			continue
+		}
+		folded := len(operands) == 1 || operands[1-i].constant ||
+			i == 1 && (name == "/" || name == "%" || strings.HasPrefix(name, "<<") ||
+				strings.HasPrefix(name, ">>"))
+		if folded {
+			operands[i] = f.temp(operand.expr, operand.t)
+		}
+	}
+
+	if call := f.overflowing(op, operands); call != "" {
+		// The decimal functions clamp out of range results, and report them.
+		status := "_"
+		if check == ir.CheckOverflow {
+			status = "err"
+		}
+		d := fmt.Sprintf("d%d", f.temps)
+		fmt.Fprintf(&f.body, "%s, %s := %s\n", d, status, call)
+		if check == ir.CheckOverflow {
+			f.fail("err != nil", gruelrt("FaultOverflow"), site)
+		}
+		f.push(f.temp("int64("+d+")", result))
+		return nil
+	}
+
+	switch check {
+	case ir.CheckDivisor:
+		f.fail(f.isZero(operands[1]), gruelrt("FaultDivisionByZero"), site)
+	case ir.CheckShift:
+		f.fail(fmt.Sprintf("%s >= %d", f.convert(operands[1], typeUint64), shiftWidth(operands[0].t)),
+			gruelrt("FaultShift"), site)
+	}
+	expr, discarded, err := f.apply(name, op, operands, result)
+	if err != nil {
+		return err
+	}
+	if discarded {
+		for _, operand := range operands {
+			if operand.temporary {
+				fmt.Fprintf(&f.body, "_ = %s\n", operand.expr)
+			}
+		}
+	}
+	var v value
+	if len(operands) == 1 && expr == operands[0].expr {
+		// Conversions into the same Go type
+		v = operands[0]
+		v.t = result
+	} else {
+		v = f.temp(expr, result)
+	}
+	if check == ir.CheckNaN {
+		f.fail(f.call("math", "IsNaN", f.convert(v, typeFloat)), gruelrt("FaultNaN"), site)
+	}
+	f.push(v)
+	return nil
+}
+
+// Calls the decimal function of operators whose results may be out of range,
+// returning "" for other operators
+func (f *function) overflowing(op *ir.Operator, operands []value) string {
+	switch op.Opcode {
+	case 0xa0:
+		return fmt.Sprintf("%s.Decimal(%s).Mul(decimal.Decimal(%s), decimal.HalfEven)",
+			f.pkg("decimal"), operands[0].expr, operands[1].expr)
+	case 0xa2:
+		return f.call("decimal", "FromInt", f.convert(operands[0], typeInt))
+	case 0xa3:
+		return f.call("decimal", "FromFloat", f.convert(operands[0], typeFloat))
+	default:
+		return ""
+	}
+}
+
+func gruelrt(name string) string {
+	return "gruelrt." + name
+}
+
+// Returns a runtime error if the condition holds
+func (f *function) fail(condition string, fault string, site int) {
+	s := f.program.Sites()[site-1]
+	code := f.rule.Code
+	line := strings.Count(code[:s.Pos], "\n") + 1
+	column := s.Pos - strings.LastIndexByte(code[:s.Pos], '\n')
+	f.pkg("gruelrt")
+	fmt.Fprintf(&f.body, "if %s {\nreturn %s, &gruelrt.RuntimeError{Fault: %s, Operator: %q, Line: %d, Column: %d}\n}\n",
+		condition, f.zero(), fault, s.Operator, line, column)
+}
+
+func (f *function) isZero(v value) string {
+	if v.t == typeBool {
+		return "!" + v.expr
+	}
+	return v.expr + " == 0"
+}
+
+// Values are shifted at their own widths, see check_operands in gruel_jit.c.
+func shiftWidth(t gruelparser.TokenType) int {
+	switch t {
+	case typeInt8, typeUint8:
+		return 8
+	case typeInt16, typeUint16:
+		return 16
+	case typeInt32, typeUint32:
+		return 32
+	default:
+		return 64
+	}
+}
+
+func (f *function) call(pkg string, name string, args ...string) string {
+	return fmt.Sprintf("%s.%s(%s)", f.pkg(pkg), name, strings.Join(args, ", "))
+}
+
+// Builds the expression of an operator, which may discard its operands
+func (f *function) apply(name string, op *ir.Operator, args []value,
+	result gruelparser.TokenType) (string, bool, error) {
+	if len(args) == 1 {
+		return f.unary(name, op, args[0], result)
+	}
+	a, b := args[0], args[1]
+	switch {
+	case op.Opcode == 0xa1:
+		return fmt.Sprintf("int64(%s.Decimal(%s).Div(decimal.Decimal(%s), decimal.HalfEven))",
+			f.pkg("decimal"), a.expr, b.expr), false, nil
+	case strings.HasPrefix(name, "round-"):
+		modes := map[string]string{
+			"round-half-even": "HalfEven",
+			"round-half-up":   "HalfUp",
+			"round-down":      "Down",
+			"round-up":        "Up",
+			"round-floor":     "Floor",
+			"round-ceiling":   "Ceiling",
+		}
+		return fmt.Sprintf("int64(%s.Decimal(%s).Round(%s, decimal.%s))",
+			f.pkg("decimal"), a.expr, b.expr, modes[name]), false, nil
+	case name == "index":
+		return fmt.Sprintf("int64(%s)", f.call("strings", "Index", a.expr, b.expr)), false, nil
+	case name == "&&" || name == "||":
+		return fmt.Sprintf("%s %s %s", truth(a), name, truth(b)), false, nil
+	case name == "atan2" || name == "pow" || name == "**":
+		if a.t == typeFloat32 || b.t == typeFloat32 {
+			return "", false, unsupported(name, a.t)
+		}
+		fn := map[string]string{"atan2": "Atan2", "pow": "Pow", "**": "Pow"}[name]
+		return f.call("math", fn, f.convert(a, typeFloat), f.convert(b, typeFloat)), false, nil
+	case name == "=" || name == "==" || name == "!=":
+		negated := name == "!="
+		if (a.t == typeString) != (b.t == typeString) {
+			return strconv.FormatBool(negated), true, nil
+		}
+		if negated {
+			name = "!="
+		} else {
+			name = "=="
+		}
+		if a.t == typeBool && b.t == typeBool || a.t == typeString {
+			return fmt.Sprintf("%s %s %s", a.expr, name, b.expr), false, nil
+		}
+		t := common(a.t, b.t)
+		return fmt.Sprintf("%s %s %s", f.convert(a, t), name, f.convert(b, t)), false, nil
+	case name == "<" || name == "<=" || name == ">" || name == ">=":
+		t := common(a.t, b.t)
+		return fmt.Sprintf("%s %s %s", f.convert(a, t), name, f.convert(b, t)), false, nil
+	case name == "cmpl" || name == "cmpg":
+		t := common(a.t, b.t)
+		fn := map[string]string{"cmpl": "Cmpl", "cmpg": "Cmpg"}[name]
+		return f.call("gruelrt", fn, f.convert(a, t), f.convert(b, t)), false, nil
+	case name == "<<" || name == ">>" || name == ">>>":
+		return f.shift(name, a, b, result)
+	}
+
+	// Arithmetic and bitwise operators
+	t := result
+	if isOpaque(a.t) || isOpaque(b.t) || isOpaque(t) {
+		// Timestamps, durations and decimals are computed as raw integers.
+		t = typeInt
+	}
+	x, y := f.convert(a, t), f.convert(b, t)
+	switch name {
+	case "min", "max":
+		fn := map[string]string{"min": "Min", "max": "Max"}[name]
+		return f.call("gruelrt", fn, x, y), false, nil
+	case "%":
+		switch t {
+		case typeFloat:
+			return f.call("math", "Mod", x, y), false, nil
+		case typeFloat32:
+			return fmt.Sprintf("float32(%s)", f.call("math", "Mod", "float64("+x+")", "float64("+y+")")),
+				false, nil
+		}
+	}
+	return fmt.Sprintf("%s %s %s", x, name, y), false, nil
+}
+
+func (f *function) shift(name string, a, b value, result gruelparser.TokenType) (string, bool, error) {
+	x, n := f.convert(a, result), f.convert(b, typeUint64)
+	if name != ">>>" || isUnsigned(result) {
+		return fmt.Sprintf("%s %s %s", x, name[:2], n), false, nil
+	}
+	// Signed values are shifted logically at their own widths.
+	return fmt.Sprintf("%s(uint%d(%s) >> %s)", goType(result), shiftWidth(result), x, n), false, nil
+}
+
+var mathFunctions = map[string]string{
+	"acos":  "Acos",
+	"asin":  "Asin",
+	"atan":  "Atan",
+	"ceil":  "Ceil",
+	"cos":   "Cos",
+	"cosh":  "Cosh",
+	"exp":   "Exp",
+	"floor": "Floor",
+	"log":   "Log",
+	"log10": "Log10",
+	"rint":  "RoundToEven",
+	"round": "Round",
+	"sin":   "Sin",
+	"sinh":  "Sinh",
+	"sqrt":  "Sqrt",
+	"tan":   "Tan",
+	"tanh":  "Tanh",
+	"trunc": "Trunc",
+}
+
+var calendarFunctions = map[string]string{
+	"hour":         "Hour",
+	"minute":       "Minute",
+	"weekday":      "Weekday",
+	"day-of-month": "DayOfMonth",
+	"month":        "Month",
+	"year":         "Year",
+}
+
+func (f *function) unary(name string, op *ir.Operator, a value,
+	result gruelparser.TokenType) (string, bool, error) {
+	float := a.t == typeFloat || a.t == typeFloat32
+	switch {
+	case mathFunctions[name] != "":
+		if a.t == typeFloat32 {
+			return "", false, unsupported(name, a.t)
+		}
+		return f.call("math", mathFunctions[name], f.convert(a, typeFloat)), false, nil
+	case calendarFunctions[name] != "":
+		return f.call("gruelrt", calendarFunctions[name], a.expr, f.location()), false, nil
+	case name == "len":
+		return fmt.Sprintf("int64(len(%s))", a.expr), false, nil
+	case name == "->bool":
+		return truth(a), false, nil
+	case name == "!":
+		if a.t == typeBool {
+			return "!" + a.expr, false, nil
+		}
+		return a.expr + " == 0", false, nil
+	case name == "nan?" || name == "inf?" || name == "finite?":
+		if !float {
+			return strconv.FormatBool(name == "finite?"), true, nil
+		}
+		x := f.convert(a, typeFloat)
+		switch name {
+		case "nan?":
+			return f.call("math", "IsNaN", x), false, nil
+		case "inf?":
+			return f.call("math", "IsInf", x, "0"), false, nil
+		default:
+			return fmt.Sprintf("!%s && !%s", f.call("math", "IsNaN", x), f.call("math", "IsInf", x, "0")),
+				false, nil
+		}
+	case name == "-":
+		if isOpaque(a.t) {
+			return "-" + a.expr, false, nil
+		}
+		return "-" + f.convert(a, result), false, nil
+	case name == "^":
+		return "^" + f.convert(a, result), false, nil
+	case name == "abs":
+		switch result {
+		case typeFloat:
+			return f.call("math", "Abs", a.expr), false, nil
+		case typeFloat32:
+			return fmt.Sprintf("float32(%s)", f.call("math", "Abs", "float64("+a.expr+")")), false, nil
+		}
+		if isOpaque(a.t) {
+			return f.call("gruelrt", "Abs", a.expr), false, nil
+		}
+		return f.call("gruelrt", "Abs", f.convert(a, result)), false, nil
+	case name == "sign":
+		if a.t == typeBool {
+			return f.call("gruelrt", "Int", a.expr), false, nil
+		}
+		return f.call("gruelrt", "Sign", a.expr), false, nil
+	case name == "->string":
+		return f.format(a), false, nil
+	case op.Opcode == 0xb1:
+		return a.expr + " / " + f.pkg("decimal") + ".Scale", false, nil
+	case op.Opcode == 0xb4:
+		return "float64(" + a.expr + ") / " + f.pkg("decimal") + ".Scale", false, nil
+	case op.JitFunction[0] == '%':
+		if goType(a.t) == goType(result) {
+			return a.expr, false, nil
+		}
+		if float && isInteger(result) && result != typeInt && result != typeUint64 {
+			// Floats are converted into small integers through 64 bits.
+			return fmt.Sprintf("%s(int64(%s))", goType(result), a.expr), false, nil
+		}
+		return f.convert(a, result), false, nil
+	default:
+		return "", false, unsupported(name, a.t)
+	}
+}
+
+// Formats like the gruel_format_* functions in gruel_jit.c
+func (f *function) format(a value) string {
+	switch a.t {
+	case typeString:
+		return a.expr
+	case typeBool:
+		return f.call("strconv", "FormatBool", a.expr)
+	case typeUint64:
+		return f.call("strconv", "FormatUint", a.expr, "10")
+	case typeFloat:
+		return f.call("strconv", "FormatFloat", a.expr, "'g'", "-1", "64")
+	case typeFloat32:
+		return f.call("strconv", "FormatFloat", "float64("+a.expr+")", "'g'", "-1", "32")
+	case typeDecimal:
+		return f.pkg("decimal") + ".Decimal(" + a.expr + ").String()"
+	default:
+		return f.call("strconv", "FormatInt", f.convert(a, typeInt), "10")
+	}
+}
+
+func (f *function) location() string {
+	loc := f.program.Location()
+	if loc == time.UTC {
+		return f.pkg("time") + ".UTC"
+	}
+	return fmt.Sprintf("%s(%q)", f.pkg("gruelrt")+".Location", loc.String())
+}
+
+func truth(v value) string {
+	if v.t == typeBool {
+		return v.expr
+	}
+	return "(" + v.expr + " != 0)"
+}
+
+func unsupported(name string, t gruelparser.TokenType) error {
+	return fmt.Errorf("operator %s on %s is not supported", name, typeName(t))
+}
+
+// Converts a value into the Go type of another type
+func (f *function) convert(v value, t gruelparser.TokenType) string {
+	from, to := goType(v.t), goType(t)
+	switch {
+	case from == to:
+		return v.expr
+	case v.t == typeBool:
+		i := f.call("gruelrt", "Int", v.expr)
+		if to == "int64" {
+			return i
+		}
+		return to + "(" + i + ")"
+	case t == typeBool:
+		return "(" + v.expr + " != 0)"
+	default:
+		return to + "(" + v.expr + ")"
+	}
+}
+
+// Promotes numeric operands like LibJIT does, see ir.promote
+func common(a, b gruelparser.TokenType) gruelparser.TokenType {
+	switch {
+	case isSized(a):
+		return a
+	case isSized(b):
+		return b
+	case isOpaque(a):
+		return typeInt
+	case a == typeFloat || b == typeFloat:
+		return typeFloat
+	default:
+		return typeInt
+	}
+}
+
+// Converts parameters into the internal representations
+func (f *function) param(name string, t gruelparser.TokenType) string {
+	switch t {
+	case typeTime:
+		return name + ".UnixNano()"
+	case typeDuration, typeDecimal:
+		return "int64(" + name + ")"
+	default:
+		return name
+	}
+}
+
+// Converts the result from the internal representation
+func (f *function) output(v value) string {
+	switch v.t {
+	case typeTime:
+		return f.call("gruelrt", "Time", v.expr, f.location())
+	case typeDuration:
+		return f.pkg("time") + ".Duration(" + v.expr + ")"
+	case typeDecimal:
+		return f.pkg("decimal") + ".Decimal(" + v.expr + ")"
+	default:
+		return v.expr
+	}
+}
+
+func (f *function) zero() string {
+	switch f.result {
+	case typeBool:
+		return "false"
+	case typeString:
+		return `""`
+	case typeTime:
+		return f.pkg("time") + ".Time{}"
+	default:
+		return "0"
+	}
+}
+
+// The Go types of parameters and results
+func (f *function) paramType(t gruelparser.TokenType) string {
+	switch t {
+	case typeTime:
+		return f.pkg("time") + ".Time"
+	case typeDuration:
+		return f.pkg("time") + ".Duration"
+	case typeDecimal:
+		return f.pkg("decimal") + ".Decimal"
+	default:
+		return goType(t)
+	}
+}
+
+// Writes a constant as a typed Go expression
+func (f *function) literal(t gruelparser.TokenType, v uint64) (string, error) {
+	switch t {
+	case typeBool:
+		return strconv.FormatBool(v != 0), nil
+	case typeFloat, typeFloat32:
+		n := math.Float64frombits(v)
+		s := strconv.FormatFloat(n, 'g', -1, 64)
+		if n == 0 && math.Signbit(n) {
+			s = f.call("math", "Copysign", "0", "-1")
+		}
+		if t == typeFloat32 {
+			// Rounded from float64 like the JIT does
+			return "float32(float64(" + s + "))", nil
+		}
+		return "float64(" + s + ")", nil
+	case typeUint64:
+		return "uint64(" + strconv.FormatUint(v, 10) + ")", nil
+	case typeUint8, typeUint16, typeUint32, typeInt8, typeInt16, typeInt32,
+		typeInt, typeTime, typeDuration, typeDecimal:
+		return goType(t) + "(" + strconv.FormatInt(int64(v), 10) + ")", nil
+	default:
+		return "", fmt.Errorf("values of type %d are not supported", t)
+	}
+}
+
+var temporaries = regexp.MustCompile(`^[vd][0-9]+$`)
+
+// Names that parameters must not shadow
+var reserved = map[string]bool{
+	"bool": true, "string": true, "int64": true, "uint64": true, "float64": true, "float32": true,
+	"int8": true, "int16": true, "int32": true, "uint8": true, "uint16": true, "uint32": true,
+	"len": true, "true": true, "false": true, "nil": true, "err": true,
+}
+
+// Turns a symbol like "user.age" into a unique Go identifier like "user_age"
+func identifier(name string, taken map[string]bool) string {
+	id := []rune(name)
+	for i, r := range id {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			id[i] = '_'
+		}
+	}
+	s := string(id)
+	if s == "" || unicode.IsDigit(id[0]) {
+		s = "p" + s
+	}
+	for token.IsKeyword(s) || reserved[s] || imports[s] != "" || temporaries.MatchString(s) || taken[s] || s == "_" {
+		s += "_"
+	}
+	taken[s] = true
+	return s
+}
@@ -0,0 +1,98 @@
+package gogen_test
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/internal/gogen"
+	"github.com/yesh0/gruel/internal/gogen/golden"
+	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/pkg/gruelrt"
+)
+
+var update = flag.Bool("update", false, "regenerate golden/rules.go")
+
+func TestGolden(t *testing.T) {
+	rules := make([]gogen.Rule, len(golden.Cases))
+	for i, c := range golden.Cases {
+		rules[i] = gogen.Rule{Name: c.Name, Code: c.Code, Symbols: c.Symbols}
+	}
+	code, err := gogen.Generate("golden", rules)
+	assert.Nil(t, err)
+	if *update {
+		assert.Nil(t, os.WriteFile("golden/rules.go", code, 0o644))
+	}
+	expected, err := os.ReadFile("golden/rules.go")
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), string(code), "golden/rules.go is outdated")
+}
+
+func TestGoldenResults(t *testing.T) {
+	for _, c := range golden.Cases {
+		for i, want := range c.Want {
+			if want == nil {
+				continue
+			}
+			v, err := c.Call(c.Args[i])
+			assert.Nil(t, err, c.Code)
+			if f, ok := want.(float64); ok {
+				assert.InDelta(t, f, v, 1e-9, c.Code)
+			} else {
+				assert.Equal(t, want, v, c.Code)
+			}
+		}
+	}
+
+	c := golden.Cases[0]
+	for _, c = range golden.Cases {
+		if c.Name == "DivideByZero" {
+			break
+		}
+	}
+	_, err := c.Call(c.Args[0])
+	assert.EqualError(t, err, "runtime error at 2:3: division by zero in /")
+
+	// Shift counts and decimal ranges are checked at run time.
+	faults := map[string]gruelrt.Fault{"Shift": gruelrt.FaultShift, "Overflow": gruelrt.FaultOverflow}
+	counts := make(map[gruelrt.Fault]int)
+	for _, c := range golden.Cases {
+		for name, fault := range faults {
+			if !strings.Contains(c.Name, name) {
+				continue
+			}
+			for i, want := range c.Want {
+				if want == nil {
+					_, err := c.Call(c.Args[i])
+					var runtimeErr *gruelrt.RuntimeError
+					if assert.ErrorAs(t, err, &runtimeErr, c.Code) {
+						assert.Equal(t, fault, runtimeErr.Fault, c.Code)
+					}
+					counts[fault]++
+				}
+			}
+		}
+	}
+	assert.Equal(t, map[gruelrt.Fault]int{gruelrt.FaultShift: 5, gruelrt.FaultOverflow: 6}, counts)
+}
+
+func TestUnsupported(t *testing.T) {
+	symbols := map[string]byte{"x": byte(gruelparser.TypeFloat)}
+	for _, code := range []string{"(& x 1)", "(<< x 1)", "(unknown x)", "(+ x"} {
+		_, err := gogen.Generate("rules", []gogen.Rule{{Name: "Rule", Code: code, Symbols: symbols}})
+		assert.NotNil(t, err, code)
+	}
+	_, err := gogen.Generate("rules", []gogen.Rule{{Name: "Rule", Code: "x"}, {Name: "Rule", Code: "x"}})
+	assert.NotNil(t, err)
+}
+
+func TestStrictNaN(t *testing.T) {
+	code, err := gogen.Generate("rules", []gogen.Rule{{
+		Name: "Rule", Code: "(sqrt x)",
+		Symbols: map[string]byte{"x": byte(gruelparser.TypeFloat)}, StrictNaN: true,
+	}})
+	assert.Nil(t, err)
+	assert.Contains(t, string(code), "math.IsNaN(v0)")
+}
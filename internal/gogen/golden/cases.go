@@ -0,0 +1,298 @@
+// This package holds rules translated by gen-go, which are checked against the JIT.
+//
+// Run `go test ./internal/gogen -update` to regenerate rules.go after changing the cases.
+package golden
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/pkg/decimal"
+)
+
+const (
+	typeInt     = byte(gruelparser.TypeInt)
+	typeFloat   = byte(gruelparser.TypeFloat)
+	typeString  = byte(gruelparser.TypeString)
+	typeTime    = byte(gruelparser.TypeTime)
+	typeDecimal = byte(gruelparser.TypeDecimal)
+	typeInt8    = byte(gruelparser.TypeInt8)
+	typeUint8   = byte(gruelparser.TypeUint8)
+	typeUint64  = byte(gruelparser.TypeUint64)
+	typeFloat32 = byte(gruelparser.TypeFloat32)
+)
+
+var xFloatYInt = map[string]byte{"x": typeFloat, "y": typeInt}
+
+// Arguments of xFloatYInt shared by the operator cases, NaN included
+var xFloatYIntArgs = []map[string]any{
+	{"x": 1.0, "y": int64(1)},
+	{"x": 1.5, "y": int64(2)},
+	{"x": -0.75, "y": int64(-3)},
+	{"x": 0.25, "y": int64(0)},
+	{"x": math.NaN(), "y": int64(7)},
+}
+
+// A rule with arguments to call it with
+type Case struct {
+	Name    string
+	Code    string
+	Symbols map[string]byte
+	// Arguments by symbol names, of the parameter types of Func
+	Args []map[string]any
+	// The generated function
+	Func any
+	// Expected results of the calls (nil to skip), or nil to only compare with the JIT
+	Want []any
+}
+
+// Calls the generated function with the arguments passed by names
+func (c *Case) Call(args map[string]any) (any, error) {
+	names := make([]string, 0, len(c.Symbols))
+	for name := range c.Symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	in := make([]reflect.Value, len(names))
+	for i, name := range names {
+		in[i] = reflect.ValueOf(args[name])
+	}
+	out := reflect.ValueOf(c.Func).Call(in)
+	err, _ := out[1].Interface().(error)
+	return out[0].Interface(), err
+}
+
+var Cases = []Case{
+	{Name: "Constant0", Code: "1", Symbols: nil,
+		Args: []map[string]any{{}}, Func: Constant0, Want: []any{int64(1)}},
+	{Name: "Constant1", Code: "(+ 123000 456)", Symbols: nil,
+		Args: []map[string]any{{}}, Func: Constant1, Want: []any{int64(123456)}},
+	{Name: "Constant2", Code: "(- 123000 456)", Symbols: nil,
+		Args: []map[string]any{{}}, Func: Constant2, Want: []any{int64(122544)}},
+	{Name: "Constant3", Code: "(* 123 1000)", Symbols: nil,
+		Args: []map[string]any{{}}, Func: Constant3, Want: []any{int64(123000)}},
+	{Name: "Constant4", Code: "(/ 1230 10)", Symbols: nil,
+		Args: []map[string]any{{}}, Func: Constant4, Want: []any{int64(123)}},
+	{Name: "Constant5", Code: "(% 123 100)", Symbols: nil,
+		Args: []map[string]any{{}}, Func: Constant5, Want: []any{int64(23)}},
+	{Name: "Constant6", Code: "(/ 31536000. 365 24 60 60)", Symbols: nil,
+		Args: []map[string]any{{}}, Func: Constant6, Want: []any{1.0}},
+	{Name: "Constant7", Code: "(+ true true)", Symbols: nil,
+		Args: []map[string]any{{}}, Func: Constant7, Want: []any{int64(2)}},
+	{Name: "Constant8", Code: "(+ true false)", Symbols: nil,
+		Args: []map[string]any{{}}, Func: Constant8, Want: []any{int64(1)}},
+	{Name: "Constant9", Code: "(+ 1.23 0.00456)", Symbols: nil,
+		Args: []map[string]any{{}}, Func: Constant9, Want: []any{1.23456}},
+	{Name: "Constant10", Code: "(/ 10 0.5)", Symbols: nil,
+		Args: []map[string]any{{}}, Func: Constant10, Want: []any{20.0}},
+	{Name: "Constant11", Code: "(+ (- (* (/ 4 (% 6 5)) 3) 2) 1)", Symbols: nil,
+		Args: []map[string]any{{}}, Func: Constant11, Want: []any{int64(11)}},
+	{Name: "Args", Code: "(+ (* 2 x) (% y 9))", Symbols: xFloatYInt,
+		Args: []map[string]any{{"x": 9.0, "y": int64(4)}, {"x": 0.5, "y": int64(-7)}, {"x": -3.25, "y": int64(math.MaxInt64)}, {"x": 1e-3, "y": int64(math.MinInt64)}}, Func: Args, Want: []any{22.0, nil, nil, nil}},
+	{Name: "MoreArgs", Code: "(+ f (+ e (+ d (+ c (+ a b)))))", Symbols: map[string]byte{"a": typeFloat, "b": typeFloat, "c": typeFloat, "d": typeFloat, "e": typeFloat, "f": typeFloat},
+		Args: []map[string]any{{"a": 1.0, "b": 2.0, "c": 3.0, "d": 4.0, "e": 5.0, "f": 6.0}}, Func: MoreArgs, Want: []any{21.0}},
+	{Name: "String0", Code: "(len \"Hello\")", Symbols: nil,
+		Args: []map[string]any{{}}, Func: String0, Want: []any{int64(5)}},
+	{Name: "String1", Code: "(== \"Hello\" \"Hello\")", Symbols: nil,
+		Args: []map[string]any{{}}, Func: String1, Want: []any{true}},
+	{Name: "String2", Code: "(== \"Hello\" \"hello\")", Symbols: nil,
+		Args: []map[string]any{{}}, Func: String2, Want: []any{false}},
+	{Name: "String3", Code: "(== \"1\" 1)", Symbols: nil,
+		Args: []map[string]any{{}}, Func: String3, Want: []any{false}},
+	{Name: "String4", Code: "(index \"The quick brown fox jumps over the lazy dog\" \"quick\")", Symbols: nil,
+		Args: []map[string]any{{}}, Func: String4, Want: []any{int64(4)}},
+	{Name: "StringArgs", Code: "(index \"The quick brown fox jumps over the lazy dog\" s)", Symbols: map[string]byte{"s": typeString},
+		Args: []map[string]any{{"s": "quick"}, {"s": ""}, {"s": "dog"}, {"s": "cat"}, {"s": "The quick brown fox jumps over the lazy dog"}}, Func: StringArgs, Want: []any{int64(4), int64(0), int64(40), int64(-1), int64(0)}},
+	{Name: "OpAdd", Code: "(+ x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpAdd},
+	{Name: "OpSub", Code: "(- x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpSub},
+	{Name: "OpMul", Code: "(* x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpMul},
+	{Name: "OpDiv", Code: "(/ x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpDiv},
+	{Name: "OpRem", Code: "(% x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpRem},
+	{Name: "OpMin", Code: "(min x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpMin},
+	{Name: "OpMax", Code: "(max x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpMax},
+	{Name: "OpLess", Code: "(< x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpLess},
+	{Name: "OpLessEqual", Code: "(<= x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpLessEqual},
+	{Name: "OpGreater", Code: "(> x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpGreater},
+	{Name: "OpGreaterEqual", Code: "(>= x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpGreaterEqual},
+	{Name: "OpEqual", Code: "(== x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpEqual},
+	{Name: "OpNotEqual", Code: "(!= x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpNotEqual},
+	{Name: "OpEqual1", Code: "(= x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpEqual1},
+	{Name: "OpAnd", Code: "(&& x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpAnd},
+	{Name: "OpOr", Code: "(|| x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpOr},
+	{Name: "OpCmpl", Code: "(cmpl x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpCmpl},
+	{Name: "OpCmpg", Code: "(cmpg x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpCmpg},
+	{Name: "OpAtan2", Code: "(atan2 x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpAtan2},
+	{Name: "OpPow", Code: "(pow x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpPow},
+	{Name: "OpPower", Code: "(** x y x y)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpPower},
+	{Name: "OpNeg", Code: "(- x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpNeg},
+	{Name: "OpNot", Code: "(! x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpNot},
+	{Name: "OpToBool", Code: "(->bool x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpToBool},
+	{Name: "OpAbs", Code: "(abs x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpAbs},
+	{Name: "OpSign", Code: "(sign x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpSign},
+	{Name: "OpAcos", Code: "(acos x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpAcos},
+	{Name: "OpAsin", Code: "(asin x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpAsin},
+	{Name: "OpAtan", Code: "(atan x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpAtan},
+	{Name: "OpCeil", Code: "(ceil x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpCeil},
+	{Name: "OpCos", Code: "(cos x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpCos},
+	{Name: "OpCosh", Code: "(cosh x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpCosh},
+	{Name: "OpExp", Code: "(exp x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpExp},
+	{Name: "OpFloor", Code: "(floor x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpFloor},
+	{Name: "OpLog", Code: "(log x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpLog},
+	{Name: "OpLog10", Code: "(log10 x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpLog10},
+	{Name: "OpRint", Code: "(rint x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpRint},
+	{Name: "OpRound", Code: "(round x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpRound},
+	{Name: "OpSin", Code: "(sin x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpSin},
+	{Name: "OpSinh", Code: "(sinh x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpSinh},
+	{Name: "OpSqrt", Code: "(sqrt x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpSqrt},
+	{Name: "OpTan", Code: "(tan x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpTan},
+	{Name: "OpTanh", Code: "(tanh x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpTanh},
+	{Name: "OpTrunc", Code: "(trunc x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpTrunc},
+	{Name: "OpIsNaN", Code: "(nan? x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpIsNaN},
+	{Name: "OpIsFinite", Code: "(finite? x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpIsFinite},
+	{Name: "OpIsInf", Code: "(inf? x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpIsInf},
+	{Name: "OpToInt", Code: "(->int x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpToInt},
+	{Name: "OpToUint", Code: "(->uint x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpToUint},
+	{Name: "OpToFloat", Code: "(->float x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpToFloat},
+	{Name: "OpToInt8", Code: "(->int8 x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpToInt8},
+	{Name: "OpToInt16", Code: "(->int16 x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpToInt16},
+	{Name: "OpToInt32", Code: "(->int32 x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpToInt32},
+	{Name: "OpToInt64", Code: "(->int64 x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpToInt64},
+	{Name: "OpToUint8", Code: "(->uint8 x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpToUint8},
+	{Name: "OpToUint16", Code: "(->uint16 x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpToUint16},
+	{Name: "OpToUint32", Code: "(->uint32 x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpToUint32},
+	{Name: "OpToUint64", Code: "(->uint64 x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpToUint64},
+	{Name: "OpToFloat32", Code: "(->float32 x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpToFloat32},
+	{Name: "OpToFloat64", Code: "(->float64 x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpToFloat64},
+	{Name: "OpToString", Code: "(->string x)", Symbols: xFloatYInt,
+		Args: xFloatYIntArgs, Func: OpToString},
+	{Name: "ShiftLeft", Code: "(<< i 3)", Symbols: map[string]byte{"i": typeInt},
+		Args: []map[string]any{{"i": int64(5)}}, Func: ShiftLeft, Want: []any{int64(40)}},
+	{Name: "ShiftRight", Code: "(>> i 2)", Symbols: map[string]byte{"i": typeInt},
+		Args: []map[string]any{{"i": int64(-9)}}, Func: ShiftRight, Want: []any{int64(-3)}},
+	{Name: "UnsignedShiftRight", Code: "(>>> i 60)", Symbols: map[string]byte{"i": typeInt},
+		Args: []map[string]any{{"i": int64(-1)}}, Func: UnsignedShiftRight, Want: []any{int64(15)}},
+	{Name: "Int8Add", Code: "(+ i8 1)", Symbols: map[string]byte{"i8": typeInt8},
+		Args: []map[string]any{{"i8": int8(127)}}, Func: Int8Add, Want: []any{int8(-128)}},
+	{Name: "Int8ShiftRight", Code: "(>>> i8 1)", Symbols: map[string]byte{"i8": typeInt8},
+		Args: []map[string]any{{"i8": int8(-128)}}, Func: Int8ShiftRight, Want: []any{int8(64)}},
+	{Name: "Int8ShiftLeft", Code: "(<< i8 i)", Symbols: map[string]byte{"i8": typeInt8, "i": typeInt},
+		Args: []map[string]any{{"i8": int8(1), "i": int64(7)}}, Func: Int8ShiftLeft, Want: []any{int8(-128)}},
+	{Name: "Int8ShiftTooFar", Code: "(>> i8 i)", Symbols: map[string]byte{"i8": typeInt8, "i": typeInt},
+		Args: []map[string]any{{"i8": int8(-128), "i": int64(7)}, {"i8": int8(1), "i": int64(8)}, {"i8": int8(1), "i": int64(31)}},
+		Func: Int8ShiftTooFar, Want: []any{int8(-1), nil, nil}},
+	{Name: "Int8UnsignedShiftRight", Code: "(>>> i8 i)", Symbols: map[string]byte{"i8": typeInt8, "i": typeInt},
+		Args: []map[string]any{{"i8": int8(-128), "i": int64(7)}, {"i8": int8(-1), "i": int64(4)}, {"i8": int8(-1), "i": int64(0)}, {"i8": int8(-1), "i": int64(8)}},
+		Func: Int8UnsignedShiftRight, Want: []any{int8(1), int8(15), int8(-1), nil}},
+	{Name: "Int8UnsignedShiftInt8", Code: "(>>> i8 n8)", Symbols: map[string]byte{"i8": typeInt8, "n8": typeInt8},
+		Args: []map[string]any{{"i8": int8(-1), "n8": int8(7)}, {"i8": int8(-1), "n8": int8(-1)}},
+		Func: Int8UnsignedShiftInt8, Want: []any{int8(1), nil}},
+	{Name: "UnsignedShiftRightFar", Code: "(>>> i n)", Symbols: map[string]byte{"i": typeInt, "n": typeInt},
+		Args: []map[string]any{{"i": int64(-1), "n": int64(63)}, {"i": int64(-1), "n": int64(64)}},
+		Func: UnsignedShiftRightFar, Want: []any{int64(1), nil}},
+	{Name: "Uint8Sub", Code: "(- u8 1)", Symbols: map[string]byte{"u8": typeUint8},
+		Args: []map[string]any{{"u8": uint8(0)}}, Func: Uint8Sub, Want: []any{uint8(255)}},
+	{Name: "Int8ToString", Code: "(->string i8)", Symbols: map[string]byte{"i8": typeInt8},
+		Args: []map[string]any{{"i8": int8(-8)}}, Func: Int8ToString, Want: []any{"-8"}},
+	{Name: "Uint64Div", Code: "(/ u64 3)", Symbols: map[string]byte{"u64": typeUint64},
+		Args: []map[string]any{{"u64": uint64(math.MaxUint64)}}, Func: Uint64Div, Want: []any{uint64(6148914691236517205)}},
+	{Name: "Float32Add", Code: "(+ f32 0.1)", Symbols: map[string]byte{"f32": typeFloat32},
+		Args: []map[string]any{{"f32": float32(0.2)}}, Func: Float32Add},
+	{Name: "DivideByZero", Code: "(+ 1\n  (/ i 0))", Symbols: map[string]byte{"i": typeInt},
+		Args: []map[string]any{{"i": int64(1)}}, Func: DivideByZero},
+	{Name: "ShiftTooFar", Code: "(<< i 64)", Symbols: map[string]byte{"i": typeInt},
+		Args: []map[string]any{{"i": int64(1)}}, Func: ShiftTooFar},
+	{Name: "DecimalMul", Code: "(* d 1.5d)", Symbols: map[string]byte{"d": typeDecimal},
+		Args: []map[string]any{{"d": decimal.Decimal(2_500_000)}}, Func: DecimalMul, Want: []any{decimal.Decimal(3_750_000)}},
+	{Name: "DecimalDiv", Code: "(/ d 3d)", Symbols: map[string]byte{"d": typeDecimal},
+		Args: []map[string]any{{"d": decimal.Decimal(1_000_000)}}, Func: DecimalDiv, Want: []any{decimal.Decimal(333_333)}},
+	{Name: "DecimalRound", Code: "(round-half-up d 1)", Symbols: map[string]byte{"d": typeDecimal},
+		Args: []map[string]any{{"d": decimal.Decimal(1_250_000)}}, Func: DecimalRound, Want: []any{decimal.Decimal(1_300_000)}},
+	{Name: "DecimalToString", Code: "(->string (- d 0.5d))", Symbols: map[string]byte{"d": typeDecimal},
+		Args: []map[string]any{{"d": decimal.Decimal(1_250_000)}}, Func: DecimalToString, Want: []any{"0.75"}},
+	{Name: "DecimalToInt", Code: "(->int d)", Symbols: map[string]byte{"d": typeDecimal},
+		Args: []map[string]any{{"d": decimal.Decimal(-1_750_000)}}, Func: DecimalToInt, Want: []any{int64(-1)}},
+	{Name: "DecimalFromFloat", Code: "(->decimal (* x 2))", Symbols: map[string]byte{"x": typeFloat},
+		Args: []map[string]any{{"x": 0.1234565}}, Func: DecimalFromFloat},
+	{Name: "DecimalMulOverflow", Code: "(* d d)", Symbols: map[string]byte{"d": typeDecimal},
+		Args: []map[string]any{{"d": decimal.Decimal(3_000_000)}, {"d": decimal.Decimal(math.MaxInt64 / 1000)}},
+		Func: DecimalMulOverflow, Want: []any{decimal.Decimal(9_000_000), nil}},
+	{Name: "DecimalFromIntOverflow", Code: "(->decimal y)", Symbols: map[string]byte{"y": typeInt},
+		Args: []map[string]any{{"y": int64(-7)}, {"y": int64(math.MaxInt64)}, {"y": int64(math.MinInt64)}},
+		Func: DecimalFromIntOverflow, Want: []any{decimal.Decimal(-7_000_000), nil, nil}},
+	{Name: "DecimalFromFloatOverflow", Code: "(->decimal x)", Symbols: map[string]byte{"x": typeFloat},
+		Args: []map[string]any{{"x": 0.5}, {"x": math.NaN()}, {"x": math.Inf(-1)}, {"x": 1e13}},
+		Func: DecimalFromFloatOverflow, Want: []any{decimal.Decimal(500_000), nil, nil, nil}},
+	{Name: "Hour", Code: "(hour t)", Symbols: map[string]byte{"t": typeTime},
+		Args: []map[string]any{{"t": time.Date(2026, 1, 1, 13, 0, 0, 0, time.UTC)}}, Func: Hour, Want: []any{int64(13)}},
+	{Name: "Weekday", Code: "(weekday t)", Symbols: map[string]byte{"t": typeTime},
+		Args: []map[string]any{{"t": time.Date(2026, 1, 4, 13, 0, 0, 0, time.UTC)}}, Func: Weekday, Want: []any{int64(0)}},
+	{Name: "AddDuration", Code: "(+ t 1h30m)", Symbols: map[string]byte{"t": typeTime},
+		Args: []map[string]any{{"t": time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}}, Func: AddDuration, Want: []any{time.Date(2026, 1, 1, 1, 30, 0, 0, time.UTC)}},
+	{Name: "Elapsed", Code: "(- t #t\"2026-01-01\")", Symbols: map[string]byte{"t": typeTime},
+		Args: []map[string]any{{"t": time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)}}, Func: Elapsed, Want: []any{24 * time.Hour}},
+	{Name: "Record", Code: "(> (get user \"age\") 18)", Symbols: map[string]byte{"user.age": typeInt},
+		Args: []map[string]any{{"user.age": int64(20)}}, Func: Record, Want: []any{true}},
+}
@@ -0,0 +1,1089 @@
+// Code generated by gruel gen-go. DO NOT EDIT.
+
+package golden
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yesh0/gruel/pkg/decimal"
+	"github.com/yesh0/gruel/pkg/gruelrt"
+)
+
+// Constant0 is generated from the rule:
+//
+//	1
+func Constant0() (int64, error) {
+	return int64(1), nil
+}
+
+// Constant1 is generated from the rule:
+//
+//	(+ 123000 456)
+func Constant1() (int64, error) {
+	v0 := int64(123000)
+	v1 := v0 + int64(456)
+	return v1, nil
+}
+
+// Constant2 is generated from the rule:
+//
+//	(- 123000 456)
+func Constant2() (int64, error) {
+	v0 := int64(123000)
+	v1 := v0 - int64(456)
+	return v1, nil
+}
+
+// Constant3 is generated from the rule:
+//
+//	(* 123 1000)
+func Constant3() (int64, error) {
+	v0 := int64(123)
+	v1 := v0 * int64(1000)
+	return v1, nil
+}
+
+// Constant4 is generated from the rule:
+//
+//	(/ 1230 10)
+func Constant4() (int64, error) {
+	v0 := int64(1230)
+	v1 := int64(10)
+	if v1 == 0 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultDivisionByZero, Operator: "/", Line: 1, Column: 1}
+	}
+	v2 := v0 / v1
+	return v2, nil
+}
+
+// Constant5 is generated from the rule:
+//
+//	(% 123 100)
+func Constant5() (int64, error) {
+	v0 := int64(123)
+	v1 := int64(100)
+	if v1 == 0 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultDivisionByZero, Operator: "%", Line: 1, Column: 1}
+	}
+	v2 := v0 % v1
+	return v2, nil
+}
+
+// Constant6 is generated from the rule:
+//
+//	(/ 31536000. 365 24 60 60)
+func Constant6() (float64, error) {
+	v0 := float64(3.1536e+07)
+	v1 := int64(365)
+	if v1 == 0 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultDivisionByZero, Operator: "/", Line: 1, Column: 1}
+	}
+	v2 := v0 / float64(v1)
+	v3 := int64(24)
+	if v3 == 0 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultDivisionByZero, Operator: "/", Line: 1, Column: 1}
+	}
+	v4 := v2 / float64(v3)
+	v5 := int64(60)
+	if v5 == 0 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultDivisionByZero, Operator: "/", Line: 1, Column: 1}
+	}
+	v6 := v4 / float64(v5)
+	v7 := int64(60)
+	if v7 == 0 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultDivisionByZero, Operator: "/", Line: 1, Column: 1}
+	}
+	v8 := v6 / float64(v7)
+	return v8, nil
+}
+
+// Constant7 is generated from the rule:
+//
+//	(+ true true)
+func Constant7() (int64, error) {
+	v0 := true
+	v1 := gruelrt.Int(v0) + gruelrt.Int(true)
+	return v1, nil
+}
+
+// Constant8 is generated from the rule:
+//
+//	(+ true false)
+func Constant8() (int64, error) {
+	v0 := true
+	v1 := gruelrt.Int(v0) + gruelrt.Int(false)
+	return v1, nil
+}
+
+// Constant9 is generated from the rule:
+//
+//	(+ 1.23 0.00456)
+func Constant9() (float64, error) {
+	v0 := float64(1.23)
+	v1 := v0 + float64(0.00456)
+	return v1, nil
+}
+
+// Constant10 is generated from the rule:
+//
+//	(/ 10 0.5)
+func Constant10() (float64, error) {
+	v0 := int64(10)
+	v1 := float64(0.5)
+	v2 := float64(v0) / v1
+	return v2, nil
+}
+
+// Constant11 is generated from the rule:
+//
+//	(+ (- (* (/ 4 (% 6 5)) 3) 2) 1)
+func Constant11() (int64, error) {
+	v0 := int64(6)
+	v1 := int64(5)
+	if v1 == 0 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultDivisionByZero, Operator: "%", Line: 1, Column: 15}
+	}
+	v2 := v0 % v1
+	if v2 == 0 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultDivisionByZero, Operator: "/", Line: 1, Column: 10}
+	}
+	v3 := int64(4) / v2
+	v4 := v3 * int64(3)
+	v5 := v4 - int64(2)
+	v6 := v5 + int64(1)
+	return v6, nil
+}
+
+// Args is generated from the rule:
+//
+//	(+ (* 2 x) (% y 9))
+func Args(x float64, y int64) (float64, error) {
+	v0 := int64(9)
+	if v0 == 0 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultDivisionByZero, Operator: "%", Line: 1, Column: 12}
+	}
+	v1 := y % v0
+	v2 := float64(int64(2)) * x
+	v3 := v2 + float64(v1)
+	return v3, nil
+}
+
+// MoreArgs is generated from the rule:
+//
+//	(+ f (+ e (+ d (+ c (+ a b)))))
+func MoreArgs(a float64, b float64, c float64, d float64, e float64, f float64) (float64, error) {
+	v0 := a + b
+	v1 := c + v0
+	v2 := d + v1
+	v3 := e + v2
+	v4 := f + v3
+	return v4, nil
+}
+
+// String0 is generated from the rule:
+//
+//	(len "Hello")
+func String0() (int64, error) {
+	v0 := "Hello"
+	v1 := int64(len(v0))
+	return v1, nil
+}
+
+// String1 is generated from the rule:
+//
+//	(== "Hello" "Hello")
+func String1() (bool, error) {
+	v0 := "Hello"
+	v1 := v0 == "Hello"
+	return v1, nil
+}
+
+// String2 is generated from the rule:
+//
+//	(== "Hello" "hello")
+func String2() (bool, error) {
+	v0 := "Hello"
+	v1 := v0 == "hello"
+	return v1, nil
+}
+
+// String3 is generated from the rule:
+//
+//	(== "1" 1)
+func String3() (bool, error) {
+	v0 := "1"
+	_ = v0
+	v1 := false
+	return v1, nil
+}
+
+// String4 is generated from the rule:
+//
+//	(index "The quick brown fox jumps over the lazy dog" "quick")
+func String4() (int64, error) {
+	v0 := "The quick brown fox jumps over the lazy dog"
+	v1 := int64(strings.Index(v0, "quick"))
+	return v1, nil
+}
+
+// StringArgs is generated from the rule:
+//
+//	(index "The quick brown fox jumps over the lazy dog" s)
+func StringArgs(s string) (int64, error) {
+	v0 := int64(strings.Index("The quick brown fox jumps over the lazy dog", s))
+	return v0, nil
+}
+
+// OpAdd is generated from the rule:
+//
+//	(+ x y x y)
+func OpAdd(x float64, y int64) (float64, error) {
+	v0 := x + float64(y)
+	v1 := v0 + x
+	v2 := v1 + float64(y)
+	return v2, nil
+}
+
+// OpSub is generated from the rule:
+//
+//	(- x y x y)
+func OpSub(x float64, y int64) (float64, error) {
+	v0 := x - float64(y)
+	v1 := v0 - x
+	v2 := v1 - float64(y)
+	return v2, nil
+}
+
+// OpMul is generated from the rule:
+//
+//	(* x y x y)
+func OpMul(x float64, y int64) (float64, error) {
+	v0 := x * float64(y)
+	v1 := v0 * x
+	v2 := v1 * float64(y)
+	return v2, nil
+}
+
+// OpDiv is generated from the rule:
+//
+//	(/ x y x y)
+func OpDiv(x float64, y int64) (float64, error) {
+	if y == 0 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultDivisionByZero, Operator: "/", Line: 1, Column: 1}
+	}
+	v0 := x / float64(y)
+	v1 := v0 / x
+	if y == 0 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultDivisionByZero, Operator: "/", Line: 1, Column: 1}
+	}
+	v2 := v1 / float64(y)
+	return v2, nil
+}
+
+// OpRem is generated from the rule:
+//
+//	(% x y x y)
+func OpRem(x float64, y int64) (float64, error) {
+	if y == 0 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultDivisionByZero, Operator: "%", Line: 1, Column: 1}
+	}
+	v0 := math.Mod(x, float64(y))
+	v1 := math.Mod(v0, x)
+	if y == 0 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultDivisionByZero, Operator: "%", Line: 1, Column: 1}
+	}
+	v2 := math.Mod(v1, float64(y))
+	return v2, nil
+}
+
+// OpMin is generated from the rule:
+//
+//	(min x y x y)
+func OpMin(x float64, y int64) (float64, error) {
+	v0 := gruelrt.Min(x, float64(y))
+	v1 := gruelrt.Min(v0, x)
+	v2 := gruelrt.Min(v1, float64(y))
+	return v2, nil
+}
+
+// OpMax is generated from the rule:
+//
+//	(max x y x y)
+func OpMax(x float64, y int64) (float64, error) {
+	v0 := gruelrt.Max(x, float64(y))
+	v1 := gruelrt.Max(v0, x)
+	v2 := gruelrt.Max(v1, float64(y))
+	return v2, nil
+}
+
+// OpLess is generated from the rule:
+//
+//	(< x y x y)
+func OpLess(x float64, y int64) (bool, error) {
+	v0 := x < float64(y)
+	v1 := float64(gruelrt.Int(v0)) < x
+	v2 := gruelrt.Int(v1) < y
+	return v2, nil
+}
+
+// OpLessEqual is generated from the rule:
+//
+//	(<= x y x y)
+func OpLessEqual(x float64, y int64) (bool, error) {
+	v0 := x <= float64(y)
+	v1 := float64(gruelrt.Int(v0)) <= x
+	v2 := gruelrt.Int(v1) <= y
+	return v2, nil
+}
+
+// OpGreater is generated from the rule:
+//
+//	(> x y x y)
+func OpGreater(x float64, y int64) (bool, error) {
+	v0 := x > float64(y)
+	v1 := float64(gruelrt.Int(v0)) > x
+	v2 := gruelrt.Int(v1) > y
+	return v2, nil
+}
+
+// OpGreaterEqual is generated from the rule:
+//
+//	(>= x y x y)
+func OpGreaterEqual(x float64, y int64) (bool, error) {
+	v0 := x >= float64(y)
+	v1 := float64(gruelrt.Int(v0)) >= x
+	v2 := gruelrt.Int(v1) >= y
+	return v2, nil
+}
+
+// OpEqual is generated from the rule:
+//
+//	(== x y x y)
+func OpEqual(x float64, y int64) (bool, error) {
+	v0 := x == float64(y)
+	v1 := float64(gruelrt.Int(v0)) == x
+	v2 := gruelrt.Int(v1) == y
+	return v2, nil
+}
+
+// OpNotEqual is generated from the rule:
+//
+//	(!= x y x y)
+func OpNotEqual(x float64, y int64) (bool, error) {
+	v0 := x != float64(y)
+	v1 := float64(gruelrt.Int(v0)) != x
+	v2 := gruelrt.Int(v1) != y
+	return v2, nil
+}
+
+// OpEqual1 is generated from the rule:
+//
+//	(= x y x y)
+func OpEqual1(x float64, y int64) (bool, error) {
+	v0 := x == float64(y)
+	v1 := float64(gruelrt.Int(v0)) == x
+	v2 := gruelrt.Int(v1) == y
+	return v2, nil
+}
+
+// OpAnd is generated from the rule:
+//
+//	(&& x y x y)
+func OpAnd(x float64, y int64) (bool, error) {
+	v0 := (x != 0) && (y != 0)
+	v1 := v0 && (x != 0)
+	v2 := v1 && (y != 0)
+	return v2, nil
+}
+
+// OpOr is generated from the rule:
+//
+//	(|| x y x y)
+func OpOr(x float64, y int64) (bool, error) {
+	v0 := (x != 0) || (y != 0)
+	v1 := v0 || (x != 0)
+	v2 := v1 || (y != 0)
+	return v2, nil
+}
+
+// OpCmpl is generated from the rule:
+//
+//	(cmpl x y x y)
+func OpCmpl(x float64, y int64) (int64, error) {
+	v0 := gruelrt.Cmpl(x, float64(y))
+	v1 := gruelrt.Cmpl(float64(v0), x)
+	v2 := gruelrt.Cmpl(v1, y)
+	return v2, nil
+}
+
+// OpCmpg is generated from the rule:
+//
+//	(cmpg x y x y)
+func OpCmpg(x float64, y int64) (int64, error) {
+	v0 := gruelrt.Cmpg(x, float64(y))
+	v1 := gruelrt.Cmpg(float64(v0), x)
+	v2 := gruelrt.Cmpg(v1, y)
+	return v2, nil
+}
+
+// OpAtan2 is generated from the rule:
+//
+//	(atan2 x y x y)
+func OpAtan2(x float64, y int64) (float64, error) {
+	v0 := math.Atan2(x, float64(y))
+	v1 := math.Atan2(v0, x)
+	v2 := math.Atan2(v1, float64(y))
+	return v2, nil
+}
+
+// OpPow is generated from the rule:
+//
+//	(pow x y x y)
+func OpPow(x float64, y int64) (float64, error) {
+	v0 := math.Pow(x, float64(y))
+	v1 := math.Pow(v0, x)
+	v2 := math.Pow(v1, float64(y))
+	return v2, nil
+}
+
+// OpPower is generated from the rule:
+//
+//	(** x y x y)
+func OpPower(x float64, y int64) (float64, error) {
+	v0 := math.Pow(x, float64(y))
+	v1 := math.Pow(v0, x)
+	v2 := math.Pow(v1, float64(y))
+	return v2, nil
+}
+
+// OpNeg is generated from the rule:
+//
+//	(- x)
+func OpNeg(x float64, y int64) (float64, error) {
+	v0 := -x
+	return v0, nil
+}
+
+// OpNot is generated from the rule:
+//
+//	(! x)
+func OpNot(x float64, y int64) (bool, error) {
+	v0 := x == 0
+	return v0, nil
+}
+
+// OpToBool is generated from the rule:
+//
+//	(->bool x)
+func OpToBool(x float64, y int64) (bool, error) {
+	v0 := (x != 0)
+	return v0, nil
+}
+
+// OpAbs is generated from the rule:
+//
+//	(abs x)
+func OpAbs(x float64, y int64) (float64, error) {
+	v0 := math.Abs(x)
+	return v0, nil
+}
+
+// OpSign is generated from the rule:
+//
+//	(sign x)
+func OpSign(x float64, y int64) (int64, error) {
+	v0 := gruelrt.Sign(x)
+	return v0, nil
+}
+
+// OpAcos is generated from the rule:
+//
+//	(acos x)
+func OpAcos(x float64, y int64) (float64, error) {
+	v0 := math.Acos(x)
+	return v0, nil
+}
+
+// OpAsin is generated from the rule:
+//
+//	(asin x)
+func OpAsin(x float64, y int64) (float64, error) {
+	v0 := math.Asin(x)
+	return v0, nil
+}
+
+// OpAtan is generated from the rule:
+//
+//	(atan x)
+func OpAtan(x float64, y int64) (float64, error) {
+	v0 := math.Atan(x)
+	return v0, nil
+}
+
+// OpCeil is generated from the rule:
+//
+//	(ceil x)
+func OpCeil(x float64, y int64) (float64, error) {
+	v0 := math.Ceil(x)
+	return v0, nil
+}
+
+// OpCos is generated from the rule:
+//
+//	(cos x)
+func OpCos(x float64, y int64) (float64, error) {
+	v0 := math.Cos(x)
+	return v0, nil
+}
+
+// OpCosh is generated from the rule:
+//
+//	(cosh x)
+func OpCosh(x float64, y int64) (float64, error) {
+	v0 := math.Cosh(x)
+	return v0, nil
+}
+
+// OpExp is generated from the rule:
+//
+//	(exp x)
+func OpExp(x float64, y int64) (float64, error) {
+	v0 := math.Exp(x)
+	return v0, nil
+}
+
+// OpFloor is generated from the rule:
+//
+//	(floor x)
+func OpFloor(x float64, y int64) (float64, error) {
+	v0 := math.Floor(x)
+	return v0, nil
+}
+
+// OpLog is generated from the rule:
+//
+//	(log x)
+func OpLog(x float64, y int64) (float64, error) {
+	v0 := math.Log(x)
+	return v0, nil
+}
+
+// OpLog10 is generated from the rule:
+//
+//	(log10 x)
+func OpLog10(x float64, y int64) (float64, error) {
+	v0 := math.Log10(x)
+	return v0, nil
+}
+
+// OpRint is generated from the rule:
+//
+//	(rint x)
+func OpRint(x float64, y int64) (float64, error) {
+	v0 := math.RoundToEven(x)
+	return v0, nil
+}
+
+// OpRound is generated from the rule:
+//
+//	(round x)
+func OpRound(x float64, y int64) (float64, error) {
+	v0 := math.Round(x)
+	return v0, nil
+}
+
+// OpSin is generated from the rule:
+//
+//	(sin x)
+func OpSin(x float64, y int64) (float64, error) {
+	v0 := math.Sin(x)
+	return v0, nil
+}
+
+// OpSinh is generated from the rule:
+//
+//	(sinh x)
+func OpSinh(x float64, y int64) (float64, error) {
+	v0 := math.Sinh(x)
+	return v0, nil
+}
+
+// OpSqrt is generated from the rule:
+//
+//	(sqrt x)
+func OpSqrt(x float64, y int64) (float64, error) {
+	v0 := math.Sqrt(x)
+	return v0, nil
+}
+
+// OpTan is generated from the rule:
+//
+//	(tan x)
+func OpTan(x float64, y int64) (float64, error) {
+	v0 := math.Tan(x)
+	return v0, nil
+}
+
+// OpTanh is generated from the rule:
+//
+//	(tanh x)
+func OpTanh(x float64, y int64) (float64, error) {
+	v0 := math.Tanh(x)
+	return v0, nil
+}
+
+// OpTrunc is generated from the rule:
+//
+//	(trunc x)
+func OpTrunc(x float64, y int64) (float64, error) {
+	v0 := math.Trunc(x)
+	return v0, nil
+}
+
+// OpIsNaN is generated from the rule:
+//
+//	(nan? x)
+func OpIsNaN(x float64, y int64) (bool, error) {
+	v0 := math.IsNaN(x)
+	return v0, nil
+}
+
+// OpIsFinite is generated from the rule:
+//
+//	(finite? x)
+func OpIsFinite(x float64, y int64) (bool, error) {
+	v0 := !math.IsNaN(x) && !math.IsInf(x, 0)
+	return v0, nil
+}
+
+// OpIsInf is generated from the rule:
+//
+//	(inf? x)
+func OpIsInf(x float64, y int64) (bool, error) {
+	v0 := math.IsInf(x, 0)
+	return v0, nil
+}
+
+// OpToInt is generated from the rule:
+//
+//	(->int x)
+func OpToInt(x float64, y int64) (int64, error) {
+	v0 := int64(x)
+	return v0, nil
+}
+
+// OpToUint is generated from the rule:
+//
+//	(->uint x)
+func OpToUint(x float64, y int64) (uint64, error) {
+	v0 := uint64(x)
+	return v0, nil
+}
+
+// OpToFloat is generated from the rule:
+//
+//	(->float x)
+func OpToFloat(x float64, y int64) (float64, error) {
+	return x, nil
+}
+
+// OpToInt8 is generated from the rule:
+//
+//	(->int8 x)
+func OpToInt8(x float64, y int64) (int8, error) {
+	v0 := int8(int64(x))
+	return v0, nil
+}
+
+// OpToInt16 is generated from the rule:
+//
+//	(->int16 x)
+func OpToInt16(x float64, y int64) (int16, error) {
+	v0 := int16(int64(x))
+	return v0, nil
+}
+
+// OpToInt32 is generated from the rule:
+//
+//	(->int32 x)
+func OpToInt32(x float64, y int64) (int32, error) {
+	v0 := int32(int64(x))
+	return v0, nil
+}
+
+// OpToInt64 is generated from the rule:
+//
+//	(->int64 x)
+func OpToInt64(x float64, y int64) (int64, error) {
+	v0 := int64(x)
+	return v0, nil
+}
+
+// OpToUint8 is generated from the rule:
+//
+//	(->uint8 x)
+func OpToUint8(x float64, y int64) (uint8, error) {
+	v0 := uint8(int64(x))
+	return v0, nil
+}
+
+// OpToUint16 is generated from the rule:
+//
+//	(->uint16 x)
+func OpToUint16(x float64, y int64) (uint16, error) {
+	v0 := uint16(int64(x))
+	return v0, nil
+}
+
+// OpToUint32 is generated from the rule:
+//
+//	(->uint32 x)
+func OpToUint32(x float64, y int64) (uint32, error) {
+	v0 := uint32(int64(x))
+	return v0, nil
+}
+
+// OpToUint64 is generated from the rule:
+//
+//	(->uint64 x)
+func OpToUint64(x float64, y int64) (uint64, error) {
+	v0 := uint64(x)
+	return v0, nil
+}
+
+// OpToFloat32 is generated from the rule:
+//
+//	(->float32 x)
+func OpToFloat32(x float64, y int64) (float32, error) {
+	v0 := float32(x)
+	return v0, nil
+}
+
+// OpToFloat64 is generated from the rule:
+//
+//	(->float64 x)
+func OpToFloat64(x float64, y int64) (float64, error) {
+	return x, nil
+}
+
+// OpToString is generated from the rule:
+//
+//	(->string x)
+func OpToString(x float64, y int64) (string, error) {
+	v0 := strconv.FormatFloat(x, 'g', -1, 64)
+	return v0, nil
+}
+
+// ShiftLeft is generated from the rule:
+//
+//	(<< i 3)
+func ShiftLeft(i int64) (int64, error) {
+	v0 := int64(3)
+	if uint64(v0) >= 64 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultShift, Operator: "<<", Line: 1, Column: 1}
+	}
+	v1 := i << uint64(v0)
+	return v1, nil
+}
+
+// ShiftRight is generated from the rule:
+//
+//	(>> i 2)
+func ShiftRight(i int64) (int64, error) {
+	v0 := int64(2)
+	if uint64(v0) >= 64 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultShift, Operator: ">>", Line: 1, Column: 1}
+	}
+	v1 := i >> uint64(v0)
+	return v1, nil
+}
+
+// UnsignedShiftRight is generated from the rule:
+//
+//	(>>> i 60)
+func UnsignedShiftRight(i int64) (int64, error) {
+	v0 := int64(60)
+	if uint64(v0) >= 64 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultShift, Operator: ">>>", Line: 1, Column: 1}
+	}
+	v1 := int64(uint64(i) >> uint64(v0))
+	return v1, nil
+}
+
+// Int8Add is generated from the rule:
+//
+//	(+ i8 1)
+func Int8Add(i8 int8) (int8, error) {
+	v0 := i8 + int8(1)
+	return v0, nil
+}
+
+// Int8ShiftRight is generated from the rule:
+//
+//	(>>> i8 1)
+func Int8ShiftRight(i8 int8) (int8, error) {
+	v0 := int64(1)
+	if uint64(v0) >= 8 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultShift, Operator: ">>>", Line: 1, Column: 1}
+	}
+	v1 := int8(uint8(i8) >> uint64(v0))
+	return v1, nil
+}
+
+// Int8ShiftLeft is generated from the rule:
+//
+//	(<< i8 i)
+func Int8ShiftLeft(i int64, i8 int8) (int8, error) {
+	if uint64(i) >= 8 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultShift, Operator: "<<", Line: 1, Column: 1}
+	}
+	v0 := i8 << uint64(i)
+	return v0, nil
+}
+
+// Int8ShiftTooFar is generated from the rule:
+//
+//	(>> i8 i)
+func Int8ShiftTooFar(i int64, i8 int8) (int8, error) {
+	if uint64(i) >= 8 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultShift, Operator: ">>", Line: 1, Column: 1}
+	}
+	v0 := i8 >> uint64(i)
+	return v0, nil
+}
+
+// Int8UnsignedShiftRight is generated from the rule:
+//
+//	(>>> i8 i)
+func Int8UnsignedShiftRight(i int64, i8 int8) (int8, error) {
+	if uint64(i) >= 8 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultShift, Operator: ">>>", Line: 1, Column: 1}
+	}
+	v0 := int8(uint8(i8) >> uint64(i))
+	return v0, nil
+}
+
+// Int8UnsignedShiftInt8 is generated from the rule:
+//
+//	(>>> i8 n8)
+func Int8UnsignedShiftInt8(i8 int8, n8 int8) (int8, error) {
+	if uint64(n8) >= 8 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultShift, Operator: ">>>", Line: 1, Column: 1}
+	}
+	v0 := int8(uint8(i8) >> uint64(n8))
+	return v0, nil
+}
+
+// UnsignedShiftRightFar is generated from the rule:
+//
+//	(>>> i n)
+func UnsignedShiftRightFar(i int64, n int64) (int64, error) {
+	if uint64(n) >= 64 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultShift, Operator: ">>>", Line: 1, Column: 1}
+	}
+	v0 := int64(uint64(i) >> uint64(n))
+	return v0, nil
+}
+
+// Uint8Sub is generated from the rule:
+//
+//	(- u8 1)
+func Uint8Sub(u8 uint8) (uint8, error) {
+	v0 := u8 - uint8(1)
+	return v0, nil
+}
+
+// Int8ToString is generated from the rule:
+//
+//	(->string i8)
+func Int8ToString(i8 int8) (string, error) {
+	v0 := strconv.FormatInt(int64(i8), 10)
+	return v0, nil
+}
+
+// Uint64Div is generated from the rule:
+//
+//	(/ u64 3)
+func Uint64Div(u64 uint64) (uint64, error) {
+	v0 := uint64(3)
+	if v0 == 0 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultDivisionByZero, Operator: "/", Line: 1, Column: 1}
+	}
+	v1 := u64 / v0
+	return v1, nil
+}
+
+// Float32Add is generated from the rule:
+//
+//	(+ f32 0.1)
+func Float32Add(f32 float32) (float32, error) {
+	v0 := f32 + float32(float64(0.1))
+	return v0, nil
+}
+
+// DivideByZero is generated from the rule:
+//
+//	(+ 1
+//	  (/ i 0))
+func DivideByZero(i int64) (int64, error) {
+	v0 := int64(0)
+	if v0 == 0 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultDivisionByZero, Operator: "/", Line: 2, Column: 3}
+	}
+	v1 := i / v0
+	v2 := int64(1) + v1
+	return v2, nil
+}
+
+// ShiftTooFar is generated from the rule:
+//
+//	(<< i 64)
+func ShiftTooFar(i int64) (int64, error) {
+	v0 := int64(64)
+	if uint64(v0) >= 64 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultShift, Operator: "<<", Line: 1, Column: 1}
+	}
+	v1 := i << uint64(v0)
+	return v1, nil
+}
+
+// DecimalMul is generated from the rule:
+//
+//	(* d 1.5d)
+func DecimalMul(d decimal.Decimal) (decimal.Decimal, error) {
+	d0, err := decimal.Decimal(int64(d)).Mul(decimal.Decimal(int64(1500000)), decimal.HalfEven)
+	if err != nil {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultOverflow, Operator: "*", Line: 1, Column: 1}
+	}
+	v0 := int64(d0)
+	return decimal.Decimal(v0), nil
+}
+
+// DecimalDiv is generated from the rule:
+//
+//	(/ d 3d)
+func DecimalDiv(d decimal.Decimal) (decimal.Decimal, error) {
+	v0 := int64(3000000)
+	if v0 == 0 {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultDivisionByZero, Operator: "/", Line: 1, Column: 1}
+	}
+	v1 := int64(decimal.Decimal(int64(d)).Div(decimal.Decimal(v0), decimal.HalfEven))
+	return decimal.Decimal(v1), nil
+}
+
+// DecimalRound is generated from the rule:
+//
+//	(round-half-up d 1)
+func DecimalRound(d decimal.Decimal) (decimal.Decimal, error) {
+	v0 := int64(decimal.Decimal(int64(d)).Round(int64(1), decimal.HalfUp))
+	return decimal.Decimal(v0), nil
+}
+
+// DecimalToString is generated from the rule:
+//
+//	(->string (- d 0.5d))
+func DecimalToString(d decimal.Decimal) (string, error) {
+	v0 := int64(d) - int64(500000)
+	v1 := decimal.Decimal(v0).String()
+	return v1, nil
+}
+
+// DecimalToInt is generated from the rule:
+//
+//	(->int d)
+func DecimalToInt(d decimal.Decimal) (int64, error) {
+	v0 := int64(d) / decimal.Scale
+	return v0, nil
+}
+
+// DecimalFromFloat is generated from the rule:
+//
+//	(->decimal (* x 2))
+func DecimalFromFloat(x float64) (decimal.Decimal, error) {
+	v0 := x * float64(int64(2))
+	d1, err := decimal.FromFloat(v0)
+	if err != nil {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultOverflow, Operator: "->decimal", Line: 1, Column: 1}
+	}
+	v1 := int64(d1)
+	return decimal.Decimal(v1), nil
+}
+
+// DecimalMulOverflow is generated from the rule:
+//
+//	(* d d)
+func DecimalMulOverflow(d decimal.Decimal) (decimal.Decimal, error) {
+	d0, err := decimal.Decimal(int64(d)).Mul(decimal.Decimal(int64(d)), decimal.HalfEven)
+	if err != nil {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultOverflow, Operator: "*", Line: 1, Column: 1}
+	}
+	v0 := int64(d0)
+	return decimal.Decimal(v0), nil
+}
+
+// DecimalFromIntOverflow is generated from the rule:
+//
+//	(->decimal y)
+func DecimalFromIntOverflow(y int64) (decimal.Decimal, error) {
+	d0, err := decimal.FromInt(y)
+	if err != nil {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultOverflow, Operator: "->decimal", Line: 1, Column: 1}
+	}
+	v0 := int64(d0)
+	return decimal.Decimal(v0), nil
+}
+
+// DecimalFromFloatOverflow is generated from the rule:
+//
+//	(->decimal x)
+func DecimalFromFloatOverflow(x float64) (decimal.Decimal, error) {
+	d0, err := decimal.FromFloat(x)
+	if err != nil {
+		return 0, &gruelrt.RuntimeError{Fault: gruelrt.FaultOverflow, Operator: "->decimal", Line: 1, Column: 1}
+	}
+	v0 := int64(d0)
+	return decimal.Decimal(v0), nil
+}
+
+// Hour is generated from the rule:
+//
+//	(hour t)
+func Hour(t time.Time) (int64, error) {
+	v0 := gruelrt.Hour(t.UnixNano(), time.UTC)
+	return v0, nil
+}
+
+// Weekday is generated from the rule:
+//
+//	(weekday t)
+func Weekday(t time.Time) (int64, error) {
+	v0 := gruelrt.Weekday(t.UnixNano(), time.UTC)
+	return v0, nil
+}
+
+// AddDuration is generated from the rule:
+//
+//	(+ t 1h30m)
+func AddDuration(t time.Time) (time.Time, error) {
+	v0 := t.UnixNano() + int64(5400000000000)
+	return gruelrt.Time(v0, time.UTC), nil
+}
+
+// Elapsed is generated from the rule:
+//
+//	(- t #t"2026-01-01")
+func Elapsed(t time.Time) (time.Duration, error) {
+	v0 := t.UnixNano() - int64(1767225600000000000)
+	return time.Duration(v0), nil
+}
+
+// Record is generated from the rule:
+//
+//	(> (get user "age") 18)
+func Record(user_age int64) (bool, error) {
+	v0 := user_age > int64(18)
+	return v0, nil
+}
@@ -0,0 +1,90 @@
+package gogen
+
+import (
+	"fmt"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+)
+
+const (
+	typeBool     = gruelparser.TypeBool
+	typeInt      = gruelparser.TypeInt
+	typeFloat    = gruelparser.TypeFloat
+	typeString   = gruelparser.TypeString
+	typeTime     = gruelparser.TypeTime
+	typeDuration = gruelparser.TypeDuration
+	typeDecimal  = gruelparser.TypeDecimal
+	typeInt8     = gruelparser.TypeInt8
+	typeInt16    = gruelparser.TypeInt16
+	typeInt32    = gruelparser.TypeInt32
+	typeUint8    = gruelparser.TypeUint8
+	typeUint16   = gruelparser.TypeUint16
+	typeUint32   = gruelparser.TypeUint32
+	typeUint64   = gruelparser.TypeUint64
+	typeFloat32  = gruelparser.TypeFloat32
+)
+
+// Go types of values in generated code
+//
+// Timestamps, durations and decimals are kept as raw integers
+// and only converted for parameters and results.
+var goTypes = map[gruelparser.TokenType]string{
+	typeBool:     "bool",
+	typeInt:      "int64",
+	typeFloat:    "float64",
+	typeString:   "string",
+	typeTime:     "int64",
+	typeDuration: "int64",
+	typeDecimal:  "int64",
+	typeInt8:     "int8",
+	typeInt16:    "int16",
+	typeInt32:    "int32",
+	typeUint8:    "uint8",
+	typeUint16:   "uint16",
+	typeUint32:   "uint32",
+	typeUint64:   "uint64",
+	typeFloat32:  "float32",
+}
+
+func goType(t gruelparser.TokenType) string {
+	return goTypes[t]
+}
+
+func isSized(t gruelparser.TokenType) bool {
+	return typeInt8 <= t && t <= typeFloat32
+}
+
+func isInteger(t gruelparser.TokenType) bool {
+	return t == typeInt || isSized(t) && t != typeFloat32
+}
+
+func isUnsigned(t gruelparser.TokenType) bool {
+	return typeUint8 <= t && t <= typeUint64
+}
+
+func isOpaque(t gruelparser.TokenType) bool {
+	return t == typeTime || t == typeDuration || t == typeDecimal
+}
+
+func typeName(t gruelparser.TokenType) string {
+	switch {
+	case t == typeBool:
+		return "bool"
+	case t == typeInt:
+		return "int"
+	case t == typeFloat:
+		return "float"
+	case t == typeString:
+		return "string"
+	case t == typeTime:
+		return "time"
+	case t == typeDuration:
+		return "duration"
+	case t == typeDecimal:
+		return "decimal"
+	case isSized(t):
+		return goType(t)
+	default:
+		return fmt.Sprintf("type %d", t)
+	}
+}
@@ -0,0 +1,197 @@
+package gruelparser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Classifies a numeric literal, checking it against the grammar:
+//
+//	number   = [sign] (decimal | "0x" hex | "0o" octal | "0b" binary | "inf" | "nan") [suffix]
+//	decimal  = digits ["." [digits]] [("e" | "E") [sign] digits] | "." digits [exponent]
+//	hex      = hexdigits ["." [hexdigits]] [("p" | "P") [sign] digits]
+//	suffix   = "i" (int) | "u" (uint64) | "f" (float) | "d" (decimal)
+//
+// Digits may be separated by single underscores like in Go, and literals
+// with fractions or exponents are floats unless suffixed with d.
+func lexNumber(token string) (TokenType, error) {
+	invalid := fmt.Errorf("invalid number %s", token)
+	s := token
+	negative := s[0] == '-'
+	if s[0] == '-' || s[0] == '+' {
+		s = s[1:]
+	}
+	if s == "inf" || s == "nan" {
+		return TypeFloat, nil
+	}
+	if s == "" {
+		return 0, invalid
+	}
+	base := 10
+	if len(s) > 1 && s[0] == '0' {
+		switch s[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+	}
+	var suffix byte
+	switch last := s[len(s)-1]; {
+	case last == 'i' || last == 'u':
+		suffix = last
+	case (last == 'f' || last == 'd') && base != 16:
+		// Both are hex digits.
+		suffix = last
+	}
+	if suffix != 0 {
+		s = s[:len(s)-1]
+	}
+	if !underscoresOK(s, base) {
+		return 0, invalid
+	}
+	s = strings.ReplaceAll(s, "_", "")
+
+	var float, exponent bool
+	if base == 10 {
+		mantissa := s
+		if i := strings.IndexAny(s, "eE"); i >= 0 {
+			mantissa, exponent = s[:i], true
+			if !isExponent(s[i+1:]) {
+				return 0, invalid
+			}
+		}
+		whole, fraction, dot := strings.Cut(mantissa, ".")
+		if !isDigits(whole, 10, true) || !isDigits(fraction, 10, true) || whole+fraction == "" {
+			return 0, invalid
+		}
+		float = dot || exponent
+	} else {
+		mantissa := s[2:]
+		if i := strings.IndexAny(mantissa, "pP"); i >= 0 && base == 16 {
+			mantissa, exponent = mantissa[:i], true
+			if !isExponent(s[2+i+1:]) {
+				return 0, invalid
+			}
+		}
+		whole, fraction, dot := strings.Cut(mantissa, ".")
+		// Hex floats need exponents, like in Go.
+		if (dot || exponent) && (base != 16 || !exponent) ||
+			!isDigits(whole, base, true) || !isDigits(fraction, base, true) || whole+fraction == "" {
+			return 0, invalid
+		}
+		float = exponent
+	}
+
+	var t TokenType
+	switch {
+	case suffix == 'd':
+		if base != 10 || exponent {
+			return 0, invalid
+		}
+		return TypeDecimal, nil
+	case suffix == 'f' || suffix == 0 && float:
+		t = TypeFloat
+	case float:
+		return 0, fmt.Errorf("%s is not an integer", token)
+	case suffix == 'u':
+		if negative {
+			return 0, fmt.Errorf("unsigned %s is negative", token)
+		}
+		t = TypeUint64
+	default:
+		t = TypeInt
+	}
+	var err error
+	if t == TypeFloat {
+		_, err = ParseFloat(token)
+	} else {
+		_, err = ParseInt(token)
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("number %s out of range", token)
+	} else if err != nil {
+		// Like legacy octal numbers with 8 or 9
+		return 0, invalid
+	}
+	return t, nil
+}
+
+// Whether underscores only separate digits, or follow base prefixes
+func underscoresOK(s string, base int) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '_' {
+			continue
+		}
+		prefix := base != 10 && i == 2
+		if !prefix && (i == 0 || !isDigits(s[i-1:i], base, false)) ||
+			i+1 == len(s) || !isDigits(s[i+1:i+2], base, false) {
+			return false
+		}
+	}
+	return true
+}
+
+func isExponent(s string) bool {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "+"), "-")
+	return isDigits(s, 10, false)
+}
+
+func isDigits(s string, base int, empty bool) bool {
+	if s == "" {
+		return empty
+	}
+	for _, c := range []byte(s) {
+		var digit int
+		switch {
+		case '0' <= c && c <= '9':
+			digit = int(c - '0')
+		case 'a' <= c && c <= 'f':
+			digit = int(c-'a') + 10
+		case 'A' <= c && c <= 'F':
+			digit = int(c-'A') + 10
+		default:
+			return false
+		}
+		// Legacy octal numbers like 0755 are checked when parsed.
+		if digit >= base {
+			return false
+		}
+	}
+	return true
+}
+
+// Parses an integer literal like 1_000, 0x7f, 10i or 10u, returning its bits
+//
+// Literals beyond math.MaxInt64 are parsed as unsigned.
+func ParseInt(literal string) (uint64, error) {
+	literal = strings.TrimRight(literal, "iu")
+	v, err := strconv.ParseInt(literal, 0, 64)
+	if err == nil {
+		return uint64(v), nil
+	}
+	u, uerr := strconv.ParseUint(literal, 0, 64)
+	if uerr != nil {
+		return 0, err
+	}
+	return u, nil
+}
+
+// Parses a float literal like 1e9, 0x1p-2, .5f, inf or -nan
+func ParseFloat(literal string) (float64, error) {
+	unsigned := strings.TrimLeft(literal, "+-")
+	if unsigned == "nan" {
+		// Unlike inf, strconv rejects signs here.
+		return math.NaN(), nil
+	}
+	hex := strings.HasPrefix(unsigned, "0x") || strings.HasPrefix(unsigned, "0X")
+	if !hex && strings.HasSuffix(literal, "f") && !strings.HasSuffix(literal, "inf") {
+		literal = literal[:len(literal)-1]
+	}
+	return strconv.ParseFloat(literal, 64)
+}
@@ -0,0 +1,120 @@
+package gruelparser_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/internal/gruelparser"
+)
+
+func TestNumbers(t *testing.T) {
+	for token, expected := range map[string]gruelparser.TokenType{
+		"1_000":                 gruelparser.TypeInt,
+		"0x_7f_ff":              gruelparser.TypeInt,
+		"0b1010":                gruelparser.TypeInt,
+		"0O17":                  gruelparser.TypeInt,
+		"10i":                   gruelparser.TypeInt,
+		"-0x10i":                gruelparser.TypeInt,
+		"18446744073709551615":  gruelparser.TypeInt,
+		"10u":                   gruelparser.TypeUint64,
+		"18446744073709551615u": gruelparser.TypeUint64,
+		"1e9":                   gruelparser.TypeFloat,
+		"1E-9":                  gruelparser.TypeFloat,
+		"1_000.000_1e1_0":       gruelparser.TypeFloat,
+		"1.":                    gruelparser.TypeFloat,
+		"10f":                   gruelparser.TypeFloat,
+		"-.5f":                  gruelparser.TypeFloat,
+		"0x1.8p3":               gruelparser.TypeFloat,
+		"0x1p-2":                gruelparser.TypeFloat,
+		"inf":                   gruelparser.TypeFloat,
+		"-inf":                  gruelparser.TypeFloat,
+		"nan":                   gruelparser.TypeFloat,
+		"-nan":                  gruelparser.TypeFloat,
+		"+nan":                  gruelparser.TypeFloat,
+		"1_000.50d":             gruelparser.TypeDecimal,
+		"0x1f":                  gruelparser.TypeInt,
+		"0x1d":                  gruelparser.TypeInt,
+	} {
+		assertTokens(t, token, expected, token)
+	}
+}
+
+func TestNumberErrors(t *testing.T) {
+	for token, msg := range map[string]string{
+		"1e":                   "invalid number 1e",
+		"1__0":                 "invalid number 1__0",
+		"_1":                   "",
+		"1_":                   "invalid number 1_",
+		"1_.5":                 "invalid number 1_.5",
+		"0x":                   "invalid number 0x",
+		"0x1.8":                "invalid number 0x1.8",
+		"0b102":                "invalid number 0b102",
+		"09":                   "invalid number 09",
+		"1.2.3":                "invalid number 1.2.3",
+		"12abc":                "invalid number 12abc",
+		"1e5d":                 "invalid number 1e5d",
+		"1.5i":                 "1.5i is not an integer",
+		"-1u":                  "unsigned -1u is negative",
+		"1e400":                "number 1e400 out of range",
+		"99999999999999999999": "number 99999999999999999999 out of range",
+		"1e+":                  "invalid number 1e+",
+	} {
+		r := gruelparser.NewTokenReader("(+ x\n  " + token + ")")
+		var err error
+		for err == nil {
+			_, _, err = r.NextToken()
+		}
+		if msg == "" {
+			// Symbols may start with underscores.
+			assert.Equal(t, "EOF", err.Error(), token)
+			continue
+		}
+		assert.Equal(t, "2:3: "+msg, err.Error(), token)
+		syntaxErr, ok := err.(*gruelparser.SyntaxError)
+		assert.True(t, ok)
+		assert.Equal(t, 7, syntaxErr.Pos)
+	}
+
+	r := gruelparser.NewTokenReader("(+ 1\n  \"\\q\")")
+	var err error
+	for err == nil {
+		_, _, err = r.NextToken()
+	}
+	assert.Equal(t, "2:3: invalid string \"\\q\"", err.Error())
+	r = gruelparser.NewTokenReader("\n\n  \"open")
+	_, _, err = r.NextToken()
+	assert.Equal(t, "3:3: unterminated string sequence", err.Error())
+}
+
+func TestParseNumbers(t *testing.T) {
+	for literal, expected := range map[string]uint64{
+		"1_000":                1000,
+		"0x7f":                 127,
+		"-0x10i":               math.MaxUint64 - 15,
+		"10u":                  10,
+		"18446744073709551615": math.MaxUint64,
+		"0556":                 0o556,
+	} {
+		v, err := gruelparser.ParseInt(literal)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, v, literal)
+	}
+	for literal, expected := range map[string]float64{
+		"1e9":     1e9,
+		".456f":   0.456,
+		"10f":     10,
+		"0x1.8p3": 12,
+		"1_000.5": 1000.5,
+		"-inf":    math.Inf(-1),
+	} {
+		v, err := gruelparser.ParseFloat(literal)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, v, literal)
+	}
+	for _, literal := range []string{"nan", "-nan", "+nan"} {
+		v, err := gruelparser.ParseFloat(literal)
+		assert.Nil(t, err)
+		assert.True(t, math.IsNaN(v), literal)
+	}
+}
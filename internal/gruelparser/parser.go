@@ -2,6 +2,7 @@ package gruelparser
 
 import (
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 )
@@ -14,62 +15,133 @@ type GruelAstNode struct {
 	Type TokenType
 	// The parameters for non-atomic nodes (nil for atomic ones)
 	Parameters []GruelAstNode
+	// The byte offset of the token (or the opening parenthesis) in the source
+	Pos int
+	// The byte offset right after the token (or the closing parenthesis)
+	End int
 }
 
 // Parses a lisp-like expression into an AST tree
 //
 // The values still need further validation though.
+// Anything but comments after the expression is an error.
 func Parse(expr string) (GruelAstNode, error) {
 	r := NewTokenReader(expr)
+	node, _, err := parseForm(&r)
+	if err != nil {
+		return node, err
+	}
+	if _, _, err := r.NextToken(); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("unexpected content after the expression")
+		}
+		return GruelAstNode{Pos: r.Offset(), End: r.End()}, err
+	}
+	return node, nil
+}
+
+// Parses every top-level form of the source, like a file of rules
+//
+// On errors, the last form is the one that failed, positioned like with Parse,
+// and forms cut short report io.ErrUnexpectedEOF.
+func ParseAll(expr string) ([]GruelAstNode, error) {
+	p := Parser{r: NewTokenReader(expr)}
+	var forms []GruelAstNode
+	for {
+		node, err := p.Next()
+		if err == io.EOF {
+			return forms, nil
+		}
+		if err != nil {
+			return append(forms, node), err
+		}
+		forms = append(forms, node)
+	}
+}
+
+// Parses top-level forms one at a time from a stream, like a large bundle of rules
+type Parser struct {
+	r TokenReader
+}
+
+// Creates a parser over a stream, see NewTokenReaderFrom
+func NewParser(r io.Reader, maxTokenSize int) *Parser {
+	return &Parser{r: NewTokenReaderFrom(r, maxTokenSize)}
+}
+
+// Returns the next top-level form, or io.EOF after the last one
+//
+// Positions count from the start of the stream. On errors, the form is
+// positioned like with Parse, and forms cut short report io.ErrUnexpectedEOF.
+func (p *Parser) Next() (GruelAstNode, error) {
+	// Only the comments of the current form are kept.
+	*p.r.comments = nil
+	node, started, err := parseForm(&p.r)
+	if err == io.EOF && started {
+		err = io.ErrUnexpectedEOF
+	}
+	return node, err
+}
+
+// The comments read by the last call to Next, before or within its form
+func (p *Parser) Comments() []Comment {
+	return p.r.Comments()
+}
+
+// Parses the next form, telling whether any token was read
+func parseForm(r *TokenReader) (GruelAstNode, bool, error) {
 	branch := make([]GruelAstNode, 0, 16)
 	var current GruelAstNode
-	for {
+	for started := false; ; started = true {
 		token, tokenType, err := r.NextToken()
 		if err != nil {
-			return current, err
+			return current, started, err
 		}
 		current.Type = tokenType
+		current.Pos = r.Offset()
 		if tokenType == TypeParenthesis {
 			if token == "(" {
 				operator, operatorType, err := r.NextToken()
 				if err != nil {
-					return current, err
+					return current, true, err
 				}
 				if operatorType != TypeSymbol {
-					return current, fmt.Errorf("expecting symbolic operator")
+					return current, true, fmt.Errorf("expecting symbolic operator")
 				}
 				current.Value = operator
 				branch = append(branch, current)
 			} else if token == ")" {
 				var i int
-				for i = len(branch) - 1; i >= 0 && branch[i].Type != TypeParenthesis ||
-					branch[i].Parameters != nil; i-- {
+				for i = len(branch) - 1; i >= 0 && (branch[i].Type != TypeParenthesis ||
+					branch[i].Parameters != nil); i-- {
 				}
 				if i < 0 {
-					return current, fmt.Errorf("unexpected parenthesis")
+					return current, true, fmt.Errorf("unexpected parenthesis")
 				}
+				branch[i].End = r.End()
 				branch[i].Parameters = make([]GruelAstNode, len(branch)-i-1)
 				copy(branch[i].Parameters, branch[i+1:])
 				branch = branch[0 : i+1]
 				if i == 0 {
-					return branch[0], nil
+					return branch[0], true, nil
 				}
 			} else {
-				return current, fmt.Errorf("open parenthesis")
+				return current, true, fmt.Errorf("open parenthesis")
 			}
 		} else {
 			current.Value = token
+			current.End = r.End()
 			current.Parameters = nil
 			branch = append(branch, current)
 			if len(branch) == 1 {
-				return branch[0], nil
+				return branch[0], true, nil
 			}
 		}
 		current = GruelAstNode{}
 	}
 }
 
-// Implements fmt.Stringer
+// Implements fmt.Stringer, printing source that parses into the same tree
 func (node *GruelAstNode) String() string {
 	switch node.Type {
 	case TypeParenthesis:
@@ -84,9 +156,10 @@ func (node *GruelAstNode) String() string {
 		return sb.String()
 	case TypeString:
 		return strconv.Quote(node.Value)
-	case TypeBool:
-		return "#" + node.Value
+	case TypeTime:
+		return "#t" + strconv.Quote(node.Value)
 	default:
+		// Booleans are the plain `true` and `false` tokens, unlike time literals.
 		return node.Value
 	}
 }
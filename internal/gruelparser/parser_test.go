@@ -1,6 +1,8 @@
 package gruelparser_test
 
 import (
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -23,8 +25,15 @@ func TestParser(t *testing.T) {
 	assertError(t, "(", "EOF")
 	assertError(t, "(\"str\")", "expecting symbolic operator")
 	assertError(t, "(+", "EOF")
+	assertError(t, ")", "unexpected parenthesis")
+	assertError(t, "1 2", "unexpected content after the expression")
+	assertError(t, "(+ 1 2))", "unexpected content after the expression")
+	assertError(t, "#| open", "1:1: unterminated block comment")
 
 	assertAst(t, "\"\"", "\"\"")
+	assertAst(t, "; rule\n(+ 1 #| two |# 2) ; done", "(+ 1 2)")
+	assertAst(t, "(&& true false)", "(&& true false)")
+	assertAst(t, "(- #t\"2026-01-01T00:00:00Z\"  5m)", "(- #t\"2026-01-01T00:00:00Z\" 5m)")
 	assertAst(t,
 		"(with-eval-after-load 'evil-maps\n"+
 			"  (define-key evil-motion-state-map (kbd \"SPC\") nil)\n"+
@@ -35,3 +44,84 @@ func TestParser(t *testing.T) {
 			"(define-key evil-motion-state-map (kbd \"RET\") nil) "+
 			"(define-key evil-motion-state-map (kbd \"TAB\") nil))")
 }
+
+func TestStringRoundTrip(t *testing.T) {
+	for _, expr := range []string{
+		"true", "(&& true (! false))", "(== \"a\\\"b\" s)", "(< #t\"2026-01-01T00:00:00Z\" t)", "(+ 1.5d -2 3.)",
+	} {
+		node, err := gruelparser.Parse(expr)
+		assert.Nil(t, err)
+		again, err := gruelparser.Parse(node.String())
+		if assert.Nil(t, err, expr) {
+			assert.Equal(t, stripPositions(node), stripPositions(again), expr)
+		}
+	}
+}
+
+// Clears the positions of the nodes, which printing does not keep
+func stripPositions(node gruelparser.GruelAstNode) gruelparser.GruelAstNode {
+	node.Pos, node.End = 0, 0
+	parameters := make([]gruelparser.GruelAstNode, len(node.Parameters))
+	for i, p := range node.Parameters {
+		parameters[i] = stripPositions(p)
+	}
+	node.Parameters = parameters
+	return node
+}
+
+func TestPositions(t *testing.T) {
+	node, err := gruelparser.Parse("(+ 1\n  (* x \"é\" y))")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, node.Pos)
+	assert.Equal(t, 3, node.Parameters[0].Pos)
+	inner := node.Parameters[1]
+	assert.Equal(t, 7, inner.Pos)
+	assert.Equal(t, []int{10, 12, 17}, []int{
+		inner.Parameters[0].Pos, inner.Parameters[1].Pos, inner.Parameters[2].Pos,
+	})
+	assert.Equal(t, []int{20, 4, 19, 11, 16, 18}, []int{
+		node.End, node.Parameters[0].End, inner.End,
+		inner.Parameters[0].End, inner.Parameters[1].End, inner.Parameters[2].End,
+	})
+}
+
+func TestParseAll(t *testing.T) {
+	forms, err := gruelparser.ParseAll("; rules\n(+ 1 2)\nx #| y |# \"z\"\n")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(forms))
+	assert.Equal(t, []string{"(+ 1 2)", "x", "\"z\""},
+		[]string{forms[0].String(), forms[1].String(), forms[2].String()})
+	assert.Equal(t, 16, forms[1].Pos)
+
+	forms, err = gruelparser.ParseAll(" ; nothing")
+	assert.Nil(t, err)
+	assert.Empty(t, forms)
+
+	forms, err = gruelparser.ParseAll("1 (+ 2")
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Equal(t, 2, len(forms))
+	forms, err = gruelparser.ParseAll("1 ())")
+	assert.Equal(t, "expecting symbolic operator", err.Error())
+	assert.Equal(t, 2, forms[1].Pos)
+}
+
+func TestStreamingParser(t *testing.T) {
+	const n = 10000
+	p := gruelparser.NewParser(strings.NewReader(strings.Repeat("; rule\n(+ x 1)\n", n)+"; end"), 0)
+	for i := 0; i < n; i++ {
+		form, err := p.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, "(+ x 1)", form.String())
+		assert.Equal(t, 15*i+7, form.Pos)
+		assert.Equal(t, []gruelparser.Comment{{Text: "; rule", Pos: 15 * i, End: 15*i + 6}}, p.Comments())
+	}
+	_, err := p.Next()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "; end", p.Comments()[0].Text)
+
+	p = gruelparser.NewParser(strings.NewReader("1 (+ 2"), 0)
+	_, err = p.Next()
+	assert.Nil(t, err)
+	_, err = p.Next()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
@@ -2,10 +2,12 @@ package gruelparser
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 	"unicode/utf8"
 )
@@ -26,101 +28,277 @@ const (
 	TypeString
 	// A symbol that does not contain parenthesis or qualify as the other types above
 	TypeSymbol
+	// A timestamp, written as #t"2006-01-02T15:04:05Z07:00"
+	TypeTime
+	// A duration like 5m or 1h30m
+	TypeDuration
+	// A fixed-point decimal like 12.34d
+	TypeDecimal
+	// Sized numeric types, which literals adapt to like untyped constants in Go
+	TypeInt8
+	TypeInt16
+	TypeInt32
+	TypeUint8
+	TypeUint16
+	TypeUint32
+	TypeUint64
+	TypeFloat32
+)
+
+// The prefix of timestamp literals
+const timePrefix = "#t\""
+
+// The delimiters of block comments, which nest
+const (
+	blockStart = "#|"
+	blockEnd   = "|#"
 )
 
 // A tokenizer for simplified lisp-like grammar
 type TokenReader struct {
 	// The core scanner
-	s *bufio.Scanner
+	s            *bufio.Scanner
+	maxTokenSize int
+	// Positions shared with the split function
+	pos *position
+	// The comments skipped so far
+	comments *[]Comment
+}
+
+// Tracks positions while tokens are split
+type position struct {
+	consumed int
+	// The newlines consumed and the byte offset right after the last one
+	lines     int
+	lineStart int
+	// The last token
+	offset, end, line, column int
+}
+
+// Consumes the bytes, counting newlines
+func (p *position) skip(data []byte) {
+	for i, c := range data {
+		if c == '\n' {
+			p.lines++
+			p.lineStart = p.consumed + i + 1
+		}
+	}
+	p.consumed += len(data)
+}
+
+// An error in the source, like a malformed number or an unterminated string
+type SyntaxError struct {
+	// The byte offset of the error
+	Pos int
+	// The position of the error, starting from 1
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+// A comment, skipped by NextToken
+type Comment struct {
+	// The comment, either a line starting with ";" or a block between "#|" and "|#"
+	Text string
+	// The byte offsets of the comment and right after it
+	Pos int
+	End int
 }
 
 // Creates a new reader
 func NewTokenReader(str string) TokenReader {
-	s := bufio.NewScanner(strings.NewReader(str))
-	s.Split(func(data []byte, atEOF bool) (advance int, token []byte, err error) {
-		// Skip leading spaces.
-		start := 0
-		var r rune
-		for width := 0; start < len(data); start += width {
-			r, width = utf8.DecodeRune(data[start:])
-			if !unicode.IsSpace(r) {
-				break
-			}
+	// No token is longer than the string.
+	maxTokenSize := len(str) + 1
+	if maxTokenSize < bufio.MaxScanTokenSize {
+		maxTokenSize = bufio.MaxScanTokenSize
+	}
+	return NewTokenReaderFrom(strings.NewReader(str), maxTokenSize)
+}
+
+// Creates a reader over a stream, buffering up to maxTokenSize bytes for a token
+//
+// Zero stands for bufio.MaxScanTokenSize, and longer tokens fail with a *SyntaxError.
+func NewTokenReaderFrom(r io.Reader, maxTokenSize int) TokenReader {
+	if maxTokenSize <= 0 {
+		maxTokenSize = bufio.MaxScanTokenSize
+	}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, maxTokenSize)
+	p := &position{}
+	s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := splitToken(data, atEOF)
+		if e, ok := err.(*SyntaxError); ok {
+			// Positions the error, relative to the data so far.
+			p.skip(data[:e.Pos])
+			e.Pos, e.Line, e.Column = p.consumed, p.lines+1, p.consumed-p.lineStart+1
+			return advance, nil, e
 		}
-		// Split methods differ from types.
-		switch {
-		case r == '(' || r == ')':
-			// Parenthesis.
-			return start + 1, data[start : start+1], nil
-		case r == '"':
-			// A string.
-			escaped := false
-			marker := r
-			for width, i := 0, start+1; i < len(data); i += width {
-				var r rune
-				r, width = utf8.DecodeRune(data[i:])
-				if escaped {
-					escaped = false
-				} else {
-					if r == marker {
-						return i + width, data[start : i+width], nil
-					}
-					if r == '\\' {
-						escaped = true
-					}
+		if token != nil {
+			p.skip(data[:advance-len(token)])
+			p.offset, p.line, p.column = p.consumed, p.lines+1, p.consumed-p.lineStart+1
+			p.skip(data[advance-len(token) : advance])
+			p.end = p.consumed
+		} else {
+			p.skip(data[:advance])
+		}
+		return advance, token, err
+	})
+	return TokenReader{s: s, maxTokenSize: maxTokenSize, pos: p, comments: new([]Comment)}
+}
+
+// Splits the next token, see bufio.SplitFunc
+func splitToken(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	// Skip leading spaces.
+	start := 0
+	var r rune
+	for width := 0; start < len(data); start += width {
+		r, width = utf8.DecodeRune(data[start:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+	}
+	// Split methods differ from types.
+	switch {
+	case r == '(' || r == ')':
+		// Parenthesis.
+		return start + 1, data[start : start+1], nil
+	case r == ';':
+		// A comment, up to the end of the line.
+		if i := bytes.IndexByte(data[start:], '\n'); i >= 0 {
+			return start + i, data[start : start+i], nil
+		}
+		if atEOF {
+			return len(data), data[start:], nil
+		}
+		return start, nil, nil
+	case r == '#' && !atEOF && len(data)-start < len(timePrefix):
+		// Not enough data to tell a timestamp from a symbol.
+		return start, nil, nil
+	case bytes.HasPrefix(data[start:], []byte(blockStart)):
+		// A block comment, possibly with nested ones.
+		depth := 0
+		for i := start; i+1 < len(data); i++ {
+			switch string(data[i : i+2]) {
+			case blockStart:
+				depth++
+				i++
+			case blockEnd:
+				depth--
+				i++
+				if depth == 0 {
+					return i + 1, data[start : i+1], nil
 				}
 			}
-			if atEOF && len(data) > start {
-				return len(data), nil, fmt.Errorf("unterminated string sequence")
-			}
-			return start, nil, nil
-		default:
-			// An arbitrary symbol.
-			for width, i := 0, start; i < len(data); i += width {
-				var r rune
-				r, width = utf8.DecodeRune(data[i:])
-				if unicode.IsSpace(r) || r == '(' || r == ')' {
-					return i, data[start:i], nil
+		}
+		if atEOF {
+			return len(data), nil, &SyntaxError{Pos: start, Msg: "unterminated block comment"}
+		}
+		return start, nil, nil
+	case r == '"' || bytes.HasPrefix(data[start:], []byte(timePrefix)):
+		// A string or a timestamp.
+		escaped := false
+		marker := '"'
+		quote := bytes.IndexByte(data[start:], '"') + start
+		for width, i := 0, quote+1; i < len(data); i += width {
+			var r rune
+			r, width = utf8.DecodeRune(data[i:])
+			if escaped {
+				escaped = false
+			} else {
+				if r == marker {
+					return i + width, data[start : i+width], nil
+				}
+				if r == '\\' {
+					escaped = true
 				}
 			}
-			if atEOF && len(data) > start {
-				return len(data), data[start:], nil
+		}
+		if atEOF && len(data) > start {
+			return len(data), nil, &SyntaxError{Pos: start, Msg: "unterminated string sequence"}
+		}
+		return start, nil, nil
+	default:
+		// An arbitrary symbol.
+		for width, i := 0, start; i < len(data); i += width {
+			var r rune
+			r, width = utf8.DecodeRune(data[i:])
+			if unicode.IsSpace(r) || r == '(' || r == ')' || r == ';' {
+				return i, data[start:i], nil
 			}
-			return start, nil, nil
 		}
-	})
-	return TokenReader{s: s}
+		if atEOF && len(data) > start {
+			return len(data), data[start:], nil
+		}
+		return start, nil, nil
+	}
+}
+
+// The byte offset of the last token returned by NextToken
+func (reader *TokenReader) Offset() int {
+	return reader.pos.offset
+}
+
+// The byte offset right after the last token returned by NextToken
+func (reader *TokenReader) End() int {
+	return reader.pos.end
+}
+
+// An error at the last token
+func (reader *TokenReader) errorf(format string, args ...any) error {
+	return &SyntaxError{
+		Pos:    reader.pos.offset,
+		Line:   reader.pos.line,
+		Column: reader.pos.column,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// The comments skipped so far, in source order
+func (reader *TokenReader) Comments() []Comment {
+	return *reader.comments
 }
 
 // Returns the next token along with its type
 //
-// Strings are unquoted. Other types are not checked strictly.
+// Strings are unquoted and numbers are checked against the literal grammar,
+// with errors reported as *SyntaxError.
+// Comments are skipped and collected for Comments.
 func (reader *TokenReader) NextToken() (string, TokenType, error) {
-	if !reader.s.Scan() {
-		err := reader.s.Err()
-		if err == nil {
-			return "", 0, io.EOF
-		} else {
-			return "", 0, err
-		}
-	}
-
-	bytes := reader.s.Bytes()
-	initial := bytes[0]
-	if (initial == '-' || initial == '+') && len(bytes) > 1 {
-		initial = bytes[1]
+	bytes, err := reader.scan()
+	if err != nil {
+		return "", 0, err
 	}
 	token := string(bytes)
-	switch {
+	unsigned := token
+	if (token[0] == '-' || token[0] == '+') && len(token) > 1 {
+		unsigned = token[1:]
+	}
+	switch initial := unsigned[0]; {
 	case initial == '"':
 		inner, err := strconv.Unquote(token)
-		return inner, TypeString, err
-	case ('0' <= initial && initial <= '9') || initial == '.':
-		if strings.Contains(token, ".") {
-			return token, TypeFloat, nil
-		} else {
-			return token, TypeInt, nil
+		if err != nil {
+			return "", 0, reader.errorf("invalid string %s", token)
 		}
+		return inner, TypeString, nil
+	case strings.HasPrefix(token, timePrefix):
+		inner, err := strconv.Unquote(token[len(timePrefix)-1:])
+		if err != nil {
+			return "", 0, reader.errorf("invalid timestamp %s", token)
+		}
+		return inner, TypeTime, nil
+	case ('0' <= initial && initial <= '9') || initial == '.' || unsigned == "inf" || unsigned == "nan":
+		if isDuration(token) {
+			return token, TypeDuration, nil
+		}
+		t, err := lexNumber(token)
+		if err != nil {
+			return "", 0, reader.errorf("%v", err)
+		}
+		return token, t, nil
 	case initial == '(' || initial == ')':
 		return token, TypeParenthesis, nil
 	case token == "true" || token == "false":
@@ -129,3 +307,42 @@ func (reader *TokenReader) NextToken() (string, TokenType, error) {
 		return token, TypeSymbol, nil
 	}
 }
+
+// Scans the next token, collecting the comments before it
+func (reader *TokenReader) scan() ([]byte, error) {
+	for reader.s.Scan() {
+		bytes := reader.s.Bytes()
+		if bytes[0] != ';' && !strings.HasPrefix(string(bytes), blockStart) {
+			return bytes, nil
+		}
+		*reader.comments = append(*reader.comments, Comment{
+			Text: strings.TrimRightFunc(string(bytes), unicode.IsSpace),
+			Pos:  reader.Offset(),
+			End:  reader.End(),
+		})
+	}
+	err := reader.s.Err()
+	if err == nil {
+		return nil, io.EOF
+	} else if err == bufio.ErrTooLong {
+		// The token starts right after what was consumed.
+		p := reader.pos
+		return nil, &SyntaxError{
+			Pos:    p.consumed,
+			Line:   p.lines + 1,
+			Column: p.consumed - p.lineStart + 1,
+			Msg:    fmt.Sprintf("token longer than %d bytes", reader.maxTokenSize),
+		}
+	}
+	return nil, err
+}
+
+// Checks whether a numeric token is a duration like 1h30m
+func isDuration(token string) bool {
+	last, _ := utf8.DecodeLastRuneInString(token)
+	if !unicode.IsLetter(last) {
+		return false
+	}
+	_, err := time.ParseDuration(token)
+	return err == nil
+}
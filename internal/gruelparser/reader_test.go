@@ -1,6 +1,8 @@
 package gruelparser_test
 
 import (
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -44,6 +46,19 @@ func TestSplit(t *testing.T) {
 	assertTokens(t, "-0556677", gruelparser.TypeInt, "-0556677")
 	assertTokens(t, "-0556677", gruelparser.TypeInt, "-0556677")
 
+	// timestamps and durations
+	assertTokens(t, "2026-01-01T00:00:00Z", gruelparser.TypeTime, "#t\"2026-01-01T00:00:00Z\"")
+	assertTokens(t, "2026-01-01 08:00", gruelparser.TypeTime, "#t\"2026-01-01 08:00\"")
+	assertTokens(t, "5m", gruelparser.TypeDuration, "5m")
+	assertTokens(t, "-1h30m", gruelparser.TypeDuration, "-1h30m")
+	assertTokens(t, "1.5s", gruelparser.TypeDuration, "1.5s")
+	assertTokens(t, "#tag", gruelparser.TypeSymbol, "#tag")
+
+	// decimals
+	assertTokens(t, "12.34d", gruelparser.TypeDecimal, "12.34d")
+	assertTokens(t, "-5d", gruelparser.TypeDecimal, "-5d")
+	assertTokens(t, "0x5d", gruelparser.TypeInt, "0x5d")
+
 	// bools
 	assertTokens(t, "true", gruelparser.TypeBool, "true")
 	assertTokens(t, "false", gruelparser.TypeBool, "false")
@@ -58,3 +73,77 @@ func TestSplit(t *testing.T) {
 	assertTokens(t, "(", gruelparser.TypeParenthesis, "(")
 	assertTokens(t, ")", gruelparser.TypeParenthesis, ")")
 }
+
+func TestComments(t *testing.T) {
+	r := gruelparser.NewTokenReader("; leading\n(+ a;trailing  \n 1) ; last")
+	var tokens []string
+	for {
+		token, _, err := r.NextToken()
+		if err != nil {
+			break
+		}
+		tokens = append(tokens, token)
+	}
+	assert.Equal(t, []string{"(", "+", "a", "1", ")"}, tokens)
+	assert.Equal(t, []gruelparser.Comment{
+		{Text: "; leading", Pos: 0, End: 9},
+		{Text: ";trailing", Pos: 14, End: 25},
+		{Text: "; last", Pos: 30, End: 36},
+	}, r.Comments())
+
+	assertTokens(t, "\"a;b\"", gruelparser.TypeString, "\"\\\"a;b\\\"\"")
+
+	// Comments are skipped without recursing, however many there are.
+	r = gruelparser.NewTokenReader(strings.Repeat("; comment\n#| block |#", 1_000_000) + "1")
+	token, tokenType, err := r.NextToken()
+	assert.Nil(t, err)
+	assert.Equal(t, "1", token)
+	assert.Equal(t, gruelparser.TypeInt, tokenType)
+	assert.Len(t, r.Comments(), 2_000_000)
+	_, _, err = r.NextToken()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestBlockComments(t *testing.T) {
+	r := gruelparser.NewTokenReader("(+ #| one\n#| nested |# |#1 #||#2)")
+	var tokens []string
+	for {
+		token, _, err := r.NextToken()
+		if err != nil {
+			break
+		}
+		tokens = append(tokens, token)
+	}
+	assert.Equal(t, []string{"(", "+", "1", "2", ")"}, tokens)
+	assert.Equal(t, []gruelparser.Comment{
+		{Text: "#| one\n#| nested |# |#", Pos: 3, End: 25},
+		{Text: "#||#", Pos: 27, End: 31},
+	}, r.Comments())
+
+	r = gruelparser.NewTokenReader("1 #| #| |#")
+	_, _, err := r.NextToken()
+	assert.Nil(t, err)
+	_, _, err = r.NextToken()
+	assert.Equal(t, "1:3: unterminated block comment", err.Error())
+}
+
+func TestLargeTokens(t *testing.T) {
+	large := strings.Repeat("x", 1<<20)
+	r := gruelparser.NewTokenReader("(f \"" + large + "\")")
+	var token string
+	var err error
+	for i := 0; i < 3 && err == nil; i++ {
+		token, _, err = r.NextToken()
+	}
+	assert.Nil(t, err)
+	assert.Equal(t, large, token)
+
+	r = gruelparser.NewTokenReaderFrom(strings.NewReader("(f\n  \""+large+"\")"), 1024)
+	for err == nil {
+		_, _, err = r.NextToken()
+	}
+	assert.Equal(t, "2:3: token longer than 1024 bytes", err.Error())
+	syntaxErr, ok := err.(*gruelparser.SyntaxError)
+	assert.True(t, ok)
+	assert.Equal(t, 5, syntaxErr.Pos)
+}
@@ -0,0 +1,271 @@
+package ir
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+)
+
+// A value bound to a parameter, see Bind
+type Binding struct {
+	// The value encoded like parameters, with sized values in their lower bits
+	Value uint64
+	// The value of string parameters
+	String string
+}
+
+// Returns a copy of the code with some parameters replaced by constants
+//
+// Symbol instructions are rewritten in place, so that check sites, relocations
+// and stack usage stay valid. The remaining parameters keep their order.
+func (b *IrBuilder) Bind(values map[string]Binding) (*IrBuilder, error) {
+	b.Finalize()
+	for name := range values {
+		if _, ok := b.argv[name]; !ok {
+			return nil, fmt.Errorf("parameter %s not found", name)
+		}
+	}
+
+	c := b.clone(b.b.Bytes(), b.relocations)
+	c.argc, c.argv = 0, make(map[string]int)
+	c.symbols = make(map[string]byte)
+
+	names := make([]string, len(b.argv))
+	for name, index := range b.argv {
+		names[index] = name
+	}
+	indices := make([]uint64, len(names))
+	for i, name := range names {
+		if _, ok := values[name]; !ok {
+			indices[i] = uint64(c.argc)
+			c.argv[name] = c.argc
+			c.symbols[name] = b.symbols[name]
+			c.argc++
+		}
+	}
+
+	code := c.b.Bytes()
+	for offset := 0; offset < len(code); offset += 16 {
+		if gruelparser.TokenType(code[offset]) != gruelparser.TypeSymbol {
+			continue
+		}
+		index := binary.LittleEndian.Uint64(code[offset+8:])
+		if index >= uint64(len(names)) {
+			return nil, fmt.Errorf("invalid parameter index %d", index)
+		}
+		v, ok := values[names[index]]
+		if !ok {
+			binary.LittleEndian.PutUint64(code[offset+8:], indices[index])
+			continue
+		}
+		t := gruelparser.TokenType(b.symbols[names[index]])
+		value := constantValue(t, v.Value)
+		if t == typeString {
+			value = c.pool(v.String)
+			c.relocations = append(c.relocations, relocation{offset, relocString, len(c.objects) - 1})
+		}
+		binary.LittleEndian.PutUint64(code[offset:], uint64(t))
+		binary.LittleEndian.PutUint64(code[offset+8:], value)
+	}
+	sort.Slice(c.relocations, func(i, j int) bool {
+		return c.relocations[i].offset < c.relocations[j].offset
+	})
+	return c, nil
+}
+
+// Copies the program with other code, keeping the parameters and check sites
+//
+// Relocations are given by offsets into the new code.
+func (b *IrBuilder) clone(code []byte, relocations []relocation) *IrBuilder {
+	c := *b
+	c.b = bytes.Buffer{}
+	c.b.Write(code)
+	c.final, c.args = false, nil
+	c.objects = append([]string(nil), b.objects...)
+	c.strings = list.List{}
+	for e := b.strings.Front(); e != nil; e = e.Next() {
+		c.strings.PushBack(e.Value)
+	}
+	c.relocations = append([]relocation(nil), relocations...)
+	c.types = append([]gruelparser.TokenType(nil), b.types...)
+	return &c
+}
+
+// A value on the stack while folding, see Fold
+type foldValue struct {
+	// The code computing the value
+	start, end int
+	t          gruelparser.TokenType
+	// Whether the value is computed from constants by built-in operators
+	constant bool
+	operator bool
+}
+
+// Returns a copy of the code with constant sub-expressions replaced by their values
+//
+// Built-in operators whose operands are all constants, like bound parameters,
+// are evaluated by eval, which is given a program computing the largest
+// such sub-expressions one at a time and reports whether it succeeded.
+// Sub-expressions failing their runtime checks are left to fail at every call.
+// Host functions and intrinsics are never evaluated, and string results
+// are kept as they are.
+func (b *IrBuilder) Fold(eval func(program *IrBuilder) (uint64, bool)) *IrBuilder {
+	b.Finalize()
+	code := b.b.Bytes()
+	relocated := make(map[int]byte, len(b.relocations))
+	for _, r := range b.relocations {
+		relocated[r.offset] = r.kind
+	}
+
+	var stack, folds []foldValue
+	// Marks the largest constant sub-expressions among values being consumed.
+	consume := func(values []foldValue) {
+		for _, v := range values {
+			if v.constant && v.operator && v.t != typeString {
+				folds = append(folds, v)
+			}
+		}
+	}
+	for offset := 0; offset < len(code); offset += 16 {
+		tag := binary.LittleEndian.Uint64(code[offset:])
+		value := binary.LittleEndian.Uint64(code[offset+8:])
+		t := gruelparser.TokenType(tag & 0xff)
+		v := foldValue{start: offset, end: offset + 16, t: t}
+		pops := 0
+		switch t {
+		case gruelparser.TypeParenthesis:
+			name, op := builtinOperator(value)
+			if op == nil {
+				// Intrinsics may have side effects.
+				intrinsics.RLock()
+				intrinsic, ok := intrinsics.byName[intrinsics.byOpcode[int(value)]]
+				intrinsics.RUnlock()
+				if !ok {
+					return b
+				}
+				pops, v.t = len(intrinsic.Args), intrinsic.Result
+				break
+			}
+			pops, v.operator, v.constant = operatorArity[value], true, true
+			if pops > len(stack) {
+				return b
+			}
+			// Hidden operands are pushed last.
+			operands := make([]gruelparser.TokenType, op.Argc)
+			for i := range operands {
+				operands[i] = stack[len(stack)-1-(pops-op.Argc)-i].t
+			}
+			var err error
+			if v.t, err = resultType(name, operands); err != nil {
+				v.constant = false
+			}
+			for _, operand := range stack[len(stack)-pops:] {
+				v.constant = v.constant && operand.constant
+			}
+		case gruelparser.TypeSymbol:
+			if value < uint64(len(b.args)) {
+				v.t = gruelparser.TokenType(b.args[value])
+			}
+		case typeHost:
+			pops, v.t = int(tag>>8&0xff)+1, gruelparser.TokenType(tag>>16&0xff)
+		default:
+			// Host function handles are only valid in host calls.
+			v.constant = relocated[offset] != relocHost || t != typeInt
+		}
+		if pops > len(stack) {
+			return b
+		}
+		operands := stack[len(stack)-pops:]
+		if v.constant {
+			v.start = offset
+			if pops != 0 {
+				v.start = operands[0].start
+			}
+		} else {
+			consume(operands)
+		}
+		stack = append(stack[:len(stack)-pops], v)
+	}
+	consume(stack)
+	if len(folds) == 0 {
+		return b
+	}
+
+	sort.Slice(folds, func(i, j int) bool { return folds[i].start < folds[j].start })
+	folded := bytes.Buffer{}
+	relocations := []relocation(nil)
+	r, last := 0, 0
+	// Copies the code up to the offset along with its relocations.
+	copyTo := func(end int) {
+		shift := folded.Len() - last
+		for ; r < len(b.relocations) && b.relocations[r].offset < end; r++ {
+			if b.relocations[r].offset >= last {
+				moved := b.relocations[r]
+				moved.offset += shift
+				relocations = append(relocations, moved)
+			}
+		}
+		folded.Write(code[last:end])
+		last = end
+	}
+	for _, v := range folds {
+		var inner []relocation
+		for _, r := range b.relocations {
+			if v.start <= r.offset && r.offset < v.end {
+				inner = append(inner, relocation{r.offset - v.start, r.kind, r.index})
+			}
+		}
+		sub := b.clone(code[v.start:v.end], inner)
+		sub.argc, sub.argv = 0, make(map[string]int)
+		sub.symbols = make(map[string]byte)
+		sub.types = []gruelparser.TokenType{v.t}
+		value, ok := eval(sub)
+		if !ok {
+			continue
+		}
+		copyTo(v.start)
+		// Results are encoded like parameters, except for float32 ones.
+		if v.t != typeFloat32 {
+			value = constantValue(v.t, value)
+		}
+		instruction := [16]byte{}
+		binary.LittleEndian.PutUint64(instruction[:], uint64(v.t))
+		binary.LittleEndian.PutUint64(instruction[8:], value)
+		folded.Write(instruction[:])
+		last = v.end
+	}
+	copyTo(len(code))
+	return b.clone(folded.Bytes(), relocations)
+}
+
+// Encodes a parameter value like constants of the type
+func constantValue(t gruelparser.TokenType, v uint64) uint64 {
+	switch t {
+	case typeBool:
+		if v != 0 {
+			return 1
+		}
+		return 0
+	case typeInt8:
+		return uint64(int8(v))
+	case typeInt16:
+		return uint64(int16(v))
+	case typeInt32:
+		return uint64(int32(v))
+	case typeUint8:
+		return uint64(uint8(v))
+	case typeUint16:
+		return uint64(uint16(v))
+	case typeUint32:
+		return uint64(uint32(v))
+	case typeFloat32:
+		return math.Float64bits(float64(math.Float32frombits(uint32(v))))
+	default:
+		return v
+	}
+}
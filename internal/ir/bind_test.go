@@ -0,0 +1,86 @@
+package ir_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/internal/ir"
+)
+
+func compileBound(t *testing.T, expr string, values map[string]ir.Binding) *ir.IrBuilder {
+	ast, err := gruelparser.Parse(expr)
+	assert.Nil(t, err)
+	b, err := ir.Compile(&ast, map[string]byte{
+		"amount":    byte(gruelparser.TypeInt),
+		"threshold": byte(gruelparser.TypeInt),
+	}, ir.Options{})
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	bound, err := b.Bind(values)
+	assert.Nil(t, err)
+	return bound
+}
+
+func TestFold(t *testing.T) {
+	b := compileBound(t, "(> amount (* (+ threshold 1) 2))", map[string]ir.Binding{"threshold": {Value: 99}})
+	var programs [][]ir.Instruction
+	folded := b.Fold(func(program *ir.IrBuilder) (uint64, bool) {
+		programs = append(programs, program.Instructions())
+		assert.Empty(t, program.Args())
+		assert.Equal(t, gruelparser.TypeInt, program.ResultType())
+		return 200, true
+	})
+	// Only the largest constant sub-expression is evaluated.
+	assert.Len(t, programs, 1)
+	assert.Len(t, programs[0], 5)
+	instructions := folded.Instructions()
+	assert.Len(t, instructions, 3)
+	assert.Equal(t, ir.Instruction{Type: gruelparser.TypeInt, Value: 200}, instructions[0])
+	assert.Equal(t, gruelparser.TypeSymbol, instructions[1].Type)
+	assert.Equal(t, ">", instructions[2].Name)
+	assert.Equal(t, map[string]int{"amount": 0}, folded.ArgMap())
+	assert.Equal(t, b.Sites(), folded.Sites())
+
+	// Failed evaluations keep the code.
+	b = compileBound(t, "(/ amount (- threshold 5))", map[string]ir.Binding{"threshold": {Value: 5}})
+	folded = b.Fold(func(program *ir.IrBuilder) (uint64, bool) {
+		return 0, false
+	})
+	assert.Equal(t, b.Instructions(), folded.Instructions())
+
+	// Nothing is evaluated without bound parameters.
+	b = compileBound(t, "(> amount threshold)", nil)
+	folded = b.Fold(func(program *ir.IrBuilder) (uint64, bool) {
+		t.Error("unexpected evaluation")
+		return 0, false
+	})
+	assert.Equal(t, b.Instructions(), folded.Instructions())
+}
+
+func TestFoldStrings(t *testing.T) {
+	ast, err := gruelparser.Parse(`(&& (== region "eu") (> amount 1))`)
+	assert.Nil(t, err)
+	b, err := ir.Compile(&ast, map[string]byte{
+		"amount": byte(gruelparser.TypeInt),
+		"region": byte(gruelparser.TypeString),
+	}, ir.Options{})
+	assert.Nil(t, err)
+	b, err = b.Bind(map[string]ir.Binding{"region": {String: "eu"}})
+	assert.Nil(t, err)
+	folded := b.Fold(func(program *ir.IrBuilder) (uint64, bool) {
+		strings := make([]string, 0)
+		for _, in := range program.Instructions() {
+			if in.Type == gruelparser.TypeString {
+				strings = append(strings, in.String)
+			}
+		}
+		assert.Equal(t, []string{"eu", "eu"}, strings)
+		assert.Equal(t, gruelparser.TypeBool, program.ResultType())
+		return 1, true
+	})
+	instructions := folded.Instructions()
+	assert.Equal(t, ir.Instruction{Type: gruelparser.TypeBool, Value: 1}, instructions[len(instructions)-2])
+	assert.Empty(t, folded.Strings())
+}
@@ -0,0 +1,443 @@
+package ir
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"time"
+	"unsafe"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+)
+
+// Bytecode files start with the magic and the version,
+// followed by a fingerprint of the operator table.
+const (
+	bytecodeMagic   = "GRUEL"
+	bytecodeVersion = 1
+)
+
+// Kinds of process-specific values in the code, replaced when loaded
+const (
+	// A GoString pointer to a pooled string
+	relocString byte = iota
+	// The pointer to the time zone table
+	relocZone
+	// The handle of a host function, indexed by name
+	relocHost
+	// The opcode of an intrinsic, indexed by name
+	relocIntrinsic
+)
+
+type relocation struct {
+	// The offset of the instruction in the code
+	offset int
+	kind   byte
+	index  int
+}
+
+// Records a relocation for the next instruction
+func (b *IrBuilder) relocate(kind byte, index int) {
+	b.relocations = append(b.relocations, relocation{b.b.Len(), kind, index})
+}
+
+// Returns the index of the name, appending it if not found
+func nameIndex(names *[]string, name string) int {
+	for i, v := range *names {
+		if v == name {
+			return i
+		}
+	}
+	*names = append(*names, name)
+	return len(*names) - 1
+}
+
+// Identifies the opcodes of built-in operators
+var operatorsFingerprint = func() uint64 {
+	lines := make([]string, 0, len(Operators))
+	for name, ops := range Operators {
+		for _, op := range ops {
+			lines = append(lines, fmt.Sprintf("%s %d %d %v %s", name, op.Opcode, op.Argc, op.Argf, op.JitFunction))
+		}
+	}
+	sort.Strings(lines)
+	h := fnv.New64a()
+	for _, line := range lines {
+		h.Write([]byte(line))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}()
+
+// Operand counts of built-in operators by opcode, including hidden operands
+var operatorArity = func() map[uint64]int {
+	arity := make(map[uint64]int)
+	for _, ops := range Operators {
+		for _, op := range ops {
+			arity[uint64(op.Opcode)] = op.Argc
+			if op.JitFunction[0] == '@' || op.JitFunction[0] == '&' {
+				arity[uint64(op.Opcode)]++
+			}
+		}
+	}
+	return arity
+}()
+
+// Serializes the program into relocatable bytecode
+//
+// The source is kept for positions in runtime errors, and may be empty
+// to drop the positions.
+// Pointers in the code are zeroed and replaced by entries in the constant pool,
+// and host functions and intrinsics are referred to by names.
+func Marshal(b *IrBuilder, source string) ([]byte, error) {
+	b.Finalize()
+	loc := b.location()
+	if _, err := time.LoadLocation(loc.String()); err != nil {
+		return nil, fmt.Errorf("location %s cannot be serialized", loc)
+	}
+
+	code := bytes.Clone(b.Code())
+	for _, r := range b.relocations {
+		binary.LittleEndian.PutUint64(code[r.offset+8:], 0)
+	}
+
+	e := encoder{}
+	e.buf = append(e.buf, bytecodeMagic...)
+	e.uint(bytecodeVersion)
+	e.uint(operatorsFingerprint)
+	e.string(source)
+	e.string(loc.String())
+	e.uint(uint64(b.ResultType()))
+	e.uint(uint64(b.scratch))
+
+	e.uint(uint64(len(b.args)))
+	names := make([]string, len(b.args))
+	for name, index := range b.argv {
+		names[index] = name
+	}
+	for i, name := range names {
+		e.string(name)
+		e.uint(uint64(b.args[i]))
+	}
+
+	e.uint(uint64(len(b.objects)))
+	for _, s := range b.objects {
+		e.string(s)
+	}
+	e.uint(uint64(len(b.hosts)))
+	for _, name := range b.hosts {
+		fn := b.options.Functions[name]
+		e.string(name)
+		e.signature(fn.Args, fn.Result)
+	}
+	e.uint(uint64(len(b.intrinsics)))
+	for _, name := range b.intrinsics {
+		intrinsic, _ := lookupIntrinsic(name)
+		e.string(name)
+		e.signature(intrinsic.Args, intrinsic.Result)
+	}
+
+	e.uint(uint64(len(b.sites)))
+	for _, site := range b.sites {
+		e.string(site.Operator)
+		if source == "" {
+			site.Pos = 0
+		}
+		e.uint(uint64(site.Pos))
+		e.uint(uint64(site.Check))
+	}
+	e.uint(uint64(len(b.relocations)))
+	for _, r := range b.relocations {
+		e.uint(uint64(r.offset))
+		e.uint(uint64(r.kind))
+		e.uint(uint64(r.index))
+	}
+	e.uint(uint64(len(code)))
+	e.buf = append(e.buf, code...)
+	return e.buf, nil
+}
+
+// Loads bytecode produced by Marshal, returning the program and its source
+//
+// Types are not checked again. The code is checked for consistency,
+// such as operands within the parameters and the scratch buffer,
+// but bytecode should still come from a trusted source.
+// Host functions are resolved from options.Functions and must have the same signatures.
+// The time zone is loaded by name, or taken from options.Location if that fails.
+func Unmarshal(data []byte, options Options) (*IrBuilder, string, error) {
+	if !bytes.HasPrefix(data, []byte(bytecodeMagic)) {
+		return nil, "", fmt.Errorf("not gruel bytecode")
+	}
+	d := decoder{data: data[len(bytecodeMagic):]}
+	if version := d.uint(); version != bytecodeVersion {
+		return nil, "", fmt.Errorf("unsupported bytecode version %d", version)
+	}
+	if d.uint() != operatorsFingerprint {
+		return nil, "", fmt.Errorf("bytecode compiled with different operators")
+	}
+	source := d.string()
+	zone := d.string()
+	b := &IrBuilder{final: true, argv: make(map[string]int), options: options}
+	result := gruelparser.TokenType(d.uint())
+	b.types = []gruelparser.TokenType{result}
+	b.scratch = d.count(1 << 20)
+
+	argc := d.count(len(data))
+	b.argc = argc
+	b.args = make([]byte, argc)
+	for i := 0; i < argc; i++ {
+		name := d.string()
+		b.argv[name] = i
+		b.args[i] = byte(d.uint())
+		if t := gruelparser.TokenType(b.args[i]); !isNumeric(t) && t != typeString && !isOpaque(t) {
+			return nil, "", fmt.Errorf("symbol %s must have a value type", name)
+		}
+	}
+
+	b.objects = make([]string, d.count(len(data)))
+	for i := range b.objects {
+		b.objects[i] = d.string()
+	}
+	handles := make([]uint64, d.count(len(data)))
+	for i := range handles {
+		name := d.string()
+		args, result := d.signature()
+		fn, ok := options.Functions[name]
+		if !ok || !sameTypes(fn.Args, args) || fn.Result != result {
+			return nil, "", fmt.Errorf("function %s not found", name)
+		}
+		handles[i] = fn.Handle
+	}
+	opcodes := make([]uint64, d.count(len(data)))
+	// Operand counts of the intrinsics by opcode
+	intrinsics := make(map[uint64]int, len(opcodes))
+	for i := range opcodes {
+		name := d.string()
+		args, result := d.signature()
+		intrinsic, ok := lookupIntrinsic(name)
+		if !ok || !sameTypes(intrinsic.Args, args) || intrinsic.Result != result {
+			return nil, "", fmt.Errorf("intrinsic %s not found", name)
+		}
+		opcodes[i] = uint64(intrinsic.Opcode)
+		intrinsics[opcodes[i]] = len(args)
+	}
+
+	b.sites = make([]Site, d.count(len(data)))
+	for i := range b.sites {
+		b.sites[i] = Site{Operator: d.string(), Pos: d.count(len(source)), Check: byte(d.uint())}
+	}
+	b.relocations = make([]relocation, d.count(len(data)))
+	for i := range b.relocations {
+		b.relocations[i] = relocation{offset: d.count(len(data)), kind: byte(d.uint()), index: d.count(len(data))}
+	}
+	code := d.bytes(d.count(len(data)))
+	if d.err != nil || len(d.data) != 0 {
+		return nil, "", fmt.Errorf("invalid bytecode")
+	}
+	b.b.Write(code)
+
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		if options.Location == nil || options.Location.String() != zone {
+			return nil, "", fmt.Errorf("unknown location %s", zone)
+		}
+		loc = options.Location
+	}
+	b.options.Location = loc
+	if err := b.relink(handles, opcodes); err != nil {
+		return nil, "", err
+	}
+	if err := b.validate(len(handles) != 0, intrinsics); err != nil {
+		return nil, "", err
+	}
+	return b, source, nil
+}
+
+// Replaces relocated values in the code
+func (b *IrBuilder) relink(handles []uint64, opcodes []uint64) error {
+	code := b.b.Bytes()
+	for _, r := range b.relocations {
+		if r.offset%16 != 0 || r.offset >= len(code) {
+			return fmt.Errorf("invalid relocation")
+		}
+		tag := gruelparser.TokenType(code[r.offset])
+		var value uint64
+		switch {
+		case r.kind == relocString && tag == typeString && r.index < len(b.objects):
+			hdr := (*reflect.StringHeader)(unsafe.Pointer(&b.objects[r.index]))
+			s := &GoString{uint64(hdr.Data), uint64(hdr.Len)}
+			b.strings.PushBack(s)
+			value = uint64(uintptr(unsafe.Pointer(&s[0])))
+		case r.kind == relocZone && tag == typeString:
+			value = uint64(uintptr(unsafe.Pointer(&b.zoneTable()[0])))
+		case r.kind == relocHost && tag == typeInt && r.index < len(handles):
+			value = handles[r.index]
+		case r.kind == relocIntrinsic && tag == gruelparser.TypeParenthesis && r.index < len(opcodes):
+			value = opcodes[r.index]
+		default:
+			return fmt.Errorf("invalid relocation")
+		}
+		binary.LittleEndian.PutUint64(code[r.offset+8:], value)
+	}
+	return nil
+}
+
+// Checks operands and the stack usage of loaded code, and recomputes MaxStack
+func (b *IrBuilder) validate(hosts bool, intrinsics map[uint64]int) error {
+	code := b.b.Bytes()
+	if len(code) == 0 || len(code)%16 != 0 {
+		return fmt.Errorf("invalid bytecode")
+	}
+	relocated := make(map[int]bool, len(b.relocations))
+	for _, r := range b.relocations {
+		relocated[r.offset] = true
+	}
+	checked := false
+	depth := 0
+	for offset := 0; offset < len(code); offset += 16 {
+		tag := binary.LittleEndian.Uint64(code[offset:])
+		value := binary.LittleEndian.Uint64(code[offset+8:])
+		t := gruelparser.TokenType(tag & 0xff)
+		site := tag >> 32
+		if site > uint64(len(b.sites)) {
+			return fmt.Errorf("invalid site at %d", offset)
+		}
+		pops := 0
+		switch t {
+		case gruelparser.TypeParenthesis:
+			arity, ok := operatorArity[value]
+			if relocated[offset] {
+				arity, ok = intrinsics[value]
+			} else if value >= FirstIntrinsic {
+				ok = false
+			}
+			check := byte(tag >> 8)
+			if !ok || check > CheckNaN && check != CheckOverflow || (check == CheckNone) != (site == 0) {
+				return fmt.Errorf("invalid operator at %d", offset)
+			}
+			checked = checked || check != CheckNone
+			pops = arity
+		case gruelparser.TypeSymbol:
+			if value >= uint64(b.argc) {
+				return fmt.Errorf("invalid symbol at %d", offset)
+			}
+		case typeString:
+			if !relocated[offset] {
+				return fmt.Errorf("unrelocated pointer at %d", offset)
+			}
+		case typeScratch:
+			if value%8 != 0 || value/8+scratchWords > uint64(b.scratch) {
+				return fmt.Errorf("invalid scratch buffer at %d", offset)
+			}
+		case typeHost:
+			argc := int(tag >> 8 & 0xff)
+			if !hosts || site == 0 || !relocated[offset-16] || !valueType(gruelparser.TokenType(tag>>16&0xff)) ||
+				value%8 != 0 || value/8+uint64(hostFrameWords+argc) > uint64(b.scratch) || b.scratch < 2 {
+				return fmt.Errorf("invalid host call at %d", offset)
+			}
+			pops = argc + 1
+		default:
+			if !valueType(t) || relocated[offset] && t != typeInt {
+				return fmt.Errorf("invalid instruction at %d", offset)
+			}
+		}
+		if depth < pops {
+			return fmt.Errorf("stack underflow at %d", offset)
+		}
+		depth -= pops - 1
+		if b.maxStack < 8*depth {
+			b.maxStack = 8 * depth
+		}
+	}
+	if depth != 1 || checked && b.scratch < 1 || !valueType(b.types[0]) {
+		return fmt.Errorf("invalid bytecode")
+	}
+	return nil
+}
+
+func sameTypes(a, b []gruelparser.TokenType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *encoder) string(s string) {
+	e.uint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) signature(args []gruelparser.TokenType, result gruelparser.TokenType) {
+	e.uint(uint64(len(args)))
+	for _, arg := range args {
+		e.uint(uint64(arg))
+	}
+	e.uint(uint64(result))
+}
+
+// Decodes bytecode, remembering the first error
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) uint() uint64 {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = fmt.Errorf("truncated bytecode")
+		d.data = nil
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+// Decodes a length, which never exceeds the limit
+func (d *decoder) count(limit int) int {
+	v := d.uint()
+	if v > uint64(limit) {
+		d.err = fmt.Errorf("invalid length")
+		d.data = nil
+		return 0
+	}
+	return int(v)
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if n > len(d.data) {
+		d.err = fmt.Errorf("truncated bytecode")
+		d.data = nil
+		return nil
+	}
+	v := d.data[:n]
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes(d.count(len(d.data))))
+}
+
+func (d *decoder) signature() ([]gruelparser.TokenType, gruelparser.TokenType) {
+	args := make([]gruelparser.TokenType, d.count(MaxIntrinsicArgs*32))
+	for i := range args {
+		args[i] = gruelparser.TokenType(d.uint())
+	}
+	return args, gruelparser.TokenType(d.uint())
+}
@@ -9,14 +9,22 @@ import (
 	"fmt"
 	"math"
 	"reflect"
-	"strconv"
+	"strings"
+	"time"
 	"unsafe"
 
 	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/pkg/decimal"
 )
 
 type GoString [2]uint64
 
+// Hidden operands pointing into the scratch buffer, see GTYPE_SCRATCH
+const typeScratch gruelparser.TokenType = 0x7f
+
+// Words reserved per formatting operator: a GoString and up to 32 bytes
+const scratchWords = 6
+
 // Builds byte code
 type IrBuilder struct {
 	b     bytes.Buffer
@@ -33,20 +41,79 @@ type IrBuilder struct {
 	// Stack space needed, in bytes
 	maxStack     int
 	currentStack int
+	// Types of values on the stack
+	types []gruelparser.TokenType
+	// Offsets of untyped constants on the stack in the code, -1 for others
+	constants []int
+	// Scratch space needed by intrinsics, in words
+	scratch int
+	options Options
+	// Time zone transitions for calendar operators, built lazily
+	zone []int64
+	// The source position of the node being compiled
+	pos int
+	// Operators that may fail at runtime, see Sites
+	sites []Site
+	// Process-specific values in the code, see Marshal
+	relocations []relocation
+	hosts       []string
+	intrinsics  []string
+}
+
+// Runtime checks of operators, see `enum Check` in gruel_jit.h
+const (
+	CheckNone byte = iota
+	// Integer division by zero
+	CheckDivisor
+	// Negative shift counts or counts not less than the width
+	CheckShift
+	// NaN results, only checked with Options.StrictNaN
+	CheckNaN
+	// Errors returned by host functions
+	CheckHost
+	// Decimal results out of range, see overflowOpcodes
+	CheckOverflow
+)
+
+// An operator that may fail at runtime
+type Site struct {
+	Operator string
+	// The byte offset in the source
+	Pos   int
+	Check byte
+}
+
+// Compilation options
+type Options struct {
+	// The time zone for timestamp literals and calendar operators (UTC if nil)
+	Location *time.Location
+	// Skips runtime checks, so that integer division by zero yields 0 for example
+	Lenient bool
+	// Reports NaN results of floating point operators as runtime errors
+	StrictNaN bool
+	// Host functions callable like operators
+	Functions map[string]HostFunction
+}
+
+// Layouts accepted by timestamp literals, tried in order
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
 }
 
 func (b *IrBuilder) Push(value string, t gruelparser.TokenType, argc int) error {
 	if b.final {
 		return fmt.Errorf("code already finalized")
 	}
-
-	b.currentStack += 8
-	if b.maxStack < b.currentStack {
-		b.maxStack = b.currentStack
+	if t == gruelparser.TypeParenthesis {
+		return b.pushOperator(value, argc)
 	}
+	b.grow(8)
 
 	var output uint64
-	tType := uint64(t)
 	switch t {
 	case gruelparser.TypeBool:
 		if value == "true" {
@@ -55,35 +122,49 @@ func (b *IrBuilder) Push(value string, t gruelparser.TokenType, argc int) error
 			output = 0
 		}
 	case gruelparser.TypeFloat:
-		v, err := strconv.ParseFloat(value, 64)
+		v, err := gruelparser.ParseFloat(value)
 		if err != nil {
 			return err
 		}
 		output = math.Float64bits(v)
-	case gruelparser.TypeInt:
-		v, err := strconv.ParseInt(value, 0, 64)
-		if rangeErr := err; rangeErr != nil {
-			output, err = strconv.ParseUint(value, 0, 64)
-			if err != nil {
-				return rangeErr
-			}
-		} else {
-			output = uint64(v)
+	case gruelparser.TypeInt, gruelparser.TypeUint64:
+		// Unsigned literals like 10u are typed, like uint64(10) in Go.
+		v, err := gruelparser.ParseInt(value)
+		if err != nil {
+			return err
 		}
+		output = v
 	case gruelparser.TypeString:
 		length := len(value)
 		if length >= math.MaxInt32-2 {
 			return fmt.Errorf("string too large")
 		}
-		b.objects = append(b.objects, value)
-		hdr := (*reflect.StringHeader)(unsafe.Pointer(&value))
-		s := &GoString{uint64(hdr.Data), uint64(length)}
-		b.strings.PushBack(s)
-		output = uint64(uintptr(unsafe.Pointer(&s[0])))
+		output = b.pool(value)
+		b.relocate(relocString, len(b.objects)-1)
+	case gruelparser.TypeTime:
+		v, err := b.parseTime(value)
+		if err != nil {
+			return err
+		}
+		output = uint64(v.UnixNano())
+	case gruelparser.TypeDuration:
+		v, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		output = uint64(v)
+	case gruelparser.TypeDecimal:
+		v, err := decimal.Parse(strings.ReplaceAll(value, "_", ""))
+		if err != nil {
+			return err
+		}
+		output = uint64(v)
 	case gruelparser.TypeSymbol:
 		var ok bool
-		_, ok = b.symbols[value]
+		var symbolType byte
+		symbolType, ok = b.symbols[value]
 		if ok {
+			b.types = append(b.types, gruelparser.TokenType(symbolType))
 			index, ok := b.argv[value]
 			if ok {
 				output = uint64(index)
@@ -95,42 +176,257 @@ func (b *IrBuilder) Push(value string, t gruelparser.TokenType, argc int) error
 		} else {
 			return fmt.Errorf("symbol %s not found", value)
 		}
-	case gruelparser.TypeParenthesis:
-		if op := findOperator(value, argc); op == nil {
-			return fmt.Errorf("operator %s not found", value)
-		} else {
-			output = uint64(op.Opcode)
-			// Handling `(+ 1 2 3 4 5 ...)`
-			for argc > op.Argc {
-				binary.Write(&b.b, binary.LittleEndian, &tType)
-				binary.Write(&b.b, binary.LittleEndian, &output)
-				b.currentStack -= 8
-				argc--
-			}
-			b.currentStack -= 16
-		}
 	}
-	binary.Write(&b.b, binary.LittleEndian, &tType)
-	binary.Write(&b.b, binary.LittleEndian, &output)
+	if t != gruelparser.TypeSymbol {
+		b.types = append(b.types, t)
+	}
+	constant := -1
+	if t == gruelparser.TypeBool || t == gruelparser.TypeInt || t == gruelparser.TypeFloat {
+		constant = b.b.Len()
+	}
+	b.constants = append(b.constants, constant)
+	b.emit(t, output)
 	return nil
 }
 
-func findOperator(name string, argc int) *Operator {
+// Emits an operator application
+//
+// Operators with more arguments than they accept are folded from left to right,
+// that is, `(+ a b c)` is compiled as `(+ (+ a b) c)`.
+func (b *IrBuilder) pushOperator(name string, argc int) error {
 	ops, ok := Operators[name]
-	if ok {
-		var bi_op *Operator
-		for _, op := range ops {
-			if op.Argc == argc {
-				return &op
+	if !ok {
+		if intrinsic, ok := lookupIntrinsic(name); ok {
+			// Host functions registered before an intrinsic of the same name
+			// would otherwise be shadowed silently.
+			if _, ok := b.options.Functions[name]; ok {
+				return fmt.Errorf("operator %s is both an intrinsic and a host function", name)
+			}
+			return b.pushIntrinsic(name, intrinsic, argc)
+		}
+		if fn, ok := b.options.Functions[name]; ok {
+			return b.pushHost(name, fn, argc)
+		}
+		return fmt.Errorf("operator %s not found", name)
+	}
+	if argc == 0 || argc > len(b.types) {
+		return fmt.Errorf("operator %s expects arguments", name)
+	}
+	args := make([]gruelparser.TokenType, argc)
+	constants := make([]int, argc)
+	for i := range args {
+		args[i] = b.types[len(b.types)-1-i]
+		constants[i] = b.constants[len(b.constants)-1-i]
+	}
+
+	arity, steps := argc, 1
+	if !hasArity(ops, argc) {
+		if argc < 2 || !hasArity(ops, 2) {
+			return fmt.Errorf("operator %s does not accept %d arguments", name, argc)
+		}
+		arity, steps = 2, argc-1
+	}
+	result := args[0]
+	for i := 0; i < steps; i++ {
+		operands := args
+		if arity != argc {
+			operands = []gruelparser.TokenType{result, args[i+1]}
+			if i != 0 {
+				constants[i] = -1
+			}
+			if err := b.adapt(name, operands, constants[i:i+2]); err != nil {
+				return err
 			}
-			if op.Argc == 2 {
-				bi_op = &op
+		} else if arity == 2 {
+			if err := b.adapt(name, operands, constants); err != nil {
+				return err
 			}
 		}
-		return bi_op
-	} else {
+		t, err := resultType(name, operands)
+		if err != nil {
+			return err
+		}
+		op := findOperator(ops, operands)
+		if op == nil {
+			return mismatch(name, operands[0], operands[len(operands)-1])
+		}
+		switch op.JitFunction[0] {
+		case '@':
+			// Calendar operators take the time zone as a hidden argument.
+			b.grow(8)
+			b.relocate(relocZone, 0)
+			b.emit(gruelparser.TypeString, uint64(uintptr(unsafe.Pointer(&b.zoneTable()[0]))))
+			b.currentStack -= 8
+		case '&':
+			// Formatting operators write into a per-call scratch buffer.
+			b.grow(8)
+			b.emit(typeScratch, uint64(8*b.scratch))
+			b.scratch += scratchWords
+			b.currentStack -= 8
+		}
+		b.emitOperator(name, op, operands, t)
+		b.currentStack -= 8 * (arity - 1)
+		if cast, ok := sizedCasts[t]; ok && castTypes[name] == 0 {
+			// LibJIT promotes small integers, so results are wrapped explicitly.
+			b.emit(gruelparser.TypeParenthesis, uint64(Operators[cast][0].Opcode))
+		}
+		result = t
+	}
+	b.types = append(b.types[:len(b.types)-argc], result)
+	b.constants = append(b.constants[:len(b.constants)-argc], -1)
+	return nil
+}
+
+// Converts an untyped constant operand into the sized type of the other one
+//
+// The constant instruction is rewritten in place, so that LibJIT sees
+// a constant of the right type.
+func (b *IrBuilder) adapt(name string, operands []gruelparser.TokenType, constants []int) error {
+	if shiftOps[name] || (constants[0] < 0) == (constants[1] < 0) {
+		return nil
+	}
+	i := 0
+	if constants[1] >= 0 {
+		i = 1
+	}
+	from, to := operands[i], operands[1-i]
+	if !isSized(to) {
 		return nil
 	}
+	if !b.rewriteConstant(constants[i], from, to) {
+		return fmt.Errorf("constant of type %s cannot be used as %s", typeName(from), typeName(to))
+	}
+	operands[i] = to
+	return nil
+}
+
+// Rewrites the constant instruction at the offset into another type
+func (b *IrBuilder) rewriteConstant(offset int, from, to gruelparser.TokenType) bool {
+	code := b.b.Bytes()[offset:]
+	value, ok := adaptConstant(from, to, binary.LittleEndian.Uint64(code[8:]))
+	if ok {
+		binary.LittleEndian.PutUint64(code, uint64(to))
+		binary.LittleEndian.PutUint64(code[8:], value)
+	}
+	return ok
+}
+
+// Writes an operator instruction along with its runtime check
+//
+// Checked operators carry the check in bits 8-15 of the type word
+// and the 1-based site index in bits 32-63.
+func (b *IrBuilder) emitOperator(name string, op *Operator, operands []gruelparser.TokenType,
+	result gruelparser.TokenType) {
+	check := CheckNone
+	switch {
+	case b.options.Lenient:
+	case (name == "/" || name == "%") && op.Argc == 2 &&
+		operands[1] != typeFloat && operands[1] != typeFloat32:
+		check = CheckDivisor
+	case shiftOps[name]:
+		check = CheckShift
+	case overflowOpcodes[op.Opcode]:
+		check = CheckOverflow
+	case b.options.StrictNaN && (result == typeFloat || result == typeFloat32):
+		check = CheckNaN
+	}
+	if check == CheckNone {
+		b.emit(gruelparser.TypeParenthesis, uint64(op.Opcode))
+		return
+	}
+	b.sites = append(b.sites, Site{Operator: name, Pos: b.pos, Check: check})
+	b.write(uint64(len(b.sites))<<32|uint64(check)<<8|uint64(gruelparser.TypeParenthesis),
+		uint64(op.Opcode))
+}
+
+// Writes an instruction
+func (b *IrBuilder) emit(t gruelparser.TokenType, value uint64) {
+	b.write(uint64(t), value)
+}
+
+func (b *IrBuilder) write(tag uint64, value uint64) {
+	binary.Write(&b.b, binary.LittleEndian, &tag)
+	binary.Write(&b.b, binary.LittleEndian, &value)
+}
+
+// Reserves stack space
+func (b *IrBuilder) grow(size int) {
+	b.currentStack += size
+	if b.maxStack < b.currentStack {
+		b.maxStack = b.currentStack
+	}
+}
+
+func (b *IrBuilder) parseTime(value string) (time.Time, error) {
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		t, err = time.ParseInLocation(layout, value, b.location())
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+func (b *IrBuilder) location() *time.Location {
+	if b.options.Location == nil {
+		return time.UTC
+	}
+	return b.options.Location
+}
+
+func (b *IrBuilder) zoneTable() []int64 {
+	if b.zone == nil {
+		b.zone = zoneTable(b.location())
+	}
+	return b.zone
+}
+
+func hasArity(ops []Operator, argc int) bool {
+	for i := range ops {
+		if ops[i].Argc == argc {
+			return true
+		}
+	}
+	return false
+}
+
+// Picks the overload matching the argument types
+//
+// Operators with Argf only accept exactly those types,
+// and are preferred over generic ones.
+func findOperator(ops []Operator, args []gruelparser.TokenType) *Operator {
+	var generic *Operator
+	for i := range ops {
+		op := &ops[i]
+		if op.Argc != len(args) {
+			continue
+		}
+		if op.Argf == nil {
+			if generic == nil {
+				generic = op
+			}
+			continue
+		}
+		matched := true
+		for j, t := range op.Argf {
+			matched = matched && t == byte(args[j])
+		}
+		if matched {
+			return op
+		}
+	}
+	return generic
+}
+
+// Keeps the string alive, returning a pointer to its GoString
+func (b *IrBuilder) pool(value string) uint64 {
+	b.objects = append(b.objects, value)
+	hdr := (*reflect.StringHeader)(unsafe.Pointer(&value))
+	s := &GoString{uint64(hdr.Data), uint64(hdr.Len)}
+	b.strings.PushBack(s)
+	return uint64(uintptr(unsafe.Pointer(&s[0])))
 }
 
 func (b *IrBuilder) Finalize() {
@@ -168,10 +464,47 @@ func (b *IrBuilder) ArgMap() map[string]int {
 // It's fortunate that Go's GC does not move objects.
 func (b *IrBuilder) References() any {
 	b.Finalize()
-	if len(b.objects) == 0 && b.strings.Len() == 0 {
+	if len(b.objects) == 0 && b.strings.Len() == 0 && b.zone == nil {
 		return nil
 	}
-	return []any{b.objects, b.strings}
+	return []any{b.objects, b.strings, b.zone}
+}
+
+// Scratch space needed by each call, in words
+//
+// The first word is the error slot if the program has runtime checks
+// or host calls, and the second one holds the host call context in the latter case.
+func (b *IrBuilder) ScratchSize() int {
+	return b.scratch
+}
+
+// Operators with runtime checks, indexed by the site numbers minus one
+//
+// Failed checks store `site<<8 | check` into the error slot.
+func (b *IrBuilder) Sites() []Site {
+	return b.sites
+}
+
+// Values of string constants, keyed by the offsets of their instructions
+func (b *IrBuilder) Strings() map[int]string {
+	values := make(map[int]string)
+	for _, r := range b.relocations {
+		if r.kind == relocString {
+			values[r.offset] = b.objects[r.index]
+		}
+	}
+	return values
+}
+
+// The type of the result
+func (b *IrBuilder) ResultType() gruelparser.TokenType {
+	b.Finalize()
+	return b.types[len(b.types)-1]
+}
+
+// The time zone used by the program
+func (b *IrBuilder) Location() *time.Location {
+	return b.location()
 }
 
 // Needed stack space, in bytes
@@ -192,6 +525,13 @@ func (b *IrBuilder) StringArgc() int {
 }
 
 func (b *IrBuilder) Append(ast *gruelparser.GruelAstNode) error {
+	if ast.Type == gruelparser.TypeParenthesis && ast.Value == "get" {
+		path, err := FieldPath(ast)
+		if err != nil {
+			return err
+		}
+		return b.Push(path, gruelparser.TypeSymbol, 0)
+	}
 	if ast.Parameters != nil {
 		for i := len(ast.Parameters) - 1; i >= 0; i-- {
 			if err := b.Append(&ast.Parameters[i]); err != nil {
@@ -199,30 +539,178 @@ func (b *IrBuilder) Append(ast *gruelparser.GruelAstNode) error {
 			}
 		}
 	}
+	b.pos = ast.Pos
 	return b.Push(ast.Value, ast.Type, len(ast.Parameters))
 }
 
+// Whether the program may need the error slot
+func hasChecks(ast *gruelparser.GruelAstNode, options Options) bool {
+	if ast.Type != gruelparser.TypeParenthesis || options.Lenient {
+		return false
+	}
+	// Multiplications and conversions may be decimal ones.
+	if options.StrictNaN || ast.Value == "/" || ast.Value == "%" || shiftOps[ast.Value] ||
+		ast.Value == "*" || ast.Value == "->decimal" {
+		return true
+	}
+	for i := range ast.Parameters {
+		if hasChecks(&ast.Parameters[i], options) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolves `(get record "field" ...)` into a dotted symbol path
+//
+// Nested forms like `(get (get user "address") "city")` are flattened
+// into "user.address.city", so that every field gets its own parameter slot.
+func FieldPath(ast *gruelparser.GruelAstNode) (string, error) {
+	if len(ast.Parameters) < 2 {
+		return "", fmt.Errorf("get expects a record and field names")
+	}
+	var path string
+	record := &ast.Parameters[0]
+	switch {
+	case record.Type == gruelparser.TypeSymbol:
+		path = record.Value
+	case record.Type == gruelparser.TypeParenthesis && record.Value == "get":
+		inner, err := FieldPath(record)
+		if err != nil {
+			return "", err
+		}
+		path = inner
+	default:
+		return "", fmt.Errorf("get expects a record")
+	}
+	for _, field := range ast.Parameters[1:] {
+		if field.Type != gruelparser.TypeString || field.Value == "" {
+			return "", fmt.Errorf("get expects constant field names")
+		}
+		path += "." + field.Value
+	}
+	return path, nil
+}
+
 type CompiledChunk struct {
 	Code       []byte
 	Parameters []gruelparser.TokenType
 }
 
 // Compiles the AST into byte codes
-func Compile(ast *gruelparser.GruelAstNode, symbols map[string]byte) (*IrBuilder, error) {
+func Compile(ast *gruelparser.GruelAstNode, symbols map[string]byte, options Options) (*IrBuilder, error) {
 	for k, vb := range symbols {
 		v := gruelparser.TokenType(vb)
-		if v != gruelparser.TypeBool && v != gruelparser.TypeInt &&
-			v != gruelparser.TypeFloat && v != gruelparser.TypeString {
+		if !isNumeric(v) && v != typeString && !isOpaque(v) {
 			return nil, fmt.Errorf("symbol %s must have a value type", k)
 		}
+		// Fields of nested records are declared as dotted paths like "user.age".
+		fields := strings.Split(k, ".")
+		for i, field := range fields {
+			if field == "" {
+				return nil, fmt.Errorf("symbol %s has an empty field name", k)
+			}
+			if i > 0 {
+				record := strings.Join(fields[:i], ".")
+				if _, ok := symbols[record]; ok {
+					return nil, fmt.Errorf("symbol %s conflicts with record %s", k, record)
+				}
+			}
+		}
 	}
 
 	b := IrBuilder{
 		symbols: symbols,
 		argv:    make(map[string]int, len(symbols)),
+		options: options,
+	}
+	switch {
+	case hasHostCalls(ast, options):
+		// Reserves the error slot and the host call context.
+		b.scratch = 2
+	case hasChecks(ast, options):
+		// Reserves the error slot.
+		b.scratch = 1
 	}
 	if err := b.Append(ast); err != nil {
 		return nil, err
 	}
 	return &b, nil
 }
+
+// A decoded instruction, see IrBuilder.Instructions
+type Instruction struct {
+	// The type of the value, TypeParenthesis for operators, or TypeSymbol for parameters
+	Type gruelparser.TokenType
+	// Constant bits, parameter indices or opcodes
+	Value uint64
+	// Built-in operators and their names, nil for intrinsics and other instructions
+	Name     string
+	Operator *Operator
+	// The runtime check of operators and host calls, and its 1-based index into Sites
+	Check byte
+	Site  int
+	// Calls to host functions, see IrBuilder.pushHost
+	Host bool
+	// Hidden operands, like time zone tables and scratch buffers
+	Hidden bool
+	// The value of string constants
+	String string
+}
+
+// Decodes the code, for backends other than LibJIT
+func (b *IrBuilder) Instructions() []Instruction {
+	code := b.Code()
+	strings := b.Strings()
+	instructions := make([]Instruction, 0, len(code)/16)
+	for pc := 0; pc < len(code); pc += 16 {
+		tag := binary.LittleEndian.Uint64(code[pc:])
+		in := Instruction{
+			Type:  gruelparser.TokenType(tag & 0xff),
+			Value: binary.LittleEndian.Uint64(code[pc+8:]),
+		}
+		switch in.Type {
+		case gruelparser.TypeParenthesis:
+			in.Check = byte(tag >> 8)
+			in.Site = int(tag >> 32)
+			in.Name, in.Operator = builtinOperator(in.Value)
+		case gruelparser.TypeString:
+			s, ok := strings[pc]
+			in.String = s
+			in.Hidden = !ok
+		case typeScratch:
+			in.Hidden = true
+		case typeHost:
+			in.Host = true
+			in.Check = CheckHost
+			in.Site = int(tag >> 32)
+		}
+		instructions = append(instructions, in)
+	}
+	return instructions
+}
+
+// Operators indexed by opcodes
+var operatorsByOpcode = func() map[uint64]string {
+	names := make(map[uint64]string)
+	for name, ops := range Operators {
+		for _, op := range ops {
+			names[uint64(op.Opcode)] = name
+		}
+	}
+	return names
+}()
+
+func builtinOperator(opcode uint64) (string, *Operator) {
+	name, ok := operatorsByOpcode[opcode]
+	if !ok {
+		return "", nil
+	}
+	ops := Operators[name]
+	for i := range ops {
+		if uint64(ops[i].Opcode) == opcode {
+			return name, &ops[i]
+		}
+	}
+	return "", nil
+}
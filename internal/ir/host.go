@@ -0,0 +1,87 @@
+package ir
+
+import (
+	"fmt"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+)
+
+// Hidden instructions calling host functions, see GTYPE_HOST
+const typeHost gruelparser.TokenType = 0x7e
+
+// Words of a host call frame before the arguments:
+// the function handle, the call context, the status and the result
+const hostFrameWords = 4
+
+// A Go function callable from rules, see grueljit.Registry
+type HostFunction struct {
+	Args   []gruelparser.TokenType
+	Result gruelparser.TokenType
+	// Passed back to the trampoline to find the Go function
+	Handle uint64
+}
+
+// Emits a call to a host function
+//
+// The type word carries the argument count in bits 8-15,
+// the result type in bits 16-23 and the site in bits 32-63.
+// The value is the offset of the call frame in the scratch buffer.
+func (b *IrBuilder) pushHost(name string, fn HostFunction, argc int) error {
+	if argc != len(fn.Args) {
+		return fmt.Errorf("function %s expects %d arguments", name, len(fn.Args))
+	}
+	if argc > len(b.types) {
+		return fmt.Errorf("function %s expects arguments", name)
+	}
+	if err := b.convertArgs("function "+name, fn.Args); err != nil {
+		return err
+	}
+
+	b.grow(8)
+	b.relocate(relocHost, nameIndex(&b.hosts, name))
+	b.emit(gruelparser.TypeInt, fn.Handle)
+	b.sites = append(b.sites, Site{Operator: name, Pos: b.pos, Check: CheckHost})
+	b.write(uint64(len(b.sites))<<32|uint64(fn.Result)<<16|uint64(argc)<<8|uint64(typeHost),
+		uint64(8*b.scratch))
+	b.scratch += hostFrameWords + argc
+	b.currentStack -= 8 * argc
+
+	b.types = append(b.types[:len(b.types)-argc], fn.Result)
+	b.constants = append(b.constants[:len(b.constants)-argc], -1)
+	return nil
+}
+
+// Whether the program calls host functions
+func hasHostCalls(ast *gruelparser.GruelAstNode, options Options) bool {
+	if ast.Type != gruelparser.TypeParenthesis {
+		return false
+	}
+	if _, ok := options.Functions[ast.Value]; ok && !Reserved(ast.Value) {
+		return true
+	}
+	for i := range ast.Parameters {
+		if hasHostCalls(&ast.Parameters[i], options) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks the arguments on the stack against the expected types
+//
+// Untyped constants are converted, other arguments must match exactly.
+func (b *IrBuilder) convertArgs(subject string, expected []gruelparser.TokenType) error {
+	for i, arg := range expected {
+		t := &b.types[len(b.types)-1-i]
+		if *t == arg {
+			continue
+		}
+		constant := b.constants[len(b.constants)-1-i]
+		if constant < 0 || !b.rewriteConstant(constant, *t, arg) {
+			return fmt.Errorf("%s expects %s as argument %d, got %s",
+				subject, typeName(arg), i+1, typeName(*t))
+		}
+		*t = arg
+	}
+	return nil
+}
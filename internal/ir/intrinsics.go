@@ -0,0 +1,133 @@
+package ir
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+)
+
+// Opcodes available to intrinsics registered at runtime, see `intrinsics` in gruel_jit.c
+const (
+	FirstIntrinsic = 0x100
+	MaxIntrinsics  = 0x100
+	// The maximum argument count of intrinsics
+	MaxIntrinsicArgs = 8
+)
+
+// A native function registered at runtime and called like an operator
+type Intrinsic struct {
+	Opcode int
+	Args   []gruelparser.TokenType
+	Result gruelparser.TokenType
+}
+
+var intrinsics = struct {
+	sync.RWMutex
+	byName   map[string]Intrinsic
+	byOpcode map[int]string
+}{
+	byName:   make(map[string]Intrinsic),
+	byOpcode: make(map[int]string),
+}
+
+// Checks that opcodes of operators do not conflict
+func ValidateOperators(operators map[string][]Operator) error {
+	opcodes := make(map[int]string)
+	for name, ops := range operators {
+		for _, op := range ops {
+			other, ok := opcodes[op.Opcode]
+			if ok {
+				return fmt.Errorf("conflicting opcode for %s and %s", name, other)
+			}
+			if op.Opcode >= FirstIntrinsic {
+				return fmt.Errorf("opcode of %s reserved for intrinsics", name)
+			}
+			opcodes[op.Opcode] = name
+		}
+	}
+	return nil
+}
+
+// Whether the name is taken by an operator, a special form or an intrinsic
+func Reserved(name string) bool {
+	if builtin(name) {
+		return true
+	}
+	_, ok := lookupIntrinsic(name)
+	return ok
+}
+
+func builtin(name string) bool {
+	_, ok := Operators[name]
+	return ok || name == "get" || name == ""
+}
+
+// Registers an intrinsic
+//
+// Install is called before the intrinsic becomes visible to the compiler,
+// so that the backend may set up the opcode. Intrinsics cannot be unregistered.
+func RegisterIntrinsic(name string, intrinsic Intrinsic, install func() error) error {
+	intrinsics.Lock()
+	defer intrinsics.Unlock()
+	if _, ok := intrinsics.byName[name]; ok || builtin(name) {
+		return fmt.Errorf("operator %s already defined", name)
+	}
+	if intrinsic.Opcode < FirstIntrinsic || intrinsic.Opcode >= FirstIntrinsic+MaxIntrinsics {
+		return fmt.Errorf("opcode %#x of %s out of range", intrinsic.Opcode, name)
+	}
+	if other, ok := intrinsics.byOpcode[intrinsic.Opcode]; ok {
+		return fmt.Errorf("conflicting opcode for %s and %s", name, other)
+	}
+	if len(intrinsic.Args) == 0 || len(intrinsic.Args) > MaxIntrinsicArgs {
+		return fmt.Errorf("intrinsic %s expects 1 to %d arguments", name, MaxIntrinsicArgs)
+	}
+	for _, t := range intrinsic.Args {
+		if !valueType(t) {
+			return fmt.Errorf("intrinsic %s has an invalid argument type %d", name, t)
+		}
+	}
+	if !valueType(intrinsic.Result) {
+		return fmt.Errorf("intrinsic %s has an invalid result type %d", name, intrinsic.Result)
+	}
+	if err := install(); err != nil {
+		return err
+	}
+	intrinsic.Args = append([]gruelparser.TokenType(nil), intrinsic.Args...)
+	intrinsics.byName[name] = intrinsic
+	intrinsics.byOpcode[intrinsic.Opcode] = name
+	return nil
+}
+
+// Whether values of the type may be passed around
+func valueType(t gruelparser.TokenType) bool {
+	return t != gruelparser.TypeParenthesis && t != gruelparser.TypeSymbol &&
+		gruelparser.TypeBool <= t && t <= typeFloat32
+}
+
+func lookupIntrinsic(name string) (Intrinsic, bool) {
+	intrinsics.RLock()
+	defer intrinsics.RUnlock()
+	intrinsic, ok := intrinsics.byName[name]
+	return intrinsic, ok
+}
+
+// Emits a call to an intrinsic
+//
+// Arguments must match exactly, except for untyped constants.
+func (b *IrBuilder) pushIntrinsic(name string, intrinsic Intrinsic, argc int) error {
+	if argc != len(intrinsic.Args) || argc > len(b.types) {
+		return fmt.Errorf("operator %s does not accept %d arguments", name, argc)
+	}
+	if err := b.convertArgs("operator "+name, intrinsic.Args); err != nil {
+		return err
+	}
+	op := &Operator{Opcode: intrinsic.Opcode, Argc: argc}
+	b.relocate(relocIntrinsic, nameIndex(&b.intrinsics, name))
+	b.emitOperator(name, op, intrinsic.Args, intrinsic.Result)
+	b.currentStack -= 8 * (argc - 1)
+
+	b.types = append(b.types[:len(b.types)-argc], intrinsic.Result)
+	b.constants = append(b.constants[:len(b.constants)-argc], -1)
+	return nil
+}
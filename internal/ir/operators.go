@@ -1,5 +1,18 @@
 package ir
 
+import "github.com/yesh0/gruel/internal/gruelparser"
+
+// Argument type shorthands for overloaded operators
+const (
+	argBool    = byte(gruelparser.TypeBool)
+	argInt     = byte(gruelparser.TypeInt)
+	argFloat   = byte(gruelparser.TypeFloat)
+	argString  = byte(gruelparser.TypeString)
+	argDecimal = byte(gruelparser.TypeDecimal)
+	argUint64  = byte(gruelparser.TypeUint64)
+	argFloat32 = byte(gruelparser.TypeFloat32)
+)
+
 type Operator struct {
 	Opcode int
 	// Argument count
@@ -10,21 +23,31 @@ type Operator struct {
 	// The libjit function to call
 	//
 	// - Prefix with ':' to indicate that it is an intrinsic function
+	// - Prefix with '@' to indicate that it is a calendar intrinsic,
+	//   which receives the time zone table as an extra argument
+	// - Prefix with '$' to indicate that it is a native function with
+	//   a full signature, e.g. "$i:i:f:func" for `jit_long func(jit_long, jit_float64)`
+	// - Prefix with '&' for native functions that also receive a scratch buffer
+	//   as their first argument, e.g. "&s:s:i:func" for `void *func(void *, jit_long)`
+	// - Prefix with '%' to indicate a conversion into a LibJIT type, e.g. "%sbyte"
 	JitFunction string
 }
 
+// Decimal multiplications and conversions, whose results may be out of range
+var overflowOpcodes = map[int]bool{0xa0: true, 0xa2: true, 0xa3: true}
+
 var Operators = map[string]([]Operator){
 	"+":   []Operator{{0x01, 2, nil, "jit_insn_add"}},
 	"-":   []Operator{{0x02, 2, nil, "jit_insn_sub"}, {0x03, 1, nil, "jit_insn_neg"}},
-	"*":   []Operator{{0x04, 2, nil, "jit_insn_mul"}},
-	"/":   []Operator{{0x05, 2, nil, "jit_insn_div"}},
+	"*":   []Operator{{0x04, 2, nil, "jit_insn_mul"}, {0xa0, 2, []byte{argDecimal, argDecimal}, "$i:i:i:gruel_dec_mul"}},
+	"/":   []Operator{{0x05, 2, nil, "jit_insn_div"}, {0xa1, 2, []byte{argDecimal, argDecimal}, "$i:i:i:gruel_dec_div"}},
 	"%":   []Operator{{0x06, 2, nil, "jit_insn_rem"}},
 	"&":   []Operator{{0x07, 2, nil, "jit_insn_and"}},
 	"|":   []Operator{{0x08, 2, nil, "jit_insn_or"}},
 	"^":   []Operator{{0x09, 2, nil, "jit_insn_xor"}, {0x0a, 1, nil, "jit_insn_not"}},
 	"<<":  []Operator{{0x0b, 2, nil, "jit_insn_shl"}},
-	">>":  []Operator{{0x0c, 2, nil, "jit_insn_shr"}},
-	">>>": []Operator{{0x0d, 2, nil, "jit_insn_ushr"}},
+	">>":  []Operator{{0x0c, 2, nil, "gruel_insn_shr"}},
+	">>>": []Operator{{0x0d, 2, nil, "gruel_insn_ushr"}},
 
 	"&&": []Operator{{0x20, 2, nil, "!jit_insn_and"}},
 	"||": []Operator{{0x21, 2, nil, "!jit_insn_or"}},
@@ -32,6 +55,53 @@ var Operators = map[string]([]Operator){
 	"len":   []Operator{{0x80, 1, nil, ":i:gruel_strlen"}},
 	"index": []Operator{{0x81, 2, nil, ":i:s:gruel_index_of"}},
 
+	"hour":         []Operator{{0x90, 1, nil, "@gruel_hour"}},
+	"minute":       []Operator{{0x91, 1, nil, "@gruel_minute"}},
+	"weekday":      []Operator{{0x92, 1, nil, "@gruel_weekday"}},
+	"day-of-month": []Operator{{0x93, 1, nil, "@gruel_day_of_month"}},
+	"month":        []Operator{{0x94, 1, nil, "@gruel_month"}},
+	"year":         []Operator{{0x95, 1, nil, "@gruel_year"}},
+
+	"->decimal": []Operator{
+		{0xa2, 1, []byte{argInt}, "$i:i:gruel_dec_from_int"},
+		{0xa3, 1, []byte{argFloat}, "$i:f:gruel_dec_from_float"},
+	},
+	"round-half-even": []Operator{{0xa4, 2, nil, "$i:i:i:gruel_dec_round_half_even"}},
+	"round-half-up":   []Operator{{0xa5, 2, nil, "$i:i:i:gruel_dec_round_half_up"}},
+	"round-down":      []Operator{{0xa6, 2, nil, "$i:i:i:gruel_dec_round_down"}},
+	"round-up":        []Operator{{0xa7, 2, nil, "$i:i:i:gruel_dec_round_up"}},
+	"round-floor":     []Operator{{0xa8, 2, nil, "$i:i:i:gruel_dec_round_floor"}},
+	"round-ceiling":   []Operator{{0xa9, 2, nil, "$i:i:i:gruel_dec_round_ceiling"}},
+
+	"->int": []Operator{
+		{0xb0, 1, nil, "%long"},
+		{0xb1, 1, []byte{argDecimal}, "$i:i:gruel_dec_to_int"},
+	},
+	"->uint": []Operator{{0xb2, 1, nil, "%ulong"}},
+	"->float": []Operator{
+		{0xb3, 1, nil, "%float64"},
+		{0xb4, 1, []byte{argDecimal}, "$f:i:gruel_dec_to_float"},
+	},
+	"->int8":    []Operator{{0xb5, 1, nil, "%sbyte"}},
+	"->int16":   []Operator{{0xb6, 1, nil, "%short"}},
+	"->int32":   []Operator{{0xb7, 1, nil, "%int"}},
+	"->int64":   []Operator{{0xb8, 1, nil, "%long"}},
+	"->uint8":   []Operator{{0xb9, 1, nil, "%ubyte"}},
+	"->uint16":  []Operator{{0xba, 1, nil, "%ushort"}},
+	"->uint32":  []Operator{{0xbb, 1, nil, "%uint"}},
+	"->uint64":  []Operator{{0xbc, 1, nil, "%ulong"}},
+	"->float32": []Operator{{0xbd, 1, nil, "%float32"}},
+	"->float64": []Operator{{0xbe, 1, nil, "%float64"}},
+	"->string": []Operator{
+		{0xc0, 1, nil, "&s:s:i:gruel_format_int"},
+		{0xc1, 1, []byte{argUint64}, "&s:s:u:gruel_format_uint"},
+		{0xc2, 1, []byte{argFloat}, "&s:s:f:gruel_format_float"},
+		{0xc3, 1, []byte{argFloat32}, "&s:s:F:gruel_format_float32"},
+		{0xc4, 1, []byte{argBool}, "&s:s:i:gruel_format_bool"},
+		{0xc5, 1, []byte{argDecimal}, "&s:s:i:gruel_format_decimal"},
+		{0xc6, 1, []byte{argString}, "%void_ptr"},
+	},
+
 	// python build/ir/gen_go.py >> internal/ir/operators.go
 	"=":       []Operator{{0x40, 2, nil, "gruel_insn_eq"}},
 	"==":      []Operator{{0x41, 2, nil, "gruel_insn_eq"}},
@@ -0,0 +1,385 @@
+package ir
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+)
+
+const (
+	typeBool     = gruelparser.TypeBool
+	typeInt      = gruelparser.TypeInt
+	typeFloat    = gruelparser.TypeFloat
+	typeString   = gruelparser.TypeString
+	typeTime     = gruelparser.TypeTime
+	typeDuration = gruelparser.TypeDuration
+	typeDecimal  = gruelparser.TypeDecimal
+	typeInt8     = gruelparser.TypeInt8
+	typeInt16    = gruelparser.TypeInt16
+	typeInt32    = gruelparser.TypeInt32
+	typeUint8    = gruelparser.TypeUint8
+	typeUint16   = gruelparser.TypeUint16
+	typeUint32   = gruelparser.TypeUint32
+	typeUint64   = gruelparser.TypeUint64
+	typeFloat32  = gruelparser.TypeFloat32
+)
+
+// Operators grouped by how their result types are inferred
+var (
+	arithmeticOps = names("+", "-", "*", "/", "%", "min", "max")
+	bitwiseOps    = names("&", "|", "^")
+	shiftOps      = names("<<", ">>", ">>>")
+	comparisonOps = names("<", "<=", ">", ">=", "cmpl", "cmpg")
+	equalityOps   = names("=", "==", "!=")
+	logicOps      = names("&&", "||", "!", "->bool", "nan?", "finite?", "inf?")
+	calendarOps   = names("hour", "minute", "weekday", "day-of-month", "month", "year")
+	roundingOps   = names("round-half-even", "round-half-up", "round-down", "round-up",
+		"round-floor", "round-ceiling")
+)
+
+// Result types of the numeric cast operators
+var castTypes = map[string]gruelparser.TokenType{
+	"->int":     typeInt,
+	"->int64":   typeInt,
+	"->uint":    typeUint64,
+	"->uint64":  typeUint64,
+	"->float":   typeFloat,
+	"->float64": typeFloat,
+	"->int8":    typeInt8,
+	"->int16":   typeInt16,
+	"->int32":   typeInt32,
+	"->uint8":   typeUint8,
+	"->uint16":  typeUint16,
+	"->uint32":  typeUint32,
+	"->float32": typeFloat32,
+}
+
+// The casts that wrap results into sized types
+var sizedCasts = map[gruelparser.TokenType]string{
+	typeInt8:    "->int8",
+	typeInt16:   "->int16",
+	typeInt32:   "->int32",
+	typeUint8:   "->uint8",
+	typeUint16:  "->uint16",
+	typeUint32:  "->uint32",
+	typeUint64:  "->uint64",
+	typeFloat32: "->float32",
+}
+
+func names(ops ...string) map[string]bool {
+	set := make(map[string]bool, len(ops))
+	for _, op := range ops {
+		set[op] = true
+	}
+	return set
+}
+
+func isNumeric(t gruelparser.TokenType) bool {
+	return t == typeBool || t == typeInt || t == typeFloat || isSized(t)
+}
+
+func isInteger(t gruelparser.TokenType) bool {
+	return t == typeBool || t == typeInt || isSized(t) && t != typeFloat32
+}
+
+// Numeric types with explicit sizes, which only mix with themselves
+func isSized(t gruelparser.TokenType) bool {
+	return typeInt8 <= t && t <= typeFloat32
+}
+
+func isTemporal(t gruelparser.TokenType) bool {
+	return t == typeTime || t == typeDuration
+}
+
+// Types that are stored as integers but do not mix with plain numbers
+func isOpaque(t gruelparser.TokenType) bool {
+	return isTemporal(t) || t == typeDecimal
+}
+
+// Promotes numeric types like LibJIT does
+func promote(name string, a, b gruelparser.TokenType) (gruelparser.TokenType, error) {
+	switch {
+	case a == b && isSized(a):
+		return a, nil
+	case isSized(a) || isSized(b):
+		return 0, mismatch(name, a, b)
+	case a == typeFloat || b == typeFloat:
+		return typeFloat, nil
+	default:
+		return typeInt, nil
+	}
+}
+
+// Infers the result type of a unary or binary operator
+func resultType(name string, args []gruelparser.TokenType) (gruelparser.TokenType, error) {
+	switch len(args) {
+	case 1:
+		return unaryType(name, args[0])
+	case 2:
+		return binaryType(name, args[0], args[1])
+	default:
+		return 0, fmt.Errorf("operator %s does not accept %d arguments", name, len(args))
+	}
+}
+
+// Infers the result type of an operator applied to the operand types, see Operators
+func OperatorType(name string, operands []gruelparser.TokenType) (gruelparser.TokenType, error) {
+	return resultType(name, operands)
+}
+
+// Names a type like in error messages
+func TypeName(t gruelparser.TokenType) string {
+	return typeName(t)
+}
+
+func unaryType(name string, t gruelparser.TokenType) (gruelparser.TokenType, error) {
+	switch {
+	case name == "len":
+		if t != typeString {
+			return 0, fmt.Errorf("operator len expects a string")
+		}
+		return typeInt, nil
+	case calendarOps[name]:
+		if t != typeTime {
+			return 0, fmt.Errorf("operator %s expects a time", name)
+		}
+		return typeInt, nil
+	case name == "->string":
+		if !isNumeric(t) && t != typeDecimal && t != typeString {
+			return 0, fmt.Errorf("operator %s does not accept %s", name, typeName(t))
+		}
+		return typeString, nil
+	case castTypes[name] != 0:
+		// Decimals only convert into ints (truncated) and floats
+		if !isNumeric(t) && !(t == typeDecimal && (name == "->int" || name == "->float")) {
+			return 0, fmt.Errorf("operator %s does not accept %s", name, typeName(t))
+		}
+		return castTypes[name], nil
+	case logicOps[name]:
+		if !isNumeric(t) {
+			return 0, fmt.Errorf("operator %s does not accept %s", name, typeName(t))
+		}
+		return typeBool, nil
+	case name == "->decimal":
+		if t != typeInt && t != typeFloat {
+			return 0, fmt.Errorf("operator %s does not accept %s", name, typeName(t))
+		}
+		return typeDecimal, nil
+	case name == "-" || name == "abs":
+		if t == typeDuration || t == typeDecimal {
+			return t, nil
+		}
+		if !isNumeric(t) {
+			return 0, fmt.Errorf("operator %s does not accept %s", name, typeName(t))
+		}
+		return promote(name, t, t)
+	case name == "^":
+		if !isNumeric(t) || t == typeFloat32 {
+			return 0, fmt.Errorf("operator %s does not accept %s", name, typeName(t))
+		}
+		if isSized(t) {
+			return t, nil
+		}
+		return typeInt, nil
+	case name == "sign":
+		if !isNumeric(t) && t != typeDecimal {
+			return 0, fmt.Errorf("operator %s does not accept %s", name, typeName(t))
+		}
+		return typeInt, nil
+	default:
+		if !isNumeric(t) {
+			return 0, fmt.Errorf("operator %s does not accept %s", name, typeName(t))
+		}
+		// Math functions from LibJIT
+		return typeFloat, nil
+	}
+}
+
+func binaryType(name string, a, b gruelparser.TokenType) (gruelparser.TokenType, error) {
+	switch {
+	case name == "index":
+		if a != typeString || b != typeString {
+			return 0, fmt.Errorf("operator index expects strings")
+		}
+		return typeInt, nil
+	case equalityOps[name]:
+		if isOpaque(a) || isOpaque(b) || isSized(a) || isSized(b) {
+			if a != b {
+				return 0, mismatch(name, a, b)
+			}
+		}
+		return typeBool, nil
+	case comparisonOps[name]:
+		if isNumeric(a) && isNumeric(b) || isOpaque(a) && a == b {
+			if _, err := promote(name, a, b); err != nil {
+				return 0, err
+			}
+			if name == "cmpl" || name == "cmpg" {
+				return typeInt, nil
+			}
+			return typeBool, nil
+		}
+		return 0, mismatch(name, a, b)
+	case logicOps[name]:
+		if isNumeric(a) && isNumeric(b) {
+			return typeBool, nil
+		}
+		return 0, mismatch(name, a, b)
+	case roundingOps[name]:
+		if a != typeDecimal || b != typeInt {
+			return 0, mismatch(name, a, b)
+		}
+		return typeDecimal, nil
+	case arithmeticOps[name] && (a == typeDecimal || b == typeDecimal):
+		return decimalType(name, a, b)
+	case arithmeticOps[name] && (isTemporal(a) || isTemporal(b)):
+		return temporalType(name, a, b)
+	case shiftOps[name] && (isSized(a) || isSized(b)):
+		// Shift counts may be of any integer type, like in Go
+		if !isInteger(a) || !isInteger(b) {
+			return 0, mismatch(name, a, b)
+		}
+		if isSized(a) {
+			return a, nil
+		}
+		return typeInt, nil
+	case isNumeric(a) && isNumeric(b):
+		switch {
+		case name == "atan2" || name == "pow" || name == "**":
+			return typeFloat, nil
+		case (bitwiseOps[name] || shiftOps[name]) && (!isInteger(a) || !isInteger(b)):
+			// LibJIT has no float instructions for them, and neither has Go.
+			return 0, mismatch(name, a, b)
+		case bitwiseOps[name] || shiftOps[name] || arithmeticOps[name]:
+			return promote(name, a, b)
+		default:
+			return 0, fmt.Errorf("operator %s expects one argument", name)
+		}
+	default:
+		return 0, mismatch(name, a, b)
+	}
+}
+
+// Arithmetic on timestamps and durations
+func temporalType(name string, a, b gruelparser.TokenType) (gruelparser.TokenType, error) {
+	integral := func(t gruelparser.TokenType) bool {
+		return t == typeInt || t == typeBool
+	}
+	switch name {
+	case "+":
+		switch {
+		case a == typeTime && b == typeDuration, a == typeDuration && b == typeTime:
+			return typeTime, nil
+		case a == typeDuration && b == typeDuration:
+			return typeDuration, nil
+		}
+	case "-":
+		switch {
+		case a == typeTime && b == typeTime:
+			return typeDuration, nil
+		case a == typeTime && b == typeDuration:
+			return typeTime, nil
+		case a == typeDuration && b == typeDuration:
+			return typeDuration, nil
+		}
+	case "*":
+		if a == typeDuration && integral(b) || integral(a) && b == typeDuration {
+			return typeDuration, nil
+		}
+	case "/":
+		switch {
+		case a == typeDuration && integral(b):
+			return typeDuration, nil
+		case a == typeDuration && b == typeDuration:
+			return typeInt, nil
+		}
+	case "%", "min", "max":
+		if a == b {
+			return a, nil
+		}
+	}
+	return 0, mismatch(name, a, b)
+}
+
+// Arithmetic on decimals
+func decimalType(name string, a, b gruelparser.TokenType) (gruelparser.TokenType, error) {
+	switch name {
+	case "*":
+		// Scaling by integers is exact.
+		if a == b || a == typeInt || b == typeInt {
+			return typeDecimal, nil
+		}
+	default:
+		if a == b {
+			return typeDecimal, nil
+		}
+	}
+	return 0, mismatch(name, a, b)
+}
+
+// Converts an untyped constant into a sized type, like Go does
+//
+// Returns false if the constant cannot be represented by the type.
+func adaptConstant(from, to gruelparser.TokenType, value uint64) (uint64, bool) {
+	if from == typeFloat {
+		if to != typeFloat32 {
+			return 0, false
+		}
+		return value, true
+	}
+	if from != typeInt && from != typeBool {
+		return 0, false
+	}
+	v := int64(value)
+	switch to {
+	case typeFloat:
+		return math.Float64bits(float64(v)), true
+	case typeInt8:
+		return value, math.MinInt8 <= v && v <= math.MaxInt8
+	case typeInt16:
+		return value, math.MinInt16 <= v && v <= math.MaxInt16
+	case typeInt32:
+		return value, math.MinInt32 <= v && v <= math.MaxInt32
+	case typeUint8:
+		return value, 0 <= v && v <= math.MaxUint8
+	case typeUint16:
+		return value, 0 <= v && v <= math.MaxUint16
+	case typeUint32:
+		return value, 0 <= v && v <= math.MaxUint32
+	case typeUint64:
+		// Literals beyond math.MaxInt64 need the u suffix.
+		return value, 0 <= v
+	case typeFloat32:
+		return math.Float64bits(float64(v)), true
+	default:
+		return 0, false
+	}
+}
+
+func mismatch(name string, a, b gruelparser.TokenType) error {
+	return fmt.Errorf("operator %s does not accept %s and %s", name, typeName(a), typeName(b))
+}
+
+func typeName(t gruelparser.TokenType) string {
+	switch t {
+	case typeBool:
+		return "bool"
+	case typeInt:
+		return "int"
+	case typeFloat:
+		return "float"
+	case typeString:
+		return "string"
+	case typeTime:
+		return "time"
+	case typeDuration:
+		return "duration"
+	case typeDecimal:
+		return "decimal"
+	}
+	if cast, ok := sizedCasts[t]; ok {
+		return cast[2:]
+	}
+	return fmt.Sprintf("type %d", t)
+}
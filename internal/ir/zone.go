@@ -0,0 +1,36 @@
+package ir
+
+import "time"
+
+// Range of the precomputed zone transitions
+var (
+	zoneStart = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+	zoneEnd   = time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// Flattens the offsets of a time zone into a table for the calendar intrinsics
+//
+// The layout is `{n, start_0, offset_0, ..., start_n-1, offset_n-1}`,
+// where the starts are in Unix seconds sorted ascendingly and offsets in seconds.
+// Times before the first start use the first offset.
+func zoneTable(loc *time.Location) []int64 {
+	table := []int64{0}
+	t := zoneStart.In(loc)
+	for {
+		_, offset := t.Zone()
+		if n := len(table); n == 1 || table[n-1] != int64(offset) {
+			table = append(table, t.Unix(), int64(offset))
+		}
+		_, end := t.ZoneBounds()
+		if end.IsZero() || !end.Before(zoneEnd) {
+			break
+		}
+		if !end.After(t) {
+			// Zones extended by rules report year boundaries as bounds.
+			end = t.Add(time.Hour)
+		}
+		t = end
+	}
+	table[0] = int64((len(table) - 1) / 2)
+	return table
+}
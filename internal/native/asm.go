@@ -0,0 +1,338 @@
+package native
+
+import "encoding/binary"
+
+// General purpose registers, numbered like in ModRM bytes
+type reg byte
+
+const (
+	rax reg = iota
+	rcx
+	rdx
+	rbx
+	rsp
+	rbp
+	rsi
+	rdi
+	r8
+	r9
+	r10
+	r11
+)
+
+// SSE registers
+type xmm byte
+
+const (
+	xmm0 xmm = iota
+	xmm1
+)
+
+// Condition codes of Jcc, SETcc and CMOVcc
+type cond byte
+
+const (
+	condB  cond = 0x2
+	condAE cond = 0x3
+	condE  cond = 0x4
+	condNE cond = 0x5
+	condA  cond = 0x7
+	condP  cond = 0xa
+	condNP cond = 0xb
+	condL  cond = 0xc
+	condGE cond = 0xd
+	condLE cond = 0xe
+	condG  cond = 0xf
+)
+
+// A register or memory operand
+type operand struct {
+	mem     bool
+	indexed bool
+	base    byte
+	index   byte
+	disp    int32
+}
+
+func direct(r reg) operand {
+	return operand{base: byte(r)}
+}
+
+// [base + disp]
+func mem(base reg, disp int) operand {
+	return operand{mem: true, base: byte(base), disp: int32(disp)}
+}
+
+// [base + index], with neither being rsp
+func indexed(base, index reg) operand {
+	return operand{mem: true, indexed: true, base: byte(base), index: byte(index)}
+}
+
+type label int
+
+// A tiny x86-64 assembler, only encoding what the compiler needs
+type assembler struct {
+	code []byte
+	// Offsets of labels, -1 if not bound yet
+	labels []int
+	// Offsets of rel32 fields to patch, by labels
+	fixups map[int]label
+}
+
+func (a *assembler) emit(bytes ...byte) {
+	a.code = append(a.code, bytes...)
+}
+
+func (a *assembler) emit32(v uint32) {
+	a.code = binary.LittleEndian.AppendUint32(a.code, v)
+}
+
+// Encodes an instruction with a legacy prefix (or 0), REX.W if wide,
+// and a ModRM byte taking the register (or opcode extension) and the operand
+func (a *assembler) encode(prefix byte, wide bool, opcode []byte, r byte, m operand) {
+	if prefix != 0 {
+		a.emit(prefix)
+	}
+	rex := byte(0)
+	if wide {
+		rex |= 8
+	}
+	if r&8 != 0 {
+		rex |= 4
+	}
+	if m.indexed && m.index&8 != 0 {
+		rex |= 2
+	}
+	if m.base&8 != 0 {
+		rex |= 1
+	}
+	if rex != 0 {
+		a.emit(0x40 | rex)
+	}
+	a.emit(opcode...)
+	switch {
+	case !m.mem:
+		a.emit(0xc0 | (r&7)<<3 | m.base&7)
+	case m.indexed:
+		// Always with a zero disp8, which rbp and r13 need as bases
+		a.emit(0x44|(r&7)<<3, (m.index&7)<<3|m.base&7, 0)
+	default:
+		a.emit(0x80 | (r&7)<<3 | m.base&7)
+		if m.base&7 == byte(rsp) {
+			a.emit(0x24)
+		}
+		a.emit32(uint32(m.disp))
+	}
+}
+
+func (a *assembler) movImm(r reg, v uint64) {
+	if v <= 0xffffffff {
+		// Zero-extended
+		if r >= r8 {
+			a.emit(0x41)
+		}
+		a.emit(0xb8 + byte(r)&7)
+		a.emit32(uint32(v))
+		return
+	}
+	a.emit(0x48|byte(r)>>3, 0xb8+byte(r)&7)
+	a.code = binary.LittleEndian.AppendUint64(a.code, v)
+}
+
+func (a *assembler) load(r reg, m operand) {
+	a.encode(0, true, []byte{0x8b}, byte(r), m)
+}
+
+func (a *assembler) store(m operand, r reg) {
+	a.encode(0, true, []byte{0x89}, byte(r), m)
+}
+
+func (a *assembler) mov(dst, src reg) {
+	a.encode(0, true, []byte{0x89}, byte(src), direct(dst))
+}
+
+// Opcodes of `op r/m64, r64`
+const (
+	opAdd  = 0x01
+	opOr   = 0x09
+	opAnd  = 0x21
+	opSub  = 0x29
+	opXor  = 0x31
+	opCmp  = 0x39
+	opTest = 0x85
+)
+
+// dst = dst op src
+func (a *assembler) alu(op byte, dst, src reg) {
+	a.encode(0, true, []byte{op}, byte(src), direct(dst))
+}
+
+// Compares a register with a memory operand
+func (a *assembler) cmpMem(r reg, m operand) {
+	a.encode(0, true, []byte{0x3b}, byte(r), m)
+}
+
+func (a *assembler) cmpImm(r reg, v int8) {
+	a.encode(0, true, []byte{0x83}, 7, direct(r))
+	a.emit(byte(v))
+}
+
+func (a *assembler) imul(dst, src reg) {
+	a.encode(0, true, []byte{0x0f, 0xaf}, byte(dst), direct(src))
+}
+
+// Opcode extensions of the F7 group
+const (
+	extNot  = 2
+	extNeg  = 3
+	extIdiv = 7
+)
+
+func (a *assembler) unary(ext byte, r reg) {
+	a.encode(0, true, []byte{0xf7}, ext, direct(r))
+}
+
+// Sign-extends rax into rdx
+func (a *assembler) cqo() {
+	a.emit(0x48, 0x99)
+}
+
+// Opcode extensions of shifts
+const (
+	extShl = 4
+	extShr = 5
+	extSar = 7
+)
+
+// Shifts the register by cl
+func (a *assembler) shift(ext byte, r reg) {
+	a.encode(0, true, []byte{0xd3}, ext, direct(r))
+}
+
+func (a *assembler) inc(r reg) {
+	a.encode(0, true, []byte{0xff}, 0, direct(r))
+}
+
+// Sets the lowest byte of rax, rcx, rdx or rbx
+func (a *assembler) setcc(c cond, r reg) {
+	a.encode(0, false, []byte{0x0f, 0x90 + byte(c)}, 0, direct(r))
+}
+
+// Zero-extends the lowest byte of rax, rcx, rdx or rbx
+func (a *assembler) movzx(dst, src reg) {
+	a.encode(0, true, []byte{0x0f, 0xb6}, byte(dst), direct(src))
+}
+
+// Loads a zero-extended byte
+func (a *assembler) loadByte(dst reg, m operand) {
+	a.encode(0, false, []byte{0x0f, 0xb6}, byte(dst), m)
+}
+
+// Compares the lowest byte of rax, rcx, rdx or rbx with a byte in memory
+func (a *assembler) cmpByte(r reg, m operand) {
+	a.encode(0, false, []byte{0x3a}, byte(r), m)
+}
+
+func (a *assembler) cmov(c cond, dst, src reg) {
+	a.encode(0, true, []byte{0x0f, 0x40 + byte(c)}, byte(dst), direct(src))
+}
+
+// Adds a signed 32-bit value to the register, returning the offset of the value
+func (a *assembler) addImm(r reg, v int32) int {
+	a.encode(0, true, []byte{0x81}, 0, direct(r))
+	offset := len(a.code)
+	a.emit32(uint32(v))
+	return offset
+}
+
+func (a *assembler) ret() {
+	a.emit(0xc3)
+}
+
+// Loads and stores float64 values
+func (a *assembler) loadFloat(x xmm, m operand) {
+	a.encode(0xf2, false, []byte{0x0f, 0x10}, byte(x), m)
+}
+
+func (a *assembler) storeFloat(m operand, x xmm) {
+	a.encode(0xf2, false, []byte{0x0f, 0x11}, byte(x), m)
+}
+
+// Opcodes of scalar double instructions, prefixed with F2 (or 66 for ucomisd)
+const (
+	opSqrtsd  = 0x51
+	opAddsd   = 0x58
+	opMulsd   = 0x59
+	opSubsd   = 0x5c
+	opDivsd   = 0x5e
+	opUcomisd = 0x2e
+)
+
+// dst = dst op src, or compares dst with src for ucomisd
+func (a *assembler) sse(op byte, dst, src xmm) {
+	prefix := byte(0xf2)
+	if op == opUcomisd {
+		prefix = 0x66
+	}
+	a.encode(prefix, false, []byte{0x0f, op}, byte(dst), direct(reg(src)))
+}
+
+func (a *assembler) movqToXmm(x xmm, r reg) {
+	a.encode(0x66, true, []byte{0x0f, 0x6e}, byte(x), direct(r))
+}
+
+func (a *assembler) movqFromXmm(r reg, x xmm) {
+	a.encode(0x66, true, []byte{0x0f, 0x7e}, byte(x), direct(r))
+}
+
+func (a *assembler) cvtsi2sd(x xmm, r reg) {
+	a.encode(0xf2, true, []byte{0x0f, 0x2a}, byte(x), direct(r))
+}
+
+// Truncates, giving the minimum int64 for NaN and values out of range
+func (a *assembler) cvttsd2si(r reg, x xmm) {
+	a.encode(0xf2, true, []byte{0x0f, 0x2c}, byte(r), direct(reg(x)))
+}
+
+// x87 loads and stores, for fprem
+func (a *assembler) fld(m operand) {
+	a.encode(0, false, []byte{0xdd}, 0, m)
+}
+
+func (a *assembler) fstp(m operand) {
+	a.encode(0, false, []byte{0xdd}, 3, m)
+}
+
+func (a *assembler) newLabel() label {
+	a.labels = append(a.labels, -1)
+	return label(len(a.labels) - 1)
+}
+
+func (a *assembler) bind(l label) {
+	a.labels[l] = len(a.code)
+}
+
+func (a *assembler) rel32(l label) {
+	if a.fixups == nil {
+		a.fixups = make(map[int]label)
+	}
+	a.fixups[len(a.code)] = l
+	a.emit32(0)
+}
+
+func (a *assembler) jcc(c cond, l label) {
+	a.emit(0x0f, 0x80+byte(c))
+	a.rel32(l)
+}
+
+func (a *assembler) jmp(l label) {
+	a.emit(0xe9)
+	a.rel32(l)
+}
+
+// Resolves jumps, once all labels are bound
+func (a *assembler) link() {
+	for offset, l := range a.fixups {
+		binary.LittleEndian.PutUint32(a.code[offset:], uint32(a.labels[l]-offset-4))
+	}
+}
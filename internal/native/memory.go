@@ -0,0 +1,28 @@
+//go:build amd64 && unix
+
+package native
+
+import "syscall"
+
+// Copies the code into new executable memory
+//
+// Pages are mapped writable first, and then made executable,
+// never being both at the same time.
+func allocate(code []byte) ([]byte, error) {
+	page := syscall.Getpagesize()
+	memory, err := syscall.Mmap(-1, 0, (len(code)+page-1)/page*page,
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return nil, err
+	}
+	copy(memory, code)
+	if err := syscall.Mprotect(memory, syscall.PROT_READ|syscall.PROT_EXEC); err != nil {
+		syscall.Munmap(memory)
+		return nil, err
+	}
+	return memory, nil
+}
+
+func release(memory []byte) error {
+	return syscall.Munmap(memory)
+}
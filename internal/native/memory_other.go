@@ -0,0 +1,13 @@
+//go:build !amd64 || !unix
+
+package native
+
+import "fmt"
+
+func allocate(code []byte) ([]byte, error) {
+	return nil, fmt.Errorf("the native backend only supports amd64 on unix systems")
+}
+
+func release(memory []byte) error {
+	return nil
+}
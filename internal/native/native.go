@@ -0,0 +1,750 @@
+// This package compiles IR into x86-64 machine code without LibJIT.
+//
+// Only a subset of the operators is supported: arithmetic, comparison and logic
+// operators on booleans, integers, floats, timestamps, durations and decimals
+// (except decimal multiplication and division), along with string lengths,
+// equality and searching. Other operators, sized types, host functions
+// and intrinsics fail to compile.
+//
+// The code follows compile_opcodes in gruel_jit.c: it takes a pointer to
+// the parameters, keeps values in 64-bit stack slots, and stores failed
+// runtime checks into the error slot right after the parameters.
+package native
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/internal/ir"
+)
+
+const (
+	typeBool     = gruelparser.TypeBool
+	typeInt      = gruelparser.TypeInt
+	typeFloat    = gruelparser.TypeFloat
+	typeString   = gruelparser.TypeString
+	typeTime     = gruelparser.TypeTime
+	typeDuration = gruelparser.TypeDuration
+	typeDecimal  = gruelparser.TypeDecimal
+	// Time zone tables and scratch buffers, only used by unsupported operators
+	typeHidden gruelparser.TokenType = -1
+)
+
+// Machine code in executable memory
+type Code struct {
+	memory []byte
+	stack  int
+}
+
+// The address of the code, callable with caller.CallNative
+func (c *Code) Entry() uint64 {
+	return uint64(uintptr(unsafe.Pointer(&c.memory[0])))
+}
+
+// Stack space needed by calls, in bytes
+func (c *Code) Stack() int {
+	return c.stack
+}
+
+// Executable memory taken by the code, in bytes
+func (c *Code) Size() int {
+	return len(c.memory)
+}
+
+// Releases the memory, after which the code must not be called
+func (c *Code) Free() error {
+	return release(c.memory)
+}
+
+type compiler struct {
+	a     assembler
+	types []gruelparser.TokenType
+	// The maximum stack depth, in slots
+	depth int
+	// The index of the error slot
+	errorSlot int
+	faults    []fault
+}
+
+// A failed runtime check, reported by code after the function body
+type fault struct {
+	label label
+	code  uint64
+}
+
+// Compiles the program into machine code
+func Compile(program *ir.IrBuilder) (*Code, error) {
+	c := compiler{errorSlot: len(program.ArgMap())}
+	args := program.Args()
+	frame := c.a.addImm(rsp, 0)
+	for _, in := range program.Instructions() {
+		var err error
+		switch {
+		case in.Host:
+			err = fmt.Errorf("host functions are not supported by the native backend")
+		case in.Hidden:
+			c.push(typeHidden)
+		case in.Type == gruelparser.TypeParenthesis:
+			err = c.operator(in)
+		case in.Type == gruelparser.TypeSymbol:
+			err = c.param(int(in.Value), gruelparser.TokenType(args[in.Value]))
+		default:
+			err = c.constant(in.Type, in.Value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(c.types) != 1 {
+		return nil, fmt.Errorf("unexpected stack size %d", len(c.types))
+	}
+
+	// Two more slots for x87 operands, keeping the stack aligned
+	size := 8 * (c.depth + 2)
+	size += size & 8
+	c.a.load(rax, c.slot(0))
+	exit := c.a.newLabel()
+	c.a.bind(exit)
+	c.a.addImm(rsp, int32(size))
+	c.a.ret()
+	for _, f := range c.faults {
+		c.a.bind(f.label)
+		c.a.movImm(rax, f.code)
+		c.a.store(mem(rdi, 8*c.errorSlot), rax)
+		c.a.movImm(rax, 0)
+		c.a.jmp(exit)
+	}
+	c.a.link()
+	// The prologue reserves the frame.
+	putInt32(c.a.code[frame:], int32(-size))
+
+	memory, err := allocate(c.a.code)
+	if err != nil {
+		return nil, err
+	}
+	// The frame and the return address
+	return &Code{memory: memory, stack: size + 8}, nil
+}
+
+func putInt32(b []byte, v int32) {
+	b[0], b[1], b[2], b[3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+}
+
+// The stack slot of a value, indexed from the bottom
+func (c *compiler) slot(i int) operand {
+	return mem(rsp, 8*i)
+}
+
+// The slot of the value n positions below the top
+func (c *compiler) top(n int) operand {
+	return c.slot(len(c.types) - 1 - n)
+}
+
+func (c *compiler) push(t gruelparser.TokenType) {
+	c.types = append(c.types, t)
+	if c.depth < len(c.types) {
+		c.depth = len(c.types)
+	}
+}
+
+func supported(t gruelparser.TokenType) bool {
+	switch t {
+	case typeBool, typeInt, typeFloat, typeString, typeTime, typeDuration, typeDecimal:
+		return true
+	default:
+		return false
+	}
+}
+
+func unsupportedType(t gruelparser.TokenType) error {
+	return fmt.Errorf("type %s is not supported by the native backend", ir.TypeName(t))
+}
+
+func unsupported(name string, t gruelparser.TokenType) error {
+	return fmt.Errorf("operator %s on %s is not supported by the native backend", name, ir.TypeName(t))
+}
+
+func (c *compiler) param(index int, t gruelparser.TokenType) error {
+	if !supported(t) {
+		return unsupportedType(t)
+	}
+	c.a.load(rax, mem(rdi, 8*index))
+	c.push(t)
+	c.a.store(c.top(0), rax)
+	return nil
+}
+
+// Pushes a constant, with strings being pointers to their headers
+func (c *compiler) constant(t gruelparser.TokenType, v uint64) error {
+	if !supported(t) {
+		return unsupportedType(t)
+	}
+	c.a.movImm(rax, v)
+	c.push(t)
+	c.a.store(c.top(0), rax)
+	return nil
+}
+
+// Jumps to code reporting the fault if the condition holds
+func (c *compiler) fail(cc cond, in ir.Instruction) {
+	l := c.a.newLabel()
+	c.a.jcc(cc, l)
+	c.faults = append(c.faults, fault{label: l, code: uint64(in.Site)<<8 | uint64(in.Check)})
+}
+
+func isFloat(t gruelparser.TokenType) bool {
+	return t == typeFloat
+}
+
+// Loads a value into an SSE register, converting integers
+func (c *compiler) loadFloat(x xmm, m operand, t gruelparser.TokenType) {
+	if isFloat(t) {
+		c.a.loadFloat(x, m)
+		return
+	}
+	c.a.load(rax, m)
+	c.a.cvtsi2sd(x, rax)
+}
+
+// Loads a value into a general purpose register, truncating floats
+func (c *compiler) loadInt(r reg, m operand, t gruelparser.TokenType) {
+	if isFloat(t) {
+		c.a.loadFloat(xmm0, m)
+		c.a.cvttsd2si(r, xmm0)
+		return
+	}
+	c.a.load(r, m)
+}
+
+func (c *compiler) operator(in ir.Instruction) error {
+	op := in.Operator
+	if op == nil {
+		return fmt.Errorf("intrinsics are not supported by the native backend")
+	}
+	if kind := op.JitFunction[0]; kind == '@' || kind == '&' || kind == '$' {
+		// Operators calling into C
+		return fmt.Errorf("operator %s is not supported by the native backend", in.Name)
+	}
+	if op.Argc > len(c.types) {
+		return fmt.Errorf("operator %s expects %d operands", in.Name, op.Argc)
+	}
+	operands := make([]gruelparser.TokenType, op.Argc)
+	for i := range operands {
+		operands[i] = c.types[len(c.types)-1-i]
+		if !supported(operands[i]) {
+			return unsupported(in.Name, operands[i])
+		}
+	}
+	result, err := ir.OperatorType(in.Name, operands)
+	if err != nil {
+		return err
+	}
+	if !supported(result) {
+		return unsupported(in.Name, result)
+	}
+
+	switch in.Check {
+	case ir.CheckDivisor:
+		// Float divisors are never checked.
+		c.a.load(rax, c.top(1))
+		c.a.alu(opTest, rax, rax)
+		c.fail(condE, in)
+	case ir.CheckShift:
+		// Values are shifted at their own widths, see check_operands in gruel_jit.c,
+		// and all supported types are 64 bits wide.
+		c.a.load(rax, c.top(1))
+		c.a.movImm(rcx, 64)
+		c.a.alu(opCmp, rax, rcx)
+		c.fail(condAE, in)
+	}
+	if op.Argc == 1 {
+		err = c.unary(in.Name, op, operands[0], result)
+	} else {
+		err = c.binary(in.Name, op, operands[0], operands[1], result)
+	}
+	if err != nil {
+		return err
+	}
+	c.types = append(c.types[:len(c.types)-op.Argc], result)
+	if in.Check == ir.CheckNaN {
+		c.a.loadFloat(xmm0, c.top(0))
+		c.a.sse(opUcomisd, xmm0, xmm0)
+		c.fail(condP, in)
+	}
+	return nil
+}
+
+// Stores rax as the result of an operator with argc operands
+func (c *compiler) result(argc int) {
+	c.a.store(c.top(argc-1), rax)
+}
+
+// Stores xmm0 as the result
+func (c *compiler) floatResult(argc int) {
+	c.a.storeFloat(c.top(argc-1), xmm0)
+}
+
+// Sets rax to the condition
+func (c *compiler) setResult(cc cond) {
+	c.a.setcc(cc, rax)
+	c.a.movzx(rax, rax)
+}
+
+// Sets rax to whether xmm0 is non-zero, NaN being true
+func (c *compiler) floatTruth() {
+	c.a.alu(opXor, rax, rax)
+	c.a.movqToXmm(xmm1, rax)
+	c.a.sse(opUcomisd, xmm0, xmm1)
+	c.a.setcc(condNE, rax)
+	c.a.setcc(condP, rcx)
+	c.a.alu(opOr, rax, rcx)
+	c.a.movzx(rax, rax)
+}
+
+// Sets rax to whether the value is non-zero
+func (c *compiler) truth(m operand, t gruelparser.TokenType) {
+	if isFloat(t) {
+		c.a.loadFloat(xmm0, m)
+		c.floatTruth()
+		return
+	}
+	c.a.load(rcx, m)
+	c.a.alu(opXor, rax, rax)
+	c.a.alu(opTest, rcx, rcx)
+	c.a.setcc(condNE, rax)
+}
+
+func (c *compiler) unary(name string, op *ir.Operator, t, result gruelparser.TokenType) error {
+	a := c.top(0)
+	switch {
+	case name == "len":
+		done := c.a.newLabel()
+		c.a.load(rcx, a)
+		c.a.alu(opXor, rax, rax)
+		c.a.alu(opTest, rcx, rcx)
+		c.a.jcc(condE, done)
+		c.a.load(rax, mem(rcx, 8))
+		c.a.bind(done)
+		c.result(1)
+	case name == "->bool" || name == "!":
+		c.truth(a, t)
+		if name == "!" {
+			c.a.movImm(rcx, 1)
+			c.a.alu(opXor, rax, rcx)
+		}
+		c.result(1)
+	case name == "nan?" || name == "inf?" || name == "finite?":
+		if !isFloat(t) {
+			if name == "finite?" {
+				c.a.movImm(rax, 1)
+			} else {
+				c.a.movImm(rax, 0)
+			}
+			c.result(1)
+			return nil
+		}
+		c.a.loadFloat(xmm0, a)
+		switch name {
+		case "nan?":
+			c.a.sse(opUcomisd, xmm0, xmm0)
+			c.setResult(condP)
+		default:
+			// Compares the bits of |x| with those of infinity,
+			// which NaNs are greater than.
+			c.abs(xmm0)
+			c.a.movqFromXmm(rax, xmm0)
+			c.a.movImm(rcx, 0x7ff0000000000000)
+			c.a.alu(opCmp, rax, rcx)
+			if name == "inf?" {
+				c.setResult(condE)
+			} else {
+				c.setResult(condB)
+			}
+		}
+		c.result(1)
+	case name == "-":
+		if isFloat(result) {
+			c.a.loadFloat(xmm0, a)
+			c.a.movqFromXmm(rax, xmm0)
+			c.a.movImm(rcx, 1<<63)
+			c.a.alu(opXor, rax, rcx)
+		} else {
+			c.a.load(rax, a)
+			c.a.unary(extNeg, rax)
+		}
+		c.result(1)
+	case name == "^":
+		c.a.load(rax, a)
+		c.a.unary(extNot, rax)
+		c.result(1)
+	case name == "abs":
+		if isFloat(result) {
+			c.loadFloat(xmm0, a, t)
+			c.abs(xmm0)
+			c.floatResult(1)
+			return nil
+		}
+		c.a.load(rax, a)
+		c.a.mov(rcx, rax)
+		c.a.unary(extNeg, rcx)
+		c.a.cmov(condGE, rax, rcx)
+		c.result(1)
+	case name == "sign":
+		if isFloat(t) {
+			c.a.loadFloat(xmm0, a)
+			c.a.alu(opXor, rax, rax)
+			c.a.movqToXmm(xmm1, rax)
+			c.a.sse(opUcomisd, xmm0, xmm1)
+			c.a.setcc(condA, rax)
+			c.a.alu(opXor, rcx, rcx)
+			c.a.sse(opUcomisd, xmm1, xmm0)
+			c.a.setcc(condA, rcx)
+		} else {
+			c.a.load(rdx, a)
+			c.a.alu(opXor, rax, rax)
+			c.a.alu(opXor, rcx, rcx)
+			c.a.alu(opTest, rdx, rdx)
+			c.a.setcc(condG, rax)
+			c.a.setcc(condL, rcx)
+		}
+		c.a.alu(opSub, rax, rcx)
+		c.result(1)
+	case name == "sqrt":
+		c.loadFloat(xmm0, a, t)
+		c.a.sse(opSqrtsd, xmm0, xmm0)
+		c.floatResult(1)
+	case op.JitFunction[0] == '%' && isFloat(result):
+		c.loadFloat(xmm0, a, t)
+		c.floatResult(1)
+	case op.JitFunction[0] == '%' && result == typeInt || op.JitFunction[0] == '%' && result == t:
+		c.loadInt(rax, a, t)
+		c.result(1)
+	default:
+		return unsupported(name, t)
+	}
+	return nil
+}
+
+// Clears the sign bit
+func (c *compiler) abs(x xmm) {
+	c.a.movqFromXmm(rax, x)
+	c.a.movImm(rcx, 1<<63-1)
+	c.a.alu(opAnd, rax, rcx)
+	c.a.movqToXmm(x, rax)
+}
+
+// Common types of operands, floats if either one is
+func common(a, b gruelparser.TokenType) gruelparser.TokenType {
+	if isFloat(a) || isFloat(b) {
+		return typeFloat
+	}
+	return typeInt
+}
+
+// Loads the operands (a on the top) into xmm0 and xmm1, or rax and rcx
+func (c *compiler) loadOperands(a, b, t gruelparser.TokenType) {
+	if isFloat(t) {
+		c.loadFloat(xmm0, c.top(0), a)
+		c.loadFloat(xmm1, c.top(1), b)
+	} else {
+		c.loadInt(rax, c.top(0), a)
+		c.loadInt(rcx, c.top(1), b)
+	}
+}
+
+func (c *compiler) binary(name string, op *ir.Operator, a, b, result gruelparser.TokenType) error {
+	switch {
+	case name == "index":
+		c.index()
+	case name == "&&" || name == "||":
+		c.truth(c.top(1), b)
+		c.a.mov(rdx, rax)
+		c.truth(c.top(0), a)
+		if name == "&&" {
+			c.a.alu(opAnd, rax, rdx)
+		} else {
+			c.a.alu(opOr, rax, rdx)
+		}
+	case name == "=" || name == "==" || name == "!=":
+		switch {
+		case a == typeString && b == typeString:
+			c.equalStrings()
+		case a == typeString || b == typeString:
+			c.a.movImm(rax, 0)
+		default:
+			c.compare(condE, a, b)
+		}
+		if name == "!=" {
+			c.a.movImm(rcx, 1)
+			c.a.alu(opXor, rax, rcx)
+		}
+	case name == "<" || name == "<=" || name == ">" || name == ">=":
+		conditions := map[string]cond{"<": condL, "<=": condLE, ">": condG, ">=": condGE}
+		c.compare(conditions[name], a, b)
+	case name == "cmpl" || name == "cmpg":
+		c.cmp(name == "cmpg", a, b)
+	case name == "<<" || name == ">>" || name == ">>>":
+		if a != typeInt && a != typeDuration {
+			return unsupported(name, a)
+		}
+		shifts := map[string]byte{"<<": extShl, ">>": extSar, ">>>": extShr}
+		c.loadOperands(a, b, typeInt)
+		c.a.shift(shifts[name], rax)
+	case isFloat(result):
+		return c.floatArithmetic(name, a, b)
+	default:
+		return c.arithmetic(name, a, b)
+	}
+	c.result(2)
+	return nil
+}
+
+// Sets rax to the integer comparison, or the float one with NaN being unordered
+func (c *compiler) compare(cc cond, a, b gruelparser.TokenType) {
+	t := common(a, b)
+	c.loadOperands(a, b, t)
+	if !isFloat(t) {
+		c.a.alu(opCmp, rax, rcx)
+		c.setResult(cc)
+		return
+	}
+	switch cc {
+	case condE:
+		c.a.sse(opUcomisd, xmm0, xmm1)
+		c.a.setcc(condE, rax)
+		c.a.setcc(condNP, rcx)
+		c.a.alu(opAnd, rax, rcx)
+		c.a.movzx(rax, rax)
+	case condL, condLE:
+		// a < b as b > a, which is false for NaN
+		c.a.sse(opUcomisd, xmm1, xmm0)
+		c.setResult(map[cond]cond{condL: condA, condLE: condAE}[cc])
+	default:
+		c.a.sse(opUcomisd, xmm0, xmm1)
+		c.setResult(map[cond]cond{condG: condA, condGE: condAE}[cc])
+	}
+}
+
+// Sets rax to -1, 0 or 1, with NaN being 1 if greater
+func (c *compiler) cmp(greater bool, a, b gruelparser.TokenType) {
+	t := common(a, b)
+	c.loadOperands(a, b, t)
+	if !isFloat(t) {
+		c.a.alu(opCmp, rax, rcx)
+		c.a.setcc(condG, rax)
+		c.a.setcc(condL, rcx)
+	} else {
+		unordered, done := c.a.newLabel(), c.a.newLabel()
+		c.a.sse(opUcomisd, xmm0, xmm1)
+		c.a.jcc(condP, unordered)
+		c.a.setcc(condA, rax)
+		c.a.setcc(condB, rcx)
+		c.a.movzx(rax, rax)
+		c.a.movzx(rcx, rcx)
+		c.a.alu(opSub, rax, rcx)
+		c.a.jmp(done)
+		c.a.bind(unordered)
+		if greater {
+			c.a.movImm(rax, 1)
+		} else {
+			c.a.movImm(rax, 1<<64-1)
+		}
+		c.a.bind(done)
+		return
+	}
+	c.a.movzx(rax, rax)
+	c.a.movzx(rcx, rcx)
+	c.a.alu(opSub, rax, rcx)
+}
+
+func (c *compiler) arithmetic(name string, a, b gruelparser.TokenType) error {
+	c.loadOperands(a, b, typeInt)
+	switch name {
+	case "+":
+		c.a.alu(opAdd, rax, rcx)
+	case "-":
+		c.a.alu(opSub, rax, rcx)
+	case "*":
+		c.a.imul(rax, rcx)
+	case "&":
+		c.a.alu(opAnd, rax, rcx)
+	case "|":
+		c.a.alu(opOr, rax, rcx)
+	case "^":
+		c.a.alu(opXor, rax, rcx)
+	case "min", "max":
+		c.a.alu(opCmp, rcx, rax)
+		if name == "min" {
+			c.a.cmov(condL, rax, rcx)
+		} else {
+			c.a.cmov(condG, rax, rcx)
+		}
+	case "/", "%":
+		c.divide(name == "%")
+	default:
+		return unsupported(name, a)
+	}
+	c.result(2)
+	return nil
+}
+
+// Divides rax by rcx into rax, yielding 0 for zero divisors
+// and wrapping around like Go for the minimum divided by -1
+func (c *compiler) divide(remainder bool) {
+	zero, negative, done := c.a.newLabel(), c.a.newLabel(), c.a.newLabel()
+	c.a.alu(opTest, rcx, rcx)
+	c.a.jcc(condE, zero)
+	c.a.cmpImm(rcx, -1)
+	c.a.jcc(condE, negative)
+	c.a.cqo()
+	c.a.unary(extIdiv, rcx)
+	if remainder {
+		c.a.mov(rax, rdx)
+	}
+	c.a.jmp(done)
+	c.a.bind(negative)
+	if !remainder {
+		c.a.unary(extNeg, rax)
+		c.a.jmp(done)
+	}
+	c.a.bind(zero)
+	c.a.alu(opXor, rax, rax)
+	c.a.bind(done)
+}
+
+func (c *compiler) floatArithmetic(name string, a, b gruelparser.TokenType) error {
+	c.loadOperands(a, b, typeFloat)
+	switch name {
+	case "+":
+		c.a.sse(opAddsd, xmm0, xmm1)
+	case "-":
+		c.a.sse(opSubsd, xmm0, xmm1)
+	case "*":
+		c.a.sse(opMulsd, xmm0, xmm1)
+	case "/":
+		c.a.sse(opDivsd, xmm0, xmm1)
+	case "%":
+		c.remainder()
+	case "min", "max":
+		c.minMax(name == "max")
+	default:
+		return unsupported(name, typeFloat)
+	}
+	c.floatResult(2)
+	return nil
+}
+
+// Computes fmod(xmm0, xmm1) into xmm0 with fprem, in the scratch slots
+func (c *compiler) remainder() {
+	x, y := c.slot(c.depth), c.slot(c.depth+1)
+	c.a.storeFloat(x, xmm0)
+	c.a.storeFloat(y, xmm1)
+	c.a.fld(y)
+	c.a.fld(x)
+	loop := c.a.newLabel()
+	c.a.bind(loop)
+	// fprem; fnstsw ax; test ah, 4 (C2 set while partial)
+	c.a.emit(0xd9, 0xf8, 0xdf, 0xe0, 0xf6, 0xc4, 0x04)
+	c.a.jcc(condNE, loop)
+	c.a.fstp(x)
+	// fstp st(0)
+	c.a.emit(0xdd, 0xd8)
+	c.a.loadFloat(xmm0, x)
+}
+
+// Picks the smaller (or greater) one of xmm0 and xmm1 into xmm0,
+// or the NaN one, like gruelrt.Min and gruelrt.Max
+func (c *compiler) minMax(greater bool) {
+	done, pick := c.a.newLabel(), c.a.newLabel()
+	c.a.sse(opUcomisd, xmm0, xmm0)
+	c.a.jcc(condP, done)
+	c.a.sse(opUcomisd, xmm1, xmm1)
+	c.a.jcc(condP, pick)
+	if greater {
+		c.a.sse(opUcomisd, xmm1, xmm0)
+	} else {
+		c.a.sse(opUcomisd, xmm0, xmm1)
+	}
+	c.a.jcc(condA, pick)
+	c.a.jmp(done)
+	c.a.bind(pick)
+	c.a.movqFromXmm(rax, xmm1)
+	c.a.movqToXmm(xmm0, rax)
+	c.a.bind(done)
+}
+
+// Loads the string headers of the top two values into rsi and rdx,
+// jumping to the label if either one is null
+func (c *compiler) loadStrings(null label) {
+	c.a.load(rsi, c.top(0))
+	c.a.load(rdx, c.top(1))
+	c.a.alu(opTest, rsi, rsi)
+	c.a.jcc(condE, null)
+	c.a.alu(opTest, rdx, rdx)
+	c.a.jcc(condE, null)
+}
+
+// Compares strings byte by byte, like gruel_streq
+func (c *compiler) equalStrings() {
+	unequal, equal, loop, done := c.a.newLabel(), c.a.newLabel(), c.a.newLabel(), c.a.newLabel()
+	c.loadStrings(unequal)
+	c.a.load(r8, mem(rsi, 8))
+	c.a.cmpMem(r8, mem(rdx, 8))
+	c.a.jcc(condNE, unequal)
+	c.a.load(r9, mem(rsi, 0))
+	c.a.load(r10, mem(rdx, 0))
+	c.a.alu(opXor, rcx, rcx)
+	c.a.bind(loop)
+	c.a.alu(opCmp, rcx, r8)
+	c.a.jcc(condE, equal)
+	c.a.loadByte(rax, indexed(r9, rcx))
+	c.a.cmpByte(rax, indexed(r10, rcx))
+	c.a.jcc(condNE, unequal)
+	c.a.inc(rcx)
+	c.a.jmp(loop)
+	c.a.bind(equal)
+	c.a.movImm(rax, 1)
+	c.a.jmp(done)
+	c.a.bind(unequal)
+	c.a.movImm(rax, 0)
+	c.a.bind(done)
+}
+
+// Searches the second string in the first one, like gruel_index_of
+func (c *compiler) index() {
+	missing, found, outer, inner, next, done := c.a.newLabel(), c.a.newLabel(), c.a.newLabel(),
+		c.a.newLabel(), c.a.newLabel(), c.a.newLabel()
+	c.loadStrings(missing)
+	c.a.load(r8, mem(rsi, 0))
+	c.a.load(r9, mem(rsi, 8))
+	c.a.load(r10, mem(rdx, 0))
+	c.a.load(r11, mem(rdx, 8))
+	c.a.alu(opXor, rcx, rcx)
+	// r9 is the last position to try.
+	c.a.alu(opSub, r9, r11)
+	c.a.jcc(condL, missing)
+	c.a.bind(outer)
+	c.a.alu(opCmp, rcx, r9)
+	c.a.jcc(condG, missing)
+	c.a.mov(rsi, r8)
+	c.a.alu(opAdd, rsi, rcx)
+	c.a.alu(opXor, rax, rax)
+	c.a.bind(inner)
+	c.a.alu(opCmp, rax, r11)
+	c.a.jcc(condE, found)
+	c.a.loadByte(rdx, indexed(rsi, rax))
+	c.a.cmpByte(rdx, indexed(r10, rax))
+	c.a.jcc(condNE, next)
+	c.a.inc(rax)
+	c.a.jmp(inner)
+	c.a.bind(next)
+	c.a.inc(rcx)
+	c.a.jmp(outer)
+	c.a.bind(found)
+	c.a.mov(rax, rcx)
+	c.a.jmp(done)
+	c.a.bind(missing)
+	c.a.movImm(rax, 1<<64-1)
+	c.a.bind(done)
+}
@@ -0,0 +1,227 @@
+// This package implements the fixed-point decimal type used by gruel rules.
+//
+// Decimals are int64 values scaled by 10^Digits. The JIT code path implements
+// the very same arithmetic in C (see gruel_jit.c), so that results computed
+// here match results computed by compiled rules.
+//
+// In rules, `*` and `/` on decimals always round half to even to Digits
+// places. Other rounding modes only come from the `round-*` operators, which
+// round to fewer places, like `(round-half-up (* price rate) 2)`, so results
+// with more than Digits places are rounded twice. Mul and Div take any mode.
+package decimal
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Digits after the decimal point
+const Digits = 6
+
+// The scaling factor, 10^Digits
+const Scale = 1_000_000
+
+// A fixed-point decimal number, scaled by Scale
+type Decimal int64
+
+// Rounding modes, matching the `round-*` operators
+type RoundingMode int
+
+const (
+	// Rounds to the nearest, ties to even (banker's rounding)
+	HalfEven RoundingMode = iota
+	// Rounds to the nearest, ties away from zero
+	HalfUp
+	// Rounds towards zero
+	Down
+	// Rounds away from zero
+	Up
+	// Rounds towards negative infinity
+	Floor
+	// Rounds towards positive infinity
+	Ceiling
+)
+
+var mask64 = new(big.Int).SetUint64(math.MaxUint64)
+
+// Converts an integer
+//
+// Out of range values are clamped and reported, like compiled rules report
+// them with FaultOverflow unless lenient.
+func FromInt(i int64) (Decimal, error) {
+	if i > math.MaxInt64/Scale || i < math.MinInt64/Scale {
+		return clamp(i > 0), fmt.Errorf("decimal out of range: %d", i)
+	}
+	return Decimal(i * Scale), nil
+}
+
+// Converts a float, rounding half to even
+//
+// Out of range values are clamped and NaN becomes zero, along with an error.
+func FromFloat(f float64) (Decimal, error) {
+	scaled := math.RoundToEven(f * Scale)
+	switch {
+	case math.IsNaN(scaled):
+		return 0, fmt.Errorf("decimal out of range: %v", f)
+	case scaled >= math.MaxInt64 || scaled < math.MinInt64:
+		return clamp(scaled > 0), fmt.Errorf("decimal out of range: %v", f)
+	}
+	return Decimal(scaled), nil
+}
+
+// Converts a rational number with the given rounding mode
+func FromRat(r *big.Rat, mode RoundingMode) (Decimal, error) {
+	n := new(big.Int).Mul(r.Num(), big.NewInt(Scale))
+	q := divRound(n, r.Denom(), mode)
+	if !q.IsInt64() {
+		return 0, fmt.Errorf("decimal out of range: %s", r.String())
+	}
+	return Decimal(q.Int64()), nil
+}
+
+// Parses a decimal like "-12.34", with an optional "d" suffix
+//
+// Values with more than Digits fractional digits are rejected
+// instead of being rounded silently.
+func Parse(s string) (Decimal, error) {
+	str := strings.TrimSuffix(s, "d")
+	r, ok := new(big.Rat).SetString(str)
+	if !ok || strings.ContainsAny(str, "/eEpPxX") {
+		return 0, fmt.Errorf("invalid decimal %s", s)
+	}
+	d, err := FromRat(r, Down)
+	if err != nil {
+		return 0, err
+	}
+	if d.Rat().Cmp(r) != 0 {
+		return 0, fmt.Errorf("decimal %s has more than %d fractional digits", s, Digits)
+	}
+	return d, nil
+}
+
+// The exact value as a rational number
+func (d Decimal) Rat() *big.Rat {
+	return big.NewRat(int64(d), Scale)
+}
+
+func (d Decimal) Float64() float64 {
+	return float64(d) / Scale
+}
+
+// Implements fmt.Stringer, without trailing zeros
+func (d Decimal) String() string {
+	sign := ""
+	units := uint64(d)
+	if d < 0 {
+		sign = "-"
+		units = -units
+	}
+	integral := units / Scale
+	fraction := units % Scale
+	if fraction == 0 {
+		return fmt.Sprintf("%s%d", sign, integral)
+	}
+	digits := strings.TrimRight(fmt.Sprintf("%0*d", Digits, fraction), "0")
+	return fmt.Sprintf("%s%d.%s", sign, integral, digits)
+}
+
+func (d Decimal) Add(e Decimal) Decimal {
+	return d + e
+}
+
+func (d Decimal) Sub(e Decimal) Decimal {
+	return d - e
+}
+
+// Multiplies two decimals, rounding the result with the given mode
+//
+// Out of range products are clamped, along with an error.
+func (d Decimal) Mul(e Decimal, mode RoundingMode) (Decimal, error) {
+	n := new(big.Int).Mul(big.NewInt(int64(d)), big.NewInt(int64(e)))
+	q := divRound(n, big.NewInt(Scale), mode)
+	if !q.IsInt64() {
+		return clamp(q.Sign() > 0), fmt.Errorf("decimal out of range: %s * %s", d, e)
+	}
+	return Decimal(q.Int64()), nil
+}
+
+// Divides two decimals, rounding the result with the given mode
+//
+// Dividing by zero yields zero, just like integer division in rules.
+func (d Decimal) Div(e Decimal, mode RoundingMode) Decimal {
+	if e == 0 {
+		return 0
+	}
+	n := new(big.Int).Mul(big.NewInt(int64(d)), big.NewInt(Scale))
+	return wrap(divRound(n, big.NewInt(int64(e)), mode))
+}
+
+// Rounds to the given number of fractional digits
+//
+// Negative places round to tens, hundreds and so on.
+func (d Decimal) Round(places int64, mode RoundingMode) Decimal {
+	if places >= Digits {
+		return d
+	}
+	if Digits-places > 18 {
+		return 0
+	}
+	factor := int64(1)
+	for i := places; i < Digits; i++ {
+		factor *= 10
+	}
+	q := divRound(big.NewInt(int64(d)), big.NewInt(factor), mode)
+	return wrap(q.Mul(q, big.NewInt(factor)))
+}
+
+// Divides n by d with the given rounding mode, d being non-zero
+func divRound(n, d *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	// The direction away from zero
+	away := int64(1)
+	if n.Sign() != d.Sign() {
+		away = -1
+	}
+	half := new(big.Int).Abs(r)
+	half.Lsh(half, 1)
+	cmp := half.CmpAbs(d)
+	round := false
+	switch mode {
+	case HalfEven:
+		round = cmp > 0 || cmp == 0 && q.Bit(0) == 1
+	case HalfUp:
+		round = cmp >= 0
+	case Down:
+	case Up:
+		round = true
+	case Floor:
+		round = away < 0
+	case Ceiling:
+		round = away > 0
+	}
+	if round {
+		q.Add(q, big.NewInt(away))
+	}
+	return q
+}
+
+// The largest or the smallest decimal, for out of range values
+func clamp(positive bool) Decimal {
+	if positive {
+		return math.MaxInt64
+	}
+	return math.MinInt64
+}
+
+// Truncates into 64 bits like the C implementation does
+func wrap(i *big.Int) Decimal {
+	if i.IsInt64() {
+		return Decimal(i.Int64())
+	}
+	return Decimal(int64(new(big.Int).And(i, mask64).Uint64()))
+}
@@ -0,0 +1,133 @@
+package decimal_test
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/decimal"
+)
+
+func mustParse(t *testing.T, s string) decimal.Decimal {
+	d, err := decimal.Parse(s)
+	assert.Nil(t, err)
+	return d
+}
+
+func mustMul(t *testing.T, a, b decimal.Decimal, mode decimal.RoundingMode) decimal.Decimal {
+	d, err := a.Mul(b, mode)
+	assert.Nil(t, err)
+	return d
+}
+
+func TestParse(t *testing.T) {
+	assert.Equal(t, decimal.Decimal(12_340_000), mustParse(t, "12.34"))
+	assert.Equal(t, decimal.Decimal(-500_000), mustParse(t, "-.5d"))
+	assert.Equal(t, decimal.Decimal(1), mustParse(t, "0.000001"))
+	assert.Equal(t, "12.34", mustParse(t, "12.340d").String())
+	assert.Equal(t, "-0.5", mustParse(t, "-0.5").String())
+	assert.Equal(t, "7", mustParse(t, "7").String())
+
+	for _, s := range []string{"0.0000001", "1/2", "1e3", "0x10", "abc", ""} {
+		_, err := decimal.Parse(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a := mustParse(t, "0.1")
+	b := mustParse(t, "0.2")
+	assert.Equal(t, mustParse(t, "0.3"), a.Add(b))
+	assert.Equal(t, mustParse(t, "-0.1"), a.Sub(b))
+	assert.Equal(t, mustParse(t, "0.02"), mustMul(t, a, b, decimal.HalfEven))
+
+	third := mustParse(t, "1").Div(mustParse(t, "3"), decimal.HalfEven)
+	assert.Equal(t, "0.333333", third.String())
+	assert.Equal(t, "0.666667", mustParse(t, "2").Div(mustParse(t, "3"), decimal.HalfEven).String())
+	assert.Equal(t, "0.666666", mustParse(t, "2").Div(mustParse(t, "3"), decimal.Down).String())
+	assert.Equal(t, decimal.Decimal(0), a.Div(0, decimal.HalfEven))
+
+	// 0.000001 * 0.5 is exactly half of the smallest unit.
+	tiny := mustParse(t, "0.000001")
+	half := mustParse(t, "0.5")
+	assert.Equal(t, decimal.Decimal(0), mustMul(t, tiny, half, decimal.HalfEven))
+	assert.Equal(t, decimal.Decimal(1), mustMul(t, tiny, half, decimal.HalfUp))
+	assert.Equal(t, decimal.Decimal(-1), mustMul(t, -tiny, half, decimal.HalfUp))
+	assert.Equal(t, decimal.Decimal(-1), mustMul(t, -tiny, half, decimal.Floor))
+	assert.Equal(t, decimal.Decimal(0), mustMul(t, -tiny, half, decimal.Ceiling))
+}
+
+func TestRound(t *testing.T) {
+	cases := []struct {
+		value    string
+		places   int64
+		mode     decimal.RoundingMode
+		expected string
+	}{
+		{"2.345", 2, decimal.HalfEven, "2.34"},
+		{"2.355", 2, decimal.HalfEven, "2.36"},
+		{"2.345", 2, decimal.HalfUp, "2.35"},
+		{"-2.345", 2, decimal.HalfUp, "-2.35"},
+		{"2.349", 2, decimal.Down, "2.34"},
+		{"-2.349", 2, decimal.Down, "-2.34"},
+		{"2.341", 2, decimal.Up, "2.35"},
+		{"-2.341", 2, decimal.Floor, "-2.35"},
+		{"-2.349", 2, decimal.Ceiling, "-2.34"},
+		{"1250", -2, decimal.HalfEven, "1200"},
+		{"1.5", 7, decimal.HalfEven, "1.5"},
+	}
+	for _, c := range cases {
+		actual := mustParse(t, c.value).Round(c.places, c.mode)
+		assert.Equal(t, c.expected, actual.String(), "%v", c)
+	}
+}
+
+func TestConversions(t *testing.T) {
+	d, err := decimal.FromFloat(0.1 + 0.2)
+	assert.Nil(t, err)
+	assert.Equal(t, mustParse(t, "0.3"), d)
+	_, err = decimal.FromFloat(1e300)
+	assert.NotNil(t, err)
+
+	d, err = decimal.FromRat(big.NewRat(1, 3), decimal.Up)
+	assert.Nil(t, err)
+	assert.Equal(t, "0.333334", d.String())
+	assert.Equal(t, 0, big.NewRat(3, 2).Cmp(mustParse(t, "1.5").Rat()))
+	d, err = decimal.FromInt(42)
+	assert.Nil(t, err)
+	assert.Equal(t, decimal.Decimal(42_000_000), d)
+	assert.Equal(t, 1.5, mustParse(t, "1.5").Float64())
+}
+
+func TestOverflow(t *testing.T) {
+	max, min := decimal.Decimal(math.MaxInt64), decimal.Decimal(math.MinInt64)
+	// Out of range values are clamped, never wrapped.
+	d, err := decimal.FromInt(math.MaxInt64 / decimal.Scale)
+	assert.Nil(t, err)
+	assert.Equal(t, decimal.Decimal(math.MaxInt64/decimal.Scale*decimal.Scale), d)
+	d, err = decimal.FromInt(math.MaxInt64/decimal.Scale + 1)
+	assert.EqualError(t, err, "decimal out of range: 9223372036855")
+	assert.Equal(t, max, d)
+	d, err = decimal.FromInt(math.MinInt64)
+	assert.NotNil(t, err)
+	assert.Equal(t, min, d)
+
+	d, err = decimal.FromFloat(math.Inf(-1))
+	assert.EqualError(t, err, "decimal out of range: -Inf")
+	assert.Equal(t, min, d)
+	d, err = decimal.FromFloat(1e13)
+	assert.NotNil(t, err)
+	assert.Equal(t, max, d)
+	d, err = decimal.FromFloat(math.NaN())
+	assert.EqualError(t, err, "decimal out of range: NaN")
+	assert.Equal(t, decimal.Decimal(0), d)
+
+	large := mustParse(t, "10000000")
+	d, err = large.Mul(large, decimal.HalfEven)
+	assert.EqualError(t, err, "decimal out of range: 10000000 * 10000000")
+	assert.Equal(t, max, d)
+	d, err = large.Mul(-large, decimal.HalfEven)
+	assert.NotNil(t, err)
+	assert.Equal(t, min, d)
+}
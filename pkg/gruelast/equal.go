@@ -0,0 +1,44 @@
+package gruelast
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// Whether two trees are the same, regardless of their positions
+//
+// Literals are compared as written, so 1000 and 1_000 differ.
+func Equal(a, b Node) bool {
+	if a.Type != b.Type || a.Value != b.Value || len(a.Parameters) != len(b.Parameters) {
+		return false
+	}
+	for i := range a.Parameters {
+		if !Equal(a.Parameters[i], b.Parameters[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hashes a tree regardless of its positions, so that equal trees hash equally
+//
+// Hashes are stable across processes and versions, like for caches.
+func Hash(node Node) uint64 {
+	h := fnv.New64a()
+	var buf [binary.MaxVarintLen64 + 1]byte
+	var hash func(node *Node)
+	hash = func(node *Node) {
+		// Lengths delimit values and parameters.
+		buf[0] = byte(node.Type)
+		n := binary.PutUvarint(buf[1:], uint64(len(node.Value)))
+		h.Write(buf[:n+1])
+		h.Write([]byte(node.Value))
+		n = binary.PutUvarint(buf[:], uint64(len(node.Parameters)))
+		h.Write(buf[:n])
+		for i := range node.Parameters {
+			hash(&node.Parameters[i])
+		}
+	}
+	hash(&node)
+	return h.Sum64()
+}
@@ -0,0 +1,46 @@
+package gruelast_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/gruelast"
+)
+
+func TestEqual(t *testing.T) {
+	a, err := gruelast.Parse("(+ x (* y 2))")
+	assert.Nil(t, err)
+	b, err := gruelast.Parse("  (+ x\n  (* y 2)) ; positions differ")
+	assert.Nil(t, err)
+	assert.True(t, gruelast.Equal(a, b))
+	assert.Equal(t, gruelast.Hash(a), gruelast.Hash(b))
+	built := gruelast.NewList("+", gruelast.NewSymbol("x"),
+		gruelast.NewList("*", gruelast.NewSymbol("y"), gruelast.NewInt(2)))
+	assert.True(t, gruelast.Equal(a, built))
+	assert.Equal(t, gruelast.Hash(a), gruelast.Hash(built))
+
+	hashes := map[uint64]string{gruelast.Hash(a): a.String()}
+	for _, source := range []string{
+		"(+ x (* y 2.0))",
+		"(+ x (* y 02))",
+		"(+ x (* y))",
+		"(+ x (* y 2) 1)",
+		"(+ x (* y \"2\"))",
+		"(+ (* y 2) x)",
+		"(+ x y 2)",
+		"(+ (x) (* y 2))",
+		"(+ xy (* 2))",
+		"(- x (* y 2))",
+	} {
+		node, err := gruelast.Parse(source)
+		assert.Nil(t, err)
+		assert.False(t, gruelast.Equal(a, node), source)
+		assert.NotContains(t, hashes, gruelast.Hash(node), source)
+		hashes[gruelast.Hash(node)] = source
+	}
+}
+
+func TestHashStability(t *testing.T) {
+	// Hashes must not change between versions.
+	assert.Equal(t, uint64(0xfcd7566d5b234529), gruelast.Hash(gruelast.NewList("+", gruelast.NewSymbol("x"), gruelast.NewInt(1))))
+}
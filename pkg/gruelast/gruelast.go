@@ -0,0 +1,177 @@
+// This package exposes the syntax trees of rules for tools that analyze or
+// transform them, like linters or migrations.
+//
+// Nodes are the ones the compiler uses, so rewritten trees can be printed
+// with String and compiled again without conversions.
+package gruelast
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/pkg/decimal"
+)
+
+// A node of a rule, either a list like (+ x 1) or an atom like x or 1
+//
+// Lists hold their operator in Value and their arguments in Parameters,
+// which is nil for atoms. Atoms keep literals as written, like 1_000 or 10u,
+// except that strings and timestamps are unquoted. Pos and End are the byte
+// offsets of the node in the source, or zeros for constructed nodes.
+type Node = gruelparser.GruelAstNode
+
+// Types of nodes
+type Type = gruelparser.TokenType
+
+const (
+	TypeList     Type = gruelparser.TypeParenthesis
+	TypeBool     Type = gruelparser.TypeBool
+	TypeInt      Type = gruelparser.TypeInt
+	TypeUint64   Type = gruelparser.TypeUint64
+	TypeFloat    Type = gruelparser.TypeFloat
+	TypeDecimal  Type = gruelparser.TypeDecimal
+	TypeString   Type = gruelparser.TypeString
+	TypeTime     Type = gruelparser.TypeTime
+	TypeDuration Type = gruelparser.TypeDuration
+	TypeSymbol   Type = gruelparser.TypeSymbol
+)
+
+// The names of the types, also used by the JSON encoding
+var typeNames = map[Type]string{
+	TypeList:     "list",
+	TypeBool:     "bool",
+	TypeInt:      "int",
+	TypeUint64:   "uint64",
+	TypeFloat:    "float",
+	TypeDecimal:  "decimal",
+	TypeString:   "string",
+	TypeTime:     "time",
+	TypeDuration: "duration",
+	TypeSymbol:   "symbol",
+}
+
+// A syntax error with its position in the source
+type SyntaxError = gruelparser.SyntaxError
+
+// A comment with its position in the source
+type Comment = gruelparser.Comment
+
+// Parses top-level forms one at a time from a stream
+type Parser = gruelparser.Parser
+
+// Parses a single form, like (&& (> amount 1000.0) (!= country "NZ"))
+func Parse(src string) (Node, error) {
+	return gruelparser.Parse(src)
+}
+
+// Parses all top-level forms, like those of a rule file
+func ParseAll(src string) ([]Node, error) {
+	return gruelparser.ParseAll(src)
+}
+
+// Creates a parser over a stream, with 0 for the default maximum token size
+func NewParser(r io.Reader, maxTokenSize int) *Parser {
+	return gruelparser.NewParser(r, maxTokenSize)
+}
+
+// Creates a list like (operator params...)
+func NewList(operator string, params ...Node) Node {
+	return Node{Type: TypeList, Value: operator, Parameters: append([]Node{}, params...)}
+}
+
+// Creates a symbol, referring to a variable or a record
+func NewSymbol(name string) Node {
+	return Node{Type: TypeSymbol, Value: name}
+}
+
+func NewBool(v bool) Node {
+	return Node{Type: TypeBool, Value: strconv.FormatBool(v)}
+}
+
+func NewInt(v int64) Node {
+	return Node{Type: TypeInt, Value: strconv.FormatInt(v, 10)}
+}
+
+// Creates an unsigned literal like 10u
+func NewUint(v uint64) Node {
+	return Node{Type: TypeUint64, Value: strconv.FormatUint(v, 10) + "u"}
+}
+
+// Creates a float literal, always with a fraction or an exponent
+func NewFloat(v float64) Node {
+	var s string
+	switch {
+	case math.IsInf(v, 1):
+		s = "inf"
+	case math.IsInf(v, -1):
+		s = "-inf"
+	case math.IsNaN(v):
+		s = "nan"
+	default:
+		s = strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+	}
+	return Node{Type: TypeFloat, Value: s}
+}
+
+// Creates a decimal literal like 12.34d
+func NewDecimal(v decimal.Decimal) Node {
+	return Node{Type: TypeDecimal, Value: v.String() + "d"}
+}
+
+func NewString(v string) Node {
+	return Node{Type: TypeString, Value: v}
+}
+
+// Creates a timestamp, written in RFC 3339
+func NewTime(v time.Time) Node {
+	return Node{Type: TypeTime, Value: v.Format(time.RFC3339Nano)}
+}
+
+func NewDuration(v time.Duration) Node {
+	return Node{Type: TypeDuration, Value: v.String()}
+}
+
+// Checks that a tree would parse back into itself, like after rewriting
+//
+// Only the syntax is checked: operators and symbols are not resolved.
+func Validate(node Node) error {
+	var err error
+	Inspect(&node, func(n *Node) bool {
+		if n == nil || err != nil {
+			return false
+		}
+		if n.Type == TypeList {
+			if n.Parameters == nil || !lexes(n.Value, TypeSymbol, n.Value) {
+				err = fmt.Errorf("invalid operator %q", n.Value)
+			}
+			return true
+		}
+		name, ok := typeNames[n.Type]
+		if !ok {
+			err = fmt.Errorf("unknown node type %d", n.Type)
+		} else if n.Parameters != nil || !lexes(n.String(), n.Type, n.Value) {
+			err = fmt.Errorf("invalid %s %s", name, n.String())
+		}
+		return false
+	})
+	return err
+}
+
+// Whether the text is a single token of the type and the value
+func lexes(text string, t Type, value string) bool {
+	r := gruelparser.NewTokenReader(text)
+	token, tokenType, err := r.NextToken()
+	if err != nil || tokenType != t || token != value {
+		return false
+	}
+	_, _, err = r.NextToken()
+	return err == io.EOF && len(r.Comments()) == 0
+}
@@ -0,0 +1,65 @@
+package gruelast_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/decimal"
+	"github.com/yesh0/gruel/pkg/gruelast"
+)
+
+func TestConstructors(t *testing.T) {
+	price, err := decimal.Parse("12.34")
+	assert.Nil(t, err)
+	for _, c := range []struct {
+		node     gruelast.Node
+		expected string
+	}{
+		{gruelast.NewSymbol("user.age"), "user.age"},
+		{gruelast.NewBool(true), "true"},
+		{gruelast.NewInt(-1000), "-1000"},
+		{gruelast.NewUint(math.MaxUint64), "18446744073709551615u"},
+		{gruelast.NewFloat(2), "2.0"},
+		{gruelast.NewFloat(1e21), "1e+21"},
+		{gruelast.NewFloat(math.Inf(-1)), "-inf"},
+		{gruelast.NewFloat(math.NaN()), "nan"},
+		{gruelast.NewDecimal(price), "12.34d"},
+		{gruelast.NewString("a \"b\"\n"), `"a \"b\"\n"`},
+		{gruelast.NewTime(time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)), `#t"2006-01-02T15:04:05Z"`},
+		{gruelast.NewDuration(90 * time.Minute), "1h30m0s"},
+		{gruelast.NewList("now"), "(now)"},
+		{gruelast.NewList("+", gruelast.NewSymbol("x"), gruelast.NewInt(1)), "(+ x 1)"},
+	} {
+		assert.Equal(t, c.expected, c.node.String())
+		assert.Nil(t, gruelast.Validate(c.node), c.expected)
+		parsed, err := gruelast.Parse(c.expected)
+		assert.Nil(t, err)
+		assert.True(t, gruelast.Equal(c.node, parsed), c.expected)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for msg, node := range map[string]gruelast.Node{
+		`invalid operator "1"`:             gruelast.NewList("1"),
+		`invalid operator "a b"`:           gruelast.NewList("a b", gruelast.NewInt(1)),
+		"invalid symbol 1":                 gruelast.NewSymbol("1"),
+		"invalid symbol true":              gruelast.NewSymbol("true"),
+		"invalid int 1x":                   {Type: gruelast.TypeInt, Value: "1x"},
+		"invalid float 1":                  {Type: gruelast.TypeFloat, Value: "1"},
+		"invalid symbol x":                 {Type: gruelast.TypeSymbol, Value: "x", Parameters: []gruelast.Node{}},
+		"unknown node type 9":              {Type: gruelast.Type(9), Value: "1"},
+		`invalid operator "(x)"`:           gruelast.NewList("f", gruelast.NewList("(x)")),
+		"invalid symbol a;comment":         gruelast.NewList("f", gruelast.NewSymbol("a;comment")),
+		"invalid decimal 1e2d":             {Type: gruelast.TypeDecimal, Value: "1e2d"},
+		"invalid duration 5":               {Type: gruelast.TypeDuration, Value: "5"},
+		"invalid uint64 -1u":               {Type: gruelast.TypeUint64, Value: "-1u"},
+		"invalid bool True":                {Type: gruelast.TypeBool, Value: "True"},
+		`invalid operator ""`:              {Type: gruelast.TypeList},
+		"invalid string \"x\"":             {Type: gruelast.TypeString, Value: "x", Parameters: []gruelast.Node{}},
+		"invalid int 99999999999999999999": {Type: gruelast.TypeInt, Value: "99999999999999999999"},
+	} {
+		assert.EqualError(t, gruelast.Validate(node), msg, node.String())
+	}
+}
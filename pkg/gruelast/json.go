@@ -0,0 +1,100 @@
+package gruelast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// The JSON form of nodes, with fields in a fixed order
+type jsonNode struct {
+	Type   string     `json:"type"`
+	Value  string     `json:"value"`
+	Pos    int        `json:"pos"`
+	End    int        `json:"end"`
+	Params []jsonNode `json:"params,omitempty"`
+}
+
+// Encodes a tree as JSON, like:
+//
+//	{"type":"list","value":"+","pos":0,"end":7,"params":[
+//	  {"type":"symbol","value":"x","pos":3,"end":4},
+//	  {"type":"int","value":"1","pos":5,"end":6}]}
+//
+// Types are named list, bool, int, uint64, float, decimal, string, time,
+// duration or symbol, and values are kept as in Node. The encoding only
+// changes in compatible ways, like by adding types.
+func Marshal(node Node) ([]byte, error) {
+	encoded, err := toJSON(&node)
+	if err != nil {
+		return nil, err
+	}
+	// Operators like && read better unescaped.
+	buf := bytes.Buffer{}
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(encoded); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func toJSON(node *Node) (jsonNode, error) {
+	name, ok := typeNames[node.Type]
+	if !ok {
+		return jsonNode{}, fmt.Errorf("unknown node type %d", node.Type)
+	}
+	encoded := jsonNode{Type: name, Value: node.Value, Pos: node.Pos, End: node.End}
+	for i := range node.Parameters {
+		param, err := toJSON(&node.Parameters[i])
+		if err != nil {
+			return jsonNode{}, err
+		}
+		encoded.Params = append(encoded.Params, param)
+	}
+	return encoded, nil
+}
+
+// Decodes a tree encoded by Marshal, checking it with Validate
+func Unmarshal(data []byte) (Node, error) {
+	var decoded jsonNode
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return Node{}, err
+	}
+	node, err := fromJSON(&decoded)
+	if err != nil {
+		return Node{}, err
+	}
+	if err := Validate(node); err != nil {
+		return Node{}, err
+	}
+	return node, nil
+}
+
+func fromJSON(decoded *jsonNode) (Node, error) {
+	node := Node{Value: decoded.Value, Pos: decoded.Pos, End: decoded.End}
+	found := false
+	for t, name := range typeNames {
+		if name == decoded.Type {
+			node.Type, found = t, true
+		}
+	}
+	if !found {
+		return Node{}, fmt.Errorf("unknown node type %q", decoded.Type)
+	}
+	if node.Type != TypeList {
+		if decoded.Params != nil {
+			return Node{}, fmt.Errorf("%s %s cannot have parameters", decoded.Type, decoded.Value)
+		}
+		return node, nil
+	}
+	node.Parameters = make([]Node, len(decoded.Params))
+	for i := range decoded.Params {
+		param, err := fromJSON(&decoded.Params[i])
+		if err != nil {
+			return Node{}, err
+		}
+		node.Parameters[i] = param
+	}
+	return node, nil
+}
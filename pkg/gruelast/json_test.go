@@ -0,0 +1,47 @@
+package gruelast_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/gruelast"
+)
+
+func TestMarshal(t *testing.T) {
+	node, err := gruelast.Parse(`(&& (> amount 1_000.0) (now) (in-tz #t"2006-01-02T15:04:05Z" "UTC") 5m 10u 1.5d true)`)
+	assert.Nil(t, err)
+	data, err := gruelast.Marshal(node)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"type":"list","value":"&&","pos":0,"end":85,"params":[`+
+		`{"type":"list","value":">","pos":4,"end":22,"params":[`+
+		`{"type":"symbol","value":"amount","pos":7,"end":13},`+
+		`{"type":"float","value":"1_000.0","pos":14,"end":21}]},`+
+		`{"type":"list","value":"now","pos":23,"end":28},`+
+		`{"type":"list","value":"in-tz","pos":29,"end":67,"params":[`+
+		`{"type":"time","value":"2006-01-02T15:04:05Z","pos":36,"end":60},`+
+		`{"type":"string","value":"UTC","pos":61,"end":66}]},`+
+		`{"type":"duration","value":"5m","pos":68,"end":70},`+
+		`{"type":"uint64","value":"10u","pos":71,"end":74},`+
+		`{"type":"decimal","value":"1.5d","pos":75,"end":79},`+
+		`{"type":"bool","value":"true","pos":80,"end":84}]}`, string(data))
+
+	decoded, err := gruelast.Unmarshal(data)
+	assert.Nil(t, err)
+	assert.Equal(t, node, decoded)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for data, msg := range map[string]string{
+		`{"type":"atom","value":"x"}`:                                         `unknown node type "atom"`,
+		`{"type":"symbol","value":"x","params":[]}`:                           "symbol x cannot have parameters",
+		`{"type":"symbol","value":"x","params":[{"type":"int","value":"1"}]}`: "symbol x cannot have parameters",
+		`{"type":"list","value":"+","params":[{"type":"int","value":"x"}]}`:   "invalid int x",
+		`{"type":"list","value":"1"}`:                                         `invalid operator "1"`,
+		`[]`:                                                                  "json: cannot unmarshal array into Go value of type gruelast.jsonNode",
+	} {
+		_, err := gruelast.Unmarshal([]byte(data))
+		assert.EqualError(t, err, msg, data)
+	}
+	_, err := gruelast.Marshal(gruelast.Node{Type: gruelast.Type(9)})
+	assert.EqualError(t, err, "unknown node type 9")
+}
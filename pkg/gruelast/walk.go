@@ -0,0 +1,54 @@
+package gruelast
+
+// Visits nodes in Walk, like ast.Visitor
+//
+// Visit is called with each node and, unless it returns nil, the returned
+// visitor then visits the parameters, followed by a call with nil.
+type Visitor interface {
+	Visit(node *Node) (w Visitor)
+}
+
+// Traverses a tree in depth-first order
+//
+// Visitors may modify the nodes in place, which changes the tree.
+func Walk(v Visitor, node *Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	for i := range node.Parameters {
+		Walk(v, &node.Parameters[i])
+	}
+	v.Visit(nil)
+}
+
+type inspector func(*Node) bool
+
+func (f inspector) Visit(node *Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Traverses a tree in depth-first order, like ast.Inspect
+//
+// The parameters of a node are skipped if f returns false for it.
+// Otherwise f is called with nil after them.
+func Inspect(node *Node, f func(*Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Returns a copy of a tree with each node replaced by f, bottom-up
+//
+// f sees nodes whose parameters are already rewritten, and the nodes it
+// returns are not rewritten again. The original tree is left untouched.
+func Rewrite(node Node, f func(Node) Node) Node {
+	if node.Parameters != nil {
+		params := make([]Node, len(node.Parameters))
+		for i := range node.Parameters {
+			params[i] = Rewrite(node.Parameters[i], f)
+		}
+		node.Parameters = params
+	}
+	return f(node)
+}
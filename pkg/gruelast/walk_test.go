@@ -0,0 +1,79 @@
+package gruelast_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/gruelast"
+)
+
+type symbolCounter map[string]int
+
+func (c symbolCounter) Visit(node *gruelast.Node) gruelast.Visitor {
+	if node != nil && node.Type == gruelast.TypeSymbol {
+		c[node.Value]++
+	}
+	return c
+}
+
+func TestWalk(t *testing.T) {
+	node, err := gruelast.Parse(`(&& (> amount limit) (|| (== country "NZ") (> amount (* limit 2))))`)
+	assert.Nil(t, err)
+	counts := symbolCounter{}
+	gruelast.Walk(counts, &node)
+	assert.Equal(t, symbolCounter{"amount": 2, "limit": 2, "country": 1}, counts)
+
+	// Visitors may change the tree in place.
+	gruelast.Inspect(&node, func(n *gruelast.Node) bool {
+		if n != nil && n.Type == gruelast.TypeSymbol && n.Value == "limit" {
+			n.Value = "threshold"
+		}
+		return true
+	})
+	assert.Equal(t, `(&& (> amount threshold) (|| (== country "NZ") (> amount (* threshold 2))))`, node.String())
+}
+
+func TestInspect(t *testing.T) {
+	node, err := gruelast.Parse("(+ (* a b) (- c) d)")
+	assert.Nil(t, err)
+	var visits []string
+	gruelast.Inspect(&node, func(n *gruelast.Node) bool {
+		if n == nil {
+			visits = append(visits, "end")
+			return false
+		}
+		visits = append(visits, n.String())
+		// Skips the parameters of (- c).
+		return n.Value != "-"
+	})
+	assert.Equal(t, []string{"(+ (* a b) (- c) d)", "(* a b)", "a", "end", "b", "end", "end", "(- c)", "d", "end", "end"},
+		visits)
+}
+
+func TestRewrite(t *testing.T) {
+	source := "(&& (> user.age 18) (< (get user \"age\") 65))"
+	node, err := gruelast.Parse(source)
+	assert.Nil(t, err)
+	var order []string
+	rewritten := gruelast.Rewrite(node, func(n gruelast.Node) gruelast.Node {
+		order = append(order, n.String())
+		switch {
+		case n.Type == gruelast.TypeSymbol && strings.HasPrefix(n.Value, "user."):
+			return gruelast.NewList("get", gruelast.NewSymbol("person"), gruelast.NewString(n.Value[5:]))
+		case n.Type == gruelast.TypeList && n.Value == "get" && n.Parameters[0].Value == "user":
+			n.Parameters[0] = gruelast.NewSymbol("person")
+		}
+		return n
+	})
+	assert.Equal(t, `(&& (> (get person "age") 18) (< (get person "age") 65))`, rewritten.String())
+	assert.Nil(t, gruelast.Validate(rewritten))
+	// Parameters come before their lists.
+	assert.Equal(t, []string{"user.age", "18", `(> (get person "age") 18)`, "user"}, order[:4])
+	// The original tree is unchanged.
+	assert.Equal(t, source, node.String())
+	assert.Equal(t, 7, node.Parameters[0].Parameters[0].Pos)
+	// Constructed nodes have no positions, while the others keep theirs.
+	assert.Equal(t, 0, rewritten.Parameters[0].Parameters[0].Pos)
+	assert.Equal(t, 4, rewritten.Parameters[0].Pos)
+}
@@ -0,0 +1,215 @@
+// This package formats rules canonically, for `gruel fmt`.
+//
+// Forms that fit in Width columns stay on one line, while longer ones
+// put every argument after the first on its own line, aligned under the first one.
+// Top-level forms are separated by blank lines.
+// Literals are kept as written and comments are kept either after the token
+// they follow or on their own lines, so that formatting twice changes nothing.
+package gruelfmt
+
+import (
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+)
+
+// The column limit, beyond which forms are broken into lines
+const Width = 80
+
+// Formats rules, failing on syntax errors
+//
+// Top-level forms are separated by blank lines, and so are comments
+// that were followed by one. Files without forms keep only their comments.
+func Format(src []byte) ([]byte, error) {
+	source := string(src)
+	forms, err := gruelparser.ParseAll(source)
+	if err != nil {
+		return nil, err
+	}
+	// Tokenizes everything again to collect comments.
+	reader := gruelparser.NewTokenReader(source)
+	for {
+		if _, _, err := reader.NextToken(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	p := printer{source: source, comments: reader.Comments(), fresh: true}
+	for i := range forms {
+		if i != 0 {
+			// Trailing comments of the previous form stay on its line.
+			for len(p.comments) != 0 && p.comments[0].Pos < forms[i].Pos &&
+				p.pending == "" && p.trailing(p.comments[0]) {
+				p.pending = p.comments[0].Text
+				p.comments = p.comments[1:]
+			}
+			p.newline(0)
+			p.newline(0)
+		}
+		p.flushComments(forms[i].Pos, 0)
+		p.node(&forms[i])
+	}
+	p.flushComments(len(source)+1, 0)
+	if !p.fresh {
+		p.newline(0)
+	}
+	return []byte(p.sb.String()), nil
+}
+
+type printer struct {
+	source string
+	// Comments not written yet
+	comments []gruelparser.Comment
+	sb       strings.Builder
+	column   int
+	// Whether nothing but indentation was written on the current line
+	fresh bool
+	// A comment to write at the end of the current line
+	pending string
+}
+
+func (p *printer) write(s string) {
+	p.sb.WriteString(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		p.column = utf8.RuneCountInString(s[i+1:])
+	} else {
+		p.column += utf8.RuneCountInString(s)
+	}
+	p.fresh = false
+}
+
+func (p *printer) newline(indent int) {
+	if p.pending != "" {
+		p.sb.WriteString(" ")
+		p.sb.WriteString(p.pending)
+		p.pending = ""
+	}
+	p.sb.WriteByte('\n')
+	p.sb.WriteString(strings.Repeat(" ", indent))
+	p.column = indent
+	p.fresh = true
+}
+
+// Writes the comments before the offset
+//
+// Comments following a token on the same line stay at the end of the line,
+// while the others get lines of their own.
+func (p *printer) flushComments(pos, indent int) {
+	for len(p.comments) != 0 && p.comments[0].Pos < pos {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		if p.pending == "" && p.trailing(c) && p.sb.Len() != 0 {
+			p.pending = c.Text
+			continue
+		}
+		if !p.fresh {
+			p.newline(indent)
+		}
+		p.write(c.Text)
+		p.newline(indent)
+		if indent == 0 && p.blankAfter(c, pos) {
+			p.newline(0)
+		}
+	}
+}
+
+// Whether a blank line follows the comment, before anything else up to the offset
+func (p *printer) blankAfter(c gruelparser.Comment, pos int) bool {
+	next := pos
+	if len(p.comments) != 0 && p.comments[0].Pos < next {
+		next = p.comments[0].Pos
+	}
+	if next >= len(p.source) {
+		return false
+	}
+	return strings.Count(p.source[c.End:next], "\n") > 1
+}
+
+// Whether the comment follows something on its line
+func (p *printer) trailing(c gruelparser.Comment) bool {
+	line := p.source[strings.LastIndexByte(p.source[:c.Pos], '\n')+1 : c.Pos]
+	return strings.TrimLeftFunc(line, unicode.IsSpace) != ""
+}
+
+// Writes the node starting from the current column
+func (p *printer) node(node *gruelparser.GruelAstNode) {
+	if node.Type != gruelparser.TypeParenthesis {
+		p.write(p.source[node.Pos:node.End])
+		return
+	}
+	if flat := p.flat(node); p.fits(node, flat) {
+		p.write(flat)
+		return
+	}
+
+	start := p.column
+	p.write("(" + node.Value)
+	// Arguments are aligned under the first one unless the operator is too far right.
+	indent, aligned := start+utf8.RuneCountInString(node.Value)+2, true
+	if indent > Width/2 {
+		indent, aligned = start+2, false
+	}
+	for i := range node.Parameters {
+		param := &node.Parameters[i]
+		p.flushComments(param.Pos, indent)
+		switch {
+		case p.fresh:
+		case i == 0 && aligned && p.pending == "":
+			p.write(" ")
+		default:
+			p.newline(indent)
+		}
+		p.node(param)
+	}
+	// Comments followed by nothing but closing parentheses are written after them.
+	p.write(")")
+}
+
+// Renders the node on a single line
+func (p *printer) flat(node *gruelparser.GruelAstNode) string {
+	if node.Type != gruelparser.TypeParenthesis {
+		return p.source[node.Pos:node.End]
+	}
+	sb := strings.Builder{}
+	sb.WriteByte('(')
+	sb.WriteString(node.Value)
+	for i := range node.Parameters {
+		sb.WriteByte(' ')
+		sb.WriteString(p.flat(&node.Parameters[i]))
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
+
+// Whether the node fits on the current line, without comments to break it
+func (p *printer) fits(node *gruelparser.GruelAstNode, flat string) bool {
+	if strings.ContainsRune(flat, '\n') || p.column+utf8.RuneCountInString(flat) > Width {
+		return false
+	}
+	last := lastToken(node)
+	for _, c := range p.comments {
+		if c.Pos >= last {
+			break
+		}
+		if c.Pos > node.Pos {
+			return false
+		}
+	}
+	return true
+}
+
+// The byte offset right after the last token of the node, closing parentheses aside
+func lastToken(node *gruelparser.GruelAstNode) int {
+	if node.Type != gruelparser.TypeParenthesis {
+		return node.End
+	}
+	if len(node.Parameters) == 0 {
+		return node.Pos + 1
+	}
+	return lastToken(&node.Parameters[len(node.Parameters)-1])
+}
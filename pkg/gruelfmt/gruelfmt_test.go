@@ -0,0 +1,88 @@
+package gruelfmt_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/gruelfmt"
+)
+
+func assertFormat(t *testing.T, expected, source string) {
+	t.Helper()
+	formatted, err := gruelfmt.Format([]byte(source))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(formatted))
+	again, err := gruelfmt.Format(formatted)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(again), "formatting is not idempotent")
+}
+
+func TestFormatShort(t *testing.T) {
+	assertFormat(t, "1\n", "  1  ")
+	assertFormat(t, "(+ 1 2)\n", "(+\n  1\n\t2 )")
+	assertFormat(t, "(f)\n", "( f )")
+	// Literals are kept as written.
+	assertFormat(t, "(== s \"a\\x41\" #t\"2026-01-01T00:00:00Z\" 0x1F 1h30m)\n",
+		"(== s   \"a\\x41\" #t\"2026-01-01T00:00:00Z\"\n 0x1F 1h30m)")
+}
+
+func TestFormatLong(t *testing.T) {
+	long := "(&& (> amount 1000.0) (== country \"NZ\") (|| (contains? note \"refund\") (< age 18)))"
+	assertFormat(t, `(&& (> amount 1000.0)
+    (== country "NZ")
+    (|| (contains? note "refund") (< age 18)))
+`, long)
+	assertFormat(t, `(|| (== a b)
+    (&& (> amount 1000.0)
+        (== country "NZ")
+        (|| (contains? note "refund") (< age 18))))
+`, "(|| (== a b) "+long+")")
+
+	// Deep forms fall back to indenting by two columns.
+	name := strings.Repeat("x", 40)
+	assertFormat(t, `(`+name+`
+  (+ 1 2)
+  (`+name+`
+    (+ 3 4)
+    "`+strings.Repeat("y", 40)+`"))
+`, "("+name+" (+ 1 2) ("+name+" (+ 3 4) \""+strings.Repeat("y", 40)+"\"))")
+}
+
+func TestFormatComments(t *testing.T) {
+	assertFormat(t, "; leading\n; lines\n(+ 1 2) ; trailing\n; after\n",
+		"\n; leading\n   ; lines\n(+ 1 2)    ; trailing\n\n; after")
+	// Comments break forms.
+	assertFormat(t, "(&& a ; why\n    b)\n", "(&& a ; why\n b)")
+	assertFormat(t, "(&& a\n    ; why\n    b)\n", "(&& a\n; why\nb)")
+	assertFormat(t, "(&& ; first\n    a\n    b)\n", "(&& ; first\na b)")
+	// Comments before closing parentheses move after them.
+	assertFormat(t, "(+ a (- b 1)) ; c\n", "(+ a (- b 1 ; c\n))")
+	assertFormat(t, "(+ (- b 1) ; c\n   a)\n", "(+ (- b 1 ; c\n) a)")
+	assertFormat(t, "(+ a b)\n; c\n", "(+ a b\n; c\n)")
+}
+
+func TestFormatErrors(t *testing.T) {
+	for _, source := range []string{"(+ 1", "(+ 1 2))", "\"open", "#| open"} {
+		_, err := gruelfmt.Format([]byte(source))
+		assert.NotNil(t, err, source)
+	}
+}
+
+func TestFormatForms(t *testing.T) {
+	assertFormat(t, "", "")
+	assertFormat(t, "", " \n\n")
+	assertFormat(t, "; nothing\n", "; nothing")
+	assertFormat(t, "; first\n\n; second\n", "  ; first\n\n\n; second\n\n")
+	assertFormat(t, "1\n\n(+ 2 3)\n", "1 (+ 2\n3)")
+	assertFormat(t, `; header
+
+; first
+(declare (x int)) ; schema
+
+#| second
+   rule |#
+(> x 1)
+`, "; header\n\n\n; first\n(declare (x int)) ; schema\n#| second\n   rule |#\n(> x 1)\n\n")
+	assertFormat(t, "(+ 1 #| two |#\n   2)\n", "(+ 1 #| two |# 2)")
+}
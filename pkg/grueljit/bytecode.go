@@ -0,0 +1,37 @@
+package grueljit
+
+import (
+	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/internal/ir"
+)
+
+// Compiles the code into bytecode for Load, without generating machine code
+//
+// Bytecode refers to host functions and intrinsics by names,
+// and is tied to the operator table of the Gruel version producing it.
+// Only time zones loadable by name are supported by WithLocation.
+func CompileBytecode(code string, symbols map[string]byte, opts ...Option) ([]byte, error) {
+	ast, err := gruelparser.Parse(code)
+	if err != nil {
+		return nil, err
+	}
+	o := collectOptions(opts)
+	builder, err := ir.Compile(&ast, symbols, o.ir)
+	if err != nil {
+		return nil, err
+	}
+	return ir.Marshal(builder, code)
+}
+
+// Loads bytecode from CompileBytecode, skipping parsing and type checking
+//
+// Host functions are resolved from the registry by names and must have the same signatures.
+// Options that affect code generation, like WithLenientMath, have no effect.
+func Load(bytecode []byte, opts ...Option) (*Function, error) {
+	o := collectOptions(opts)
+	builder, source, err := ir.Unmarshal(bytecode, o.ir)
+	if err != nil {
+		return nil, err
+	}
+	return newFunction(builder, source, o)
+}
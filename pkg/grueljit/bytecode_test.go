@@ -0,0 +1,79 @@
+package grueljit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+func TestBytecode(t *testing.T) {
+	requireLibJit(t)
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	assert.Nil(t, err)
+	symbols := map[string]byte{"s": grueljit.TypeString, "t": grueljit.TypeTime, "i": grueljit.TypeInt}
+	cases := []struct {
+		expr     string
+		args     map[string]any
+		expected any
+	}{
+		{"(index s \"fox\")", map[string]any{"s": "quick fox"}, uint64(6)},
+		{"(->string (+ i 1))", map[string]any{"i": 41}, "42"},
+		{"(hour t)", map[string]any{"t": time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}, uint64(8)},
+		{"(< t #t\"2026-01-01\")", map[string]any{"t": time.Date(2025, 12, 31, 17, 0, 0, 0, time.UTC)}, uint64(0)},
+	}
+	for _, c := range cases {
+		bytecode, err := grueljit.CompileBytecode(c.expr, symbols, grueljit.WithLocation(loc))
+		assert.Nil(t, err, c.expr)
+		f, err := grueljit.Load(bytecode)
+		assert.Nil(t, err, c.expr)
+		v, err := f.Call(c.args)
+		assert.Nil(t, err, c.expr)
+		assert.Equal(t, c.expected, v, c.expr)
+		f.Free()
+	}
+
+	bytecode, err := grueljit.CompileBytecode("(+ 1\n  (/ i 0))", symbols)
+	assert.Nil(t, err)
+	f, err := grueljit.Load(bytecode)
+	assert.Nil(t, err)
+	_, err = f.Call(map[string]any{"i": 1})
+	assert.EqualError(t, err, "runtime error at 2:3: division by zero in /")
+	f.Free()
+
+	_, err = grueljit.Load([]byte("(+ 1 2)"))
+	assert.NotNil(t, err)
+	_, err = grueljit.Load(bytecode[:len(bytecode)-1])
+	assert.NotNil(t, err)
+	_, err = grueljit.CompileBytecode("(hour t)", symbols, grueljit.WithLocation(time.FixedZone("X", 3600)))
+	assert.NotNil(t, err)
+}
+
+func TestBytecodeHostFunctions(t *testing.T) {
+	requireLibJit(t)
+	sig := grueljit.Signature{Args: []byte{grueljit.TypeInt}, Result: grueljit.TypeInt}
+	r := grueljit.NewRegistry()
+	assert.Nil(t, r.Register("double", sig, func(i int) int { return 2 * i }))
+	bytecode, err := grueljit.CompileBytecode("(double 21)", nil, grueljit.WithRegistry(r))
+	assert.Nil(t, err)
+
+	// Handles are process-specific, so functions are resolved again by names.
+	other := grueljit.NewRegistry()
+	assert.Nil(t, other.Register("double", sig, func(i int) int { return i + i }))
+	f, err := grueljit.Load(bytecode, grueljit.WithRegistry(other))
+	assert.Nil(t, err)
+	v, err := f.Call(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(42), v)
+	f.Free()
+
+	_, err = grueljit.Load(bytecode)
+	assert.EqualError(t, err, "function double not found")
+	mismatched := grueljit.NewRegistry()
+	assert.Nil(t, mismatched.Register("double", grueljit.Signature{
+		Args: []byte{grueljit.TypeFloat}, Result: grueljit.TypeFloat,
+	}, func(f float64) float64 { return 2 * f }))
+	_, err = grueljit.Load(bytecode, grueljit.WithRegistry(mismatched))
+	assert.EqualError(t, err, "function double not found")
+}
@@ -0,0 +1,169 @@
+package grueljit
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+)
+
+// A cache of compiled functions, keyed by expressions and their symbol types
+//
+// Expressions are normalized, so that ones differing only in spaces share entries.
+// Functions obtained from Get are borrowed and must be returned with Release
+// instead of being freed. Evicted functions are freed after they are all returned.
+// Positions in runtime errors refer to the expression that was compiled first.
+type Cache struct {
+	mutex sync.Mutex
+	// Bounds on the entry count and estimated memory, 0 for unlimited
+	maxEntries int
+	maxMemory  int
+	options    []Option
+	// Entries from the most recently used to the least
+	lru     list.List
+	entries map[string]*list.Element
+	stats   CacheStats
+}
+
+type cacheEntry struct {
+	key      string
+	function *Function
+}
+
+// Statistics of a cache
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+	// Estimated executable memory of cached functions, see Function.Size
+	Memory int
+}
+
+// Creates a cache compiling with the options
+func NewCache(maxEntries int, maxMemory int, opts ...Option) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		maxMemory:  maxMemory,
+		options:    opts,
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Returns a cached function or compiles one, which must be returned with Release
+func (c *Cache) Get(code string, symbols map[string]byte) (*Function, error) {
+	key, err := cacheKey(code, symbols)
+	if err != nil {
+		return nil, err
+	}
+	if f := c.borrow(key, true); f != nil {
+		return f, nil
+	}
+
+	// Compiles without the lock, keeping the first one if raced.
+	f, err := Compile(code, symbols, c.options...)
+	if err != nil {
+		return nil, err
+	}
+	f.acquire()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if other := c.borrowLocked(key, false); other != nil {
+		f.release()
+		f.Free()
+		return other, nil
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, function: f})
+	c.stats.Entries++
+	c.stats.Memory += f.Size()
+	c.evict()
+	return f, nil
+}
+
+func (c *Cache) borrow(key string, count bool) *Function {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	f := c.borrowLocked(key, count)
+	if f == nil && count {
+		c.stats.Misses++
+	}
+	return f
+}
+
+func (c *Cache) borrowLocked(key string, count bool) *Function {
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*cacheEntry)
+	if !entry.function.acquire() {
+		// Freed by someone else, who should have called Release
+		c.remove(element)
+		return nil
+	}
+	c.lru.MoveToFront(element)
+	if count {
+		c.stats.Hits++
+	}
+	return entry.function
+}
+
+// Returns a function obtained from Get
+func (c *Cache) Release(f *Function) {
+	f.release()
+}
+
+// Evicts least recently used entries beyond the bounds, keeping the newest one
+func (c *Cache) evict() {
+	for c.lru.Len() > 1 && (c.maxEntries > 0 && c.stats.Entries > c.maxEntries ||
+		c.maxMemory > 0 && c.stats.Memory > c.maxMemory) {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *Cache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.stats.Entries--
+	c.stats.Memory -= entry.function.Size()
+	entry.function.Free()
+}
+
+// Evicts all entries
+func (c *Cache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for c.lru.Len() != 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// Returns the statistics
+func (c *Cache) Stats() CacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stats
+}
+
+// Normalizes the expression and appends the sorted symbol types
+func cacheKey(code string, symbols map[string]byte) (string, error) {
+	ast, err := gruelparser.Parse(code)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(symbols))
+	for name := range symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	key := strings.Builder{}
+	key.WriteString(ast.String())
+	for _, name := range names {
+		key.WriteString(fmt.Sprintf("\x00%s:%d", name, symbols[name]))
+	}
+	return key.String(), nil
+}
@@ -0,0 +1,81 @@
+package grueljit_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+func TestCache(t *testing.T) {
+	c := grueljit.NewCache(2, 0)
+	ints := map[string]byte{"x": grueljit.TypeInt}
+	floats := map[string]byte{"x": grueljit.TypeFloat}
+
+	f, err := c.Get("(+ x 1)", ints)
+	assert.Nil(t, err)
+	g, err := c.Get("(+  x\n 1 )", ints)
+	assert.Nil(t, err)
+	assert.Same(t, f, g)
+	h, err := c.Get("(+ x 1)", floats)
+	assert.Nil(t, err)
+	assert.NotSame(t, f, h)
+	v, err := h.Call(map[string]any{"x": 0.5})
+	assert.Nil(t, err)
+	assert.Equal(t, 1.5, v)
+	c.Release(g)
+	c.Release(h)
+
+	_, err = c.Get("(+ x", ints)
+	assert.NotNil(t, err)
+	assert.Equal(t, grueljit.CacheStats{
+		Hits: 1, Misses: 2, Entries: 2, Memory: f.Size() + h.Size(),
+	}, c.Stats())
+
+	// Evicted functions stay alive while borrowed.
+	e, err := c.Get("(* x 2)", ints)
+	assert.Nil(t, err)
+	c.Release(e)
+	stats := c.Stats()
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, 2, stats.Entries)
+	v, err = f.Call(map[string]any{"x": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), v)
+	c.Release(f)
+	_, err = f.Call(map[string]any{"x": 1})
+	assert.ErrorIs(t, err, grueljit.ErrFreed)
+
+	// Cached again after eviction
+	f, err = c.Get("(+ x 1)", ints)
+	assert.Nil(t, err)
+	v, err = f.Call(map[string]any{"x": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), v)
+	c.Release(f)
+	assert.Equal(t, uint64(4), c.Stats().Misses)
+
+	c.Clear()
+	assert.Equal(t, 0, c.Stats().Entries)
+	assert.Equal(t, 0, c.Stats().Memory)
+	_, err = f.Call(map[string]any{"x": 1})
+	assert.ErrorIs(t, err, grueljit.ErrFreed)
+}
+
+func TestCacheMemory(t *testing.T) {
+	f, err := grueljit.Compile("(+ x 1)", map[string]byte{"x": grueljit.TypeInt})
+	assert.Nil(t, err)
+	size := f.Size()
+	f.Free()
+
+	c := grueljit.NewCache(0, size*3/2)
+	for _, expr := range []string{"(+ x 1)", "(+ x 2)", "(+ x 3)"} {
+		f, err := c.Get(expr, map[string]byte{"x": grueljit.TypeInt})
+		assert.Nil(t, err)
+		c.Release(f)
+	}
+	stats := c.Stats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, uint64(2), stats.Evictions)
+	assert.Equal(t, size, stats.Memory)
+}
@@ -0,0 +1,248 @@
+package grueljit
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/internal/ir"
+)
+
+// Counts how often boolean sub-expressions are true or false
+//
+// Counters are updated by Call on functions compiled WithCoverage.
+type Coverage struct {
+	source string
+	ast    gruelparser.GruelAstNode
+	// Boolean sub-expressions by nodes in pre-order, nil for others
+	conditions []*condition
+	// Parameters bound by Specialize, passed to the sub-expressions
+	bindings map[string]any
+}
+
+type condition struct {
+	node *gruelparser.GruelAstNode
+	// The compiled sub-expression, nil for the root
+	f          *Function
+	trueCount  atomic.Uint64
+	falseCount atomic.Uint64
+}
+
+// A boolean sub-expression and how often it was true or false
+type Condition struct {
+	// The sub-expression as written
+	Text string
+	// The byte offsets of the sub-expression in the source
+	Pos   int
+	End   int
+	True  uint64
+	False uint64
+}
+
+// Compiles every boolean sub-expression but the root, which is the function itself
+func newCoverage(f *Function, source string, ast gruelparser.GruelAstNode,
+	symbols map[string]byte, o options) (*Coverage, error) {
+	if f.hosts {
+		return nil, fmt.Errorf("coverage cannot count rules calling host functions, which it would call again")
+	}
+	c := &Coverage{source: source, ast: ast}
+	// Sub-expressions are not instrumented themselves.
+	o.coverage = false
+	var walk func(node *gruelparser.GruelAstNode, root bool) error
+	walk = func(node *gruelparser.GruelAstNode, root bool) error {
+		var cond *condition
+		record := node.Type == gruelparser.TypeParenthesis && node.Value == "get"
+		switch {
+		case root:
+			if f.result == TypeBool {
+				cond = &condition{node: node}
+			}
+		case node.Type == gruelparser.TypeParenthesis || node.Type == gruelparser.TypeSymbol:
+			builder, err := ir.Compile(node, symbols, o.ir)
+			if err != nil {
+				return err
+			}
+			if builder.ResultType() != gruelparser.TypeBool {
+				break
+			}
+			g, err := newFunction(builder, source, o)
+			if err != nil {
+				return err
+			}
+			cond = &condition{node: node, f: g}
+		}
+		c.conditions = append(c.conditions, cond)
+		if !record {
+			for i := range node.Parameters {
+				if err := walk(&node.Parameters[i], false); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(&c.ast, true); err != nil {
+		c.free()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Coverage) free() {
+	for _, cond := range c.conditions {
+		if cond != nil && cond.f != nil {
+			cond.f.Free()
+		}
+	}
+}
+
+// Evaluates the sub-expressions, skipping those that fail
+func (c *Coverage) count(args map[string]any, result any, err error) {
+	if len(c.bindings) != 0 {
+		// Bound parameters win over arguments, which the function ignores.
+		merged := make(map[string]any, len(args)+len(c.bindings))
+		for name, value := range args {
+			merged[name] = value
+		}
+		for name, value := range c.bindings {
+			merged[name] = value
+		}
+		args = merged
+	}
+	for _, cond := range c.conditions {
+		if cond == nil {
+			continue
+		}
+		value, err := result, err
+		if cond.f != nil {
+			value, err = cond.f.Call(args)
+		}
+		switch {
+		case err != nil:
+		case value != uint64(0):
+			cond.trueCount.Add(1)
+		default:
+			cond.falseCount.Add(1)
+		}
+	}
+}
+
+// Returns the counters of the function, or nil if compiled without WithCoverage
+func (f *Function) Coverage() *Coverage {
+	return f.coverage
+}
+
+// Returns the counters of boolean sub-expressions in source order
+func (c *Coverage) Conditions() []Condition {
+	var conditions []Condition
+	for _, cond := range c.conditions {
+		if cond != nil {
+			conditions = append(conditions, Condition{
+				Text:  c.source[cond.node.Pos:cond.node.End],
+				Pos:   cond.node.Pos,
+				End:   cond.node.End,
+				True:  cond.trueCount.Load(),
+				False: cond.falseCount.Load(),
+			})
+		}
+	}
+	return conditions
+}
+
+// Writes one line per condition with its position, counters and source,
+// followed by the number of outcomes seen
+func (c *Coverage) WriteText(w io.Writer) error {
+	conditions := c.Conditions()
+	sb := strings.Builder{}
+	sb.WriteString("position\ttrue\tfalse\tcondition\n")
+	covered := 0
+	for _, cond := range conditions {
+		line := strings.Count(c.source[:cond.Pos], "\n") + 1
+		column := cond.Pos - strings.LastIndexByte(c.source[:cond.Pos], '\n')
+		fmt.Fprintf(&sb, "%d:%d\t%d\t%d\t%s\n", line, column, cond.True, cond.False,
+			strings.Join(strings.Fields(cond.Text), " "))
+		for _, n := range []uint64{cond.True, cond.False} {
+			if n != 0 {
+				covered++
+			}
+		}
+	}
+	fmt.Fprintf(&sb, "covered %d of %d outcomes\n", covered, 2*len(conditions))
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// Writes the source as an HTML page, with conditions highlighted
+// by whether they were both true and false, only one of them, or never evaluated
+func (c *Coverage) WriteHTML(w io.Writer) error {
+	sb := strings.Builder{}
+	sb.WriteString(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Gruel coverage</title>
+<style>
+pre { line-height: 1.6; }
+span { border-bottom: 2px solid; }
+.covered { border-color: #2a2; background: #e4f6e4; }
+.partial { border-color: #c90; background: #fbf0d4; }
+.uncovered { border-color: #c22; background: #fbe0e0; }
+</style>
+</head>
+<body>
+<pre>`)
+	index := 0
+	sb.WriteString(html.EscapeString(c.source[:c.ast.Pos]))
+	c.renderHTML(&sb, &c.ast, &index)
+	sb.WriteString(html.EscapeString(c.source[c.ast.End:]))
+	sb.WriteString("</pre>\n</body>\n</html>\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (c *Coverage) renderHTML(sb *strings.Builder, node *gruelparser.GruelAstNode, index *int) {
+	cond := c.conditions[*index]
+	*index++
+	if cond != nil {
+		t, f := cond.trueCount.Load(), cond.falseCount.Load()
+		class := "partial"
+		switch {
+		case t != 0 && f != 0:
+			class = "covered"
+		case t == 0 && f == 0:
+			class = "uncovered"
+		}
+		fmt.Fprintf(sb, `<span class="%s" title="true %d, false %d">`, class, t, f)
+	}
+	pos := node.Pos
+	if node.Type != gruelparser.TypeParenthesis || node.Value != "get" {
+		for i := range node.Parameters {
+			param := &node.Parameters[i]
+			if param.Pos < pos {
+				// Constants inlined by LoadRules share the position of their names.
+				c.skip(param, index)
+				continue
+			}
+			sb.WriteString(html.EscapeString(c.source[pos:param.Pos]))
+			c.renderHTML(sb, param, index)
+			pos = param.End
+		}
+	}
+	sb.WriteString(html.EscapeString(c.source[pos:node.End]))
+	if cond != nil {
+		sb.WriteString("</span>")
+	}
+}
+
+// Skips the conditions of a sub-tree
+func (c *Coverage) skip(node *gruelparser.GruelAstNode, index *int) {
+	*index++
+	if node.Type != gruelparser.TypeParenthesis || node.Value != "get" {
+		for i := range node.Parameters {
+			c.skip(&node.Parameters[i], index)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package grueljit_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+func TestCoverage(t *testing.T) {
+	f, err := grueljit.Compile("(|| (> amount 100)\n    (&& vip (< amount 0)))", map[string]byte{
+		"amount": grueljit.TypeInt,
+		"vip":    grueljit.TypeBool,
+	}, grueljit.WithCoverage())
+	assert.Nil(t, err)
+	for _, amount := range []int{50, 150, 200} {
+		_, err := f.Call(map[string]any{"amount": amount, "vip": false})
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, []grueljit.Condition{
+		{Text: "(|| (> amount 100)\n    (&& vip (< amount 0)))", Pos: 0, End: 45, True: 2, False: 1},
+		{Text: "(> amount 100)", Pos: 4, End: 18, True: 2, False: 1},
+		{Text: "(&& vip (< amount 0))", Pos: 23, End: 44, True: 0, False: 3},
+		{Text: "vip", Pos: 27, End: 30, True: 0, False: 3},
+		{Text: "(< amount 0)", Pos: 31, End: 43, True: 0, False: 3},
+	}, f.Coverage().Conditions())
+
+	sb := strings.Builder{}
+	assert.Nil(t, f.Coverage().WriteText(&sb))
+	assert.Equal(t, "position\ttrue\tfalse\tcondition\n"+
+		"1:1\t2\t1\t(|| (> amount 100) (&& vip (< amount 0)))\n"+
+		"1:5\t2\t1\t(> amount 100)\n"+
+		"2:5\t0\t3\t(&& vip (< amount 0))\n"+
+		"2:9\t0\t3\tvip\n"+
+		"2:13\t0\t3\t(< amount 0)\n"+
+		"covered 7 of 10 outcomes\n", sb.String())
+
+	sb.Reset()
+	assert.Nil(t, f.Coverage().WriteHTML(&sb))
+	assert.Contains(t, sb.String(), `<pre><span class="covered" title="true 2, false 1">(|| `+
+		`<span class="covered" title="true 2, false 1">(&gt; amount 100)</span>`+"\n    "+
+		`<span class="partial" title="true 0, false 3">(&amp;&amp; `+
+		`<span class="partial" title="true 0, false 3">vip</span> `+
+		`<span class="partial" title="true 0, false 3">(&lt; amount 0)</span>)</span>)</span></pre>`)
+
+	g, err := grueljit.Compile("(+ 1 2)", nil)
+	assert.Nil(t, err)
+	assert.Nil(t, g.Coverage())
+	f.Free()
+	g.Free()
+}
+
+func TestCoverageErrors(t *testing.T) {
+	f, err := grueljit.Compile("(&& (> (/ x y) 1) (get user \"active\"))", map[string]byte{
+		"x":           grueljit.TypeInt,
+		"y":           grueljit.TypeInt,
+		"user.active": grueljit.TypeBool,
+	}, grueljit.WithCoverage())
+	assert.Nil(t, err)
+	_, err = f.Call(map[string]any{"x": 1, "y": 0, "user": map[string]any{"active": true}})
+	assert.NotNil(t, err)
+	_, err = f.Call(map[string]any{"x": 4, "y": 2, "user": map[string]any{"active": true}})
+	assert.Nil(t, err)
+	conditions := f.Coverage().Conditions()
+	assert.Len(t, conditions, 3)
+	assert.Equal(t, [][2]uint64{{1, 0}, {1, 0}, {2, 0}}, [][2]uint64{
+		{conditions[0].True, conditions[0].False},
+		{conditions[1].True, conditions[1].False},
+		{conditions[2].True, conditions[2].False},
+	})
+	assert.Equal(t, `(get user "active")`, conditions[2].Text)
+	f.Free()
+}
+
+func TestCoverageHostFunctions(t *testing.T) {
+	requireLibJit(t)
+	r := grueljit.NewRegistry()
+	calls := 0
+	assert.Nil(t, r.Register("count", grueljit.Signature{
+		Args:   []byte{grueljit.TypeInt},
+		Result: grueljit.TypeInt,
+	}, func(v int64) int64 {
+		calls++
+		return v
+	}))
+	symbols := map[string]byte{"x": grueljit.TypeInt}
+	_, err := grueljit.Compile("(&& (> (count x) 1) (< (count x) 5))", symbols,
+		grueljit.WithRegistry(r), grueljit.WithCoverage())
+	assert.EqualError(t, err, "coverage cannot count rules calling host functions, which it would call again")
+
+	f, err := grueljit.Compile("(&& (> (count x) 1) (< (count x) 5))", symbols, grueljit.WithRegistry(r))
+	assert.Nil(t, err)
+	_, err = f.Call(map[string]any{"x": 3})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+	_, err = f.Explain(map[string]any{"x": 3})
+	assert.EqualError(t, err, "explaining cannot evaluate rules calling host functions, which it would call again")
+	assert.Equal(t, 2, calls)
+	f.Free()
+}
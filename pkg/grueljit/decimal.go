@@ -0,0 +1,53 @@
+package grueljit
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+
+	"github.com/yesh0/gruel/pkg/decimal"
+)
+
+// Fixed-point decimals, as accepted and returned by Call
+type Decimal = decimal.Decimal
+
+// Converts a parameter into a decimal
+//
+// Strings are parsed exactly, while rationals and floats are rounded half to even.
+// Integers out of the range of decimals are rejected.
+func convertDecimal(param any) (uint64, error) {
+	var d decimal.Decimal
+	var err error
+	switch v := param.(type) {
+	case decimal.Decimal:
+		d = v
+	case string:
+		d, err = decimal.Parse(v)
+	case *big.Rat:
+		if v == nil {
+			return 0, fmt.Errorf("unsupported conversion from nil")
+		}
+		d, err = decimal.FromRat(v, decimal.HalfEven)
+	case float32:
+		d, err = decimal.FromFloat(float64(v))
+	case float64:
+		d, err = decimal.FromFloat(v)
+	case bool:
+		return 0, fmt.Errorf("unsupported conversion from bool")
+	default:
+		var i uint64
+		i, err = convertType(param, TypeInt)
+		if err != nil {
+			return 0, err
+		}
+		// Unsigned values beyond math.MaxInt64 pass as negative ints.
+		if r := reflect.ValueOf(param); r.CanUint() && r.Uint() > math.MaxInt64 {
+			return 0, fmt.Errorf("decimal out of range: %v", param)
+		}
+		if d, err = decimal.FromInt(int64(i)); err != nil {
+			return 0, err
+		}
+	}
+	return uint64(d), err
+}
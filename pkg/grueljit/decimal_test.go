@@ -0,0 +1,100 @@
+package grueljit_test
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/decimal"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+func assertDecimal(t *testing.T, expr string, args map[string]any, expected any) {
+	f, err := grueljit.Compile(expr, map[string]byte{
+		"price": grueljit.TypeDecimal,
+		"qty":   grueljit.TypeInt,
+	})
+	assert.Nil(t, err, expr)
+	v, err := f.Call(args)
+	assert.Nil(t, err, expr)
+	if d, ok := v.(grueljit.Decimal); ok {
+		assert.Equal(t, expected, d.String(), expr)
+	} else {
+		assert.Equal(t, expected, v, expr)
+	}
+	f.Free()
+}
+
+func TestDecimal(t *testing.T) {
+	requireLibJit(t)
+	assertResult(t, "(== (+ 0.1 0.2) 0.3)", 0)
+	assertResult(t, "(== (+ 0.1d 0.2d) 0.3d)", 1)
+	assertDecimal(t, "(+ 0.1d 0.2d)", nil, "0.3")
+	assertDecimal(t, "(/ 2d 3d)", nil, "0.666667")
+	assertDecimal(t, "(* 0.000001d 0.5d)", nil, "0")
+	assertDecimal(t, "(round-half-up 2.345d 2)", nil, "2.35")
+	assertDecimal(t, "(round-half-even 2.345d 2)", nil, "2.34")
+	assertDecimal(t, "(round-floor -2.341d 2)", nil, "-2.35")
+	assertDecimal(t, "(->decimal 3)", nil, "3")
+	assertDecimal(t, "(->decimal 0.125)", nil, "0.125")
+
+	args := map[string]any{"price": "19.99", "qty": 3}
+	assertDecimal(t, "(* price qty)", args, "59.97")
+	assertDecimal(t, "(- (* price qty) 0.97d)", args, "59")
+	assertDecimal(t, "(> (* price qty) 50d)", args, uint64(1))
+	assertDecimal(t, "price", map[string]any{"price": big.NewRat(1, 3)}, "0.333333")
+	assertDecimal(t, "price", map[string]any{"price": decimal.Decimal(1)}, "0.000001")
+	assertDecimal(t, "price", map[string]any{"price": 2}, "2")
+}
+
+func TestDecimalConsistency(t *testing.T) {
+	requireLibJit(t)
+	f, err := grueljit.Compile("(round-half-even (/ (* a b) c) 2)", map[string]byte{
+		"a": grueljit.TypeDecimal,
+		"b": grueljit.TypeDecimal,
+		"c": grueljit.TypeDecimal,
+	})
+	assert.Nil(t, err)
+	values := []decimal.Decimal{1, -1, 500_000, 1_234_567, -98_765_432, 3_000_000, 7}
+	for _, a := range values {
+		for _, b := range values {
+			for _, c := range values {
+				product, err := a.Mul(b, decimal.HalfEven)
+				assert.Nil(t, err)
+				expected := product.Div(c, decimal.HalfEven).Round(2, decimal.HalfEven)
+				v, err := f.Call(map[string]any{"a": a, "b": b, "c": c})
+				assert.Nil(t, err)
+				assert.Equal(t, expected, v)
+			}
+		}
+	}
+	f.Free()
+}
+
+func TestDecimalErrors(t *testing.T) {
+	symbols := map[string]byte{"price": grueljit.TypeDecimal, "x": grueljit.TypeFloat}
+	for _, expr := range []string{
+		"(+ price 1)", "(< price 1)", "(/ price 2)", "(+ price x)",
+		"(sqrt price)", "(round-up price 1.5)", "1.0000001d",
+	} {
+		_, err := grueljit.Compile(expr, symbols)
+		assert.NotNil(t, err, expr)
+	}
+
+	f, err := grueljit.Compile("price", symbols)
+	assert.Nil(t, err)
+	_, err = f.Call(map[string]any{"price": "1.2.3"})
+	assert.NotNil(t, err)
+	_, err = f.Call(map[string]any{"price": true})
+	assert.NotNil(t, err)
+	for _, value := range []any{int64(9_223_372_036_855), -9_223_372_036_855, uint64(math.MaxUint64)} {
+		_, err = f.Call(map[string]any{"price": value})
+		assert.EqualError(t, err, fmt.Sprintf("decimal out of range: %v", value))
+	}
+	v, err := f.Call(map[string]any{"price": uint(9_223_372_036_854)})
+	assert.Nil(t, err)
+	assert.Equal(t, "9223372036854", v.(grueljit.Decimal).String())
+	f.Free()
+}
@@ -0,0 +1,105 @@
+package grueljit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yesh0/gruel/internal/ir"
+)
+
+// Returned by calls to freed functions
+var ErrFreed = errors.New("function already freed")
+
+// Kinds of runtime faults
+type Fault byte
+
+const (
+	// Integer (or decimal) division by zero
+	FaultDivisionByZero = Fault(ir.CheckDivisor)
+	// Negative shift counts or counts not less than the width of the value
+	FaultShift = Fault(ir.CheckShift)
+	// NaN results, only reported with WithStrictNaN
+	FaultNaN = Fault(ir.CheckNaN)
+	// Errors returned (or panics raised) by host functions
+	FaultHost = Fault(ir.CheckHost)
+	// Decimal results out of range, like products and conversions,
+	// which are clamped instead with WithLenientMath
+	FaultOverflow = Fault(ir.CheckOverflow)
+)
+
+// Implements fmt.Stringer
+func (f Fault) String() string {
+	switch f {
+	case FaultDivisionByZero:
+		return "division by zero"
+	case FaultShift:
+		return "invalid shift amount"
+	case FaultNaN:
+		return "NaN result"
+	case FaultHost:
+		return "host function error"
+	case FaultOverflow:
+		return "decimal overflow"
+	default:
+		return fmt.Sprintf("fault %d", byte(f))
+	}
+}
+
+// An error raised by compiled code, returned by Call and CallRaw
+type RuntimeError struct {
+	Fault Fault
+	// The operator that failed, like "/"
+	Operator string
+	// The position of the operator in the source, starting from 1 (0 if unknown)
+	Line   int
+	Column int
+	// The error from the host function with FaultHost
+	Err error
+}
+
+func (e *RuntimeError) Error() string {
+	msg := fmt.Sprintf("runtime error at %d:%d: %s in %s", e.Line, e.Column, e.Fault, e.Operator)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Returns the error from the host function, if any
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// Prepares runtime errors for every checked operator
+func runtimeErrors(code string, sites []ir.Site) []RuntimeError {
+	if len(sites) == 0 {
+		return nil
+	}
+	errors := make([]RuntimeError, len(sites))
+	for i, site := range sites {
+		errors[i] = RuntimeError{
+			Fault:    Fault(site.Check),
+			Operator: site.Operator,
+		}
+		// Bytecode may come without the source.
+		if code != "" {
+			errors[i].Line = strings.Count(code[:site.Pos], "\n") + 1
+			errors[i].Column = site.Pos - strings.LastIndexByte(code[:site.Pos], '\n')
+		}
+	}
+	return errors
+}
+
+// Decodes the error slot, see ir.IrBuilder.Sites
+func (f *Function) runtimeError(slot uint64, ctx *hostContext) error {
+	site := int(slot >> 8)
+	if site < 1 || site > len(f.errors) {
+		return fmt.Errorf("unknown runtime error %#x", slot)
+	}
+	err := f.errors[site-1]
+	if err.Fault == FaultHost && ctx != nil {
+		err.Err = ctx.err
+	}
+	return &err
+}
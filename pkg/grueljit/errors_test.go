@@ -0,0 +1,102 @@
+package grueljit_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/decimal"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+func assertRuntimeError(t *testing.T, expr string, args map[string]any, expected grueljit.RuntimeError,
+	opts ...grueljit.Option) {
+	f, err := grueljit.Compile(expr, map[string]byte{
+		"x": grueljit.TypeFloat,
+		"i": grueljit.TypeInt,
+		"u": grueljit.TypeUint8,
+		"d": grueljit.TypeDecimal,
+	}, opts...)
+	assert.Nil(t, err, expr)
+	_, err = f.Call(args)
+	var runtimeErr *grueljit.RuntimeError
+	if assert.True(t, errors.As(err, &runtimeErr), expr) {
+		assert.Equal(t, expected, *runtimeErr, expr)
+	}
+	f.Free()
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	requireLibJit(t)
+	assertRuntimeError(t, "(/ 123000 0)", nil,
+		grueljit.RuntimeError{Fault: grueljit.FaultDivisionByZero, Operator: "/", Line: 1, Column: 1})
+	assertRuntimeError(t, "(+ 1\n   (% i 0))", map[string]any{"i": 7},
+		grueljit.RuntimeError{Fault: grueljit.FaultDivisionByZero, Operator: "%", Line: 2, Column: 4})
+	assertRuntimeError(t, "(/ 1d d)", map[string]any{"d": 0},
+		grueljit.RuntimeError{Fault: grueljit.FaultDivisionByZero, Operator: "/", Line: 1, Column: 1})
+	assertRuntimeError(t, "(<< 1 i)", map[string]any{"i": 64},
+		grueljit.RuntimeError{Fault: grueljit.FaultShift, Operator: "<<", Line: 1, Column: 1})
+	assertRuntimeError(t, "(>> u i)", map[string]any{"u": 1, "i": -1},
+		grueljit.RuntimeError{Fault: grueljit.FaultShift, Operator: ">>", Line: 1, Column: 1})
+	assertRuntimeError(t, "(* 2 (sqrt x))", map[string]any{"x": -1.},
+		grueljit.RuntimeError{Fault: grueljit.FaultNaN, Operator: "sqrt", Line: 1, Column: 6},
+		grueljit.WithStrictNaN())
+	assertRuntimeError(t, "(* d d)", map[string]any{"d": decimal.Decimal(math.MaxInt64 / 1000)},
+		grueljit.RuntimeError{Fault: grueljit.FaultOverflow, Operator: "*", Line: 1, Column: 1})
+	assertRuntimeError(t, "(->decimal i)", map[string]any{"i": math.MinInt64},
+		grueljit.RuntimeError{Fault: grueljit.FaultOverflow, Operator: "->decimal", Line: 1, Column: 1})
+	assertRuntimeError(t, "(->decimal x)", map[string]any{"x": math.NaN()},
+		grueljit.RuntimeError{Fault: grueljit.FaultOverflow, Operator: "->decimal", Line: 1, Column: 1})
+
+	f, err := grueljit.Compile("(/ 1 i)", map[string]byte{"i": grueljit.TypeInt})
+	assert.Nil(t, err)
+	_, err = f.Call(map[string]any{"i": 0})
+	assert.Equal(t, "runtime error at 1:1: division by zero in /", err.Error())
+	// Errors are cleared between calls.
+	v, err := f.Call(map[string]any{"i": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), v)
+	f.Free()
+}
+
+func TestLenientMath(t *testing.T) {
+	for _, expr := range []string{"(/ 123000 0)", "(% 123000 0)"} {
+		f, err := grueljit.Compile(expr, nil, grueljit.WithLenientMath())
+		assert.Nil(t, err)
+		v, err := f.Call(nil)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(0), v)
+		f.Free()
+	}
+
+	f, err := grueljit.Compile("(sqrt x)", map[string]byte{"x": grueljit.TypeFloat})
+	assert.Nil(t, err)
+	v, err := f.Call(map[string]any{"x": -1.})
+	assert.Nil(t, err)
+	assert.True(t, math.IsNaN(v.(float64)))
+	f.Free()
+}
+
+func TestLenientDecimals(t *testing.T) {
+	requireLibJit(t)
+	symbols := map[string]byte{"d": grueljit.TypeDecimal, "i": grueljit.TypeInt, "x": grueljit.TypeFloat}
+	for _, c := range []struct {
+		expr     string
+		args     map[string]any
+		expected decimal.Decimal
+	}{
+		{"(* d d)", map[string]any{"d": decimal.Decimal(math.MaxInt64 / 1000)}, math.MaxInt64},
+		{"(* d (- d))", map[string]any{"d": decimal.Decimal(math.MaxInt64 / 1000)}, math.MinInt64},
+		{"(->decimal i)", map[string]any{"i": math.MaxInt64}, math.MaxInt64},
+		{"(->decimal x)", map[string]any{"x": math.Inf(-1)}, math.MinInt64},
+		{"(->decimal x)", map[string]any{"x": math.NaN()}, 0},
+	} {
+		f, err := grueljit.Compile(c.expr, symbols, grueljit.WithLenientMath())
+		assert.Nil(t, err, c.expr)
+		v, err := f.Call(c.args)
+		assert.Nil(t, err, c.expr)
+		assert.Equal(t, c.expected, v, c.expr)
+		f.Free()
+	}
+}
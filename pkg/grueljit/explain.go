@@ -0,0 +1,210 @@
+package grueljit
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/internal/ir"
+)
+
+// The evaluation of a sub-expression, mirroring the AST, see Function.Explain
+type Explanation struct {
+	// The operator, the parameter name (dotted for record fields) or the literal as written
+	Value string
+	// The operands of operators, nil for parameters and literals
+	Parameters []Explanation
+	// Whether Value names a parameter
+	Symbol bool
+	// The byte offset in the source
+	Pos int
+	// The result of operators, converted like results of Call except that
+	// booleans are bool, or the argument of parameters (nil for literals)
+	Result any
+	// Why the sub-expression could not be evaluated, like a RuntimeError
+	Err error
+}
+
+// Renders the tree compactly, like `(&& (> amount[50] 100)=false true)=false`
+//
+// Failed operators end with "!" and the reason instead of their results.
+func (e *Explanation) String() string {
+	sb := strings.Builder{}
+	e.render(&sb)
+	return sb.String()
+}
+
+func (e *Explanation) render(sb *strings.Builder) {
+	switch {
+	case e.Symbol:
+		sb.WriteString(e.Value)
+		sb.WriteByte('[')
+		if e.Err != nil {
+			sb.WriteByte('?')
+		} else {
+			sb.WriteString(formatResult(e.Result))
+		}
+		sb.WriteByte(']')
+		return
+	case e.Parameters == nil:
+		sb.WriteString(e.Value)
+		return
+	}
+	sb.WriteByte('(')
+	sb.WriteString(e.Value)
+	for i := range e.Parameters {
+		sb.WriteByte(' ')
+		e.Parameters[i].render(sb)
+	}
+	sb.WriteByte(')')
+	var runtimeErr *RuntimeError
+	switch {
+	case errors.As(e.Err, &runtimeErr):
+		sb.WriteString("!" + runtimeErr.Fault.String())
+	case e.Err != nil:
+		sb.WriteString("!" + e.Err.Error())
+	default:
+		sb.WriteString("=" + formatResult(e.Result))
+	}
+}
+
+func formatResult(v any) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(v)
+}
+
+// Sub-expressions compiled on the first call to Explain
+type explainer struct {
+	source  string
+	ast     gruelparser.GruelAstNode
+	symbols map[string]byte
+	options options
+	// Values bound by Specialize
+	bindings map[string]any
+
+	once sync.Once
+	// Compiled operators and compilation errors, by nodes in pre-order
+	functions []*Function
+	errors    []error
+}
+
+// Compiles every operator but the root, which is the function itself
+func (e *explainer) compile() {
+	var walk func(node *gruelparser.GruelAstNode, root bool)
+	walk = func(node *gruelparser.GruelAstNode, root bool) {
+		var f *Function
+		var err error
+		operator := node.Type == gruelparser.TypeParenthesis && node.Value != "get"
+		if operator && !root {
+			var builder *ir.IrBuilder
+			builder, err = ir.Compile(node, e.symbols, e.options.ir)
+			if err == nil {
+				f, err = newFunction(builder, e.source, e.options)
+			}
+		}
+		e.functions = append(e.functions, f)
+		e.errors = append(e.errors, err)
+		if operator {
+			for i := range node.Parameters {
+				walk(&node.Parameters[i], false)
+			}
+		}
+	}
+	walk(&e.ast, true)
+}
+
+func (e *explainer) free() {
+	for _, f := range e.functions {
+		if f != nil {
+			f.Free()
+		}
+	}
+}
+
+// Evaluates the rule along with every sub-expression
+//
+// Sub-expressions are compiled into functions of their own on the first call,
+// leaving Call as fast as before. Each node is evaluated independently, so that
+// operators failing at runtime, like the root, still have their operands explained.
+// Every node thus recomputes its whole sub-tree, taking O(nodes × depth) time,
+// and rules calling host functions are rejected, since they would be called
+// once per enclosing node. Only compiled functions come with the source needed,
+// and not those from Load.
+func (f *Function) Explain(args map[string]any) (*Explanation, error) {
+	if !f.acquire() {
+		return nil, ErrFreed
+	}
+	defer f.release()
+	e := f.explain
+	if e == nil {
+		return nil, fmt.Errorf("explaining requires the source")
+	}
+	if f.hosts {
+		return nil, fmt.Errorf("explaining cannot evaluate rules calling host functions, which it would call again")
+	}
+	e.once.Do(e.compile)
+	if e.bindings != nil {
+		merged := make(map[string]any, len(args)+len(e.bindings))
+		for name, value := range args {
+			merged[name] = value
+		}
+		for name, value := range e.bindings {
+			merged[name] = value
+		}
+		args = merged
+	}
+	index := 0
+	return e.evaluate(f, &e.ast, args, &index), nil
+}
+
+func (e *explainer) evaluate(root *Function, node *gruelparser.GruelAstNode,
+	args map[string]any, index *int) *Explanation {
+	i := *index
+	*index++
+	explained := &Explanation{Value: node.String(), Pos: node.Pos}
+	if node.Type == gruelparser.TypeSymbol || node.Value == "get" && node.Type == gruelparser.TypeParenthesis {
+		name := node.Value
+		if node.Type == gruelparser.TypeParenthesis {
+			// Checked when compiled
+			name, _ = ir.FieldPath(node)
+		}
+		explained.Value, explained.Symbol = name, true
+		value, ok := args[name]
+		if !ok {
+			value, ok = lookupPath(args, strings.Split(name, "."))
+		}
+		if ok {
+			explained.Result = value
+		} else {
+			explained.Err = fmt.Errorf("parameter %s not found", name)
+		}
+		return explained
+	}
+	if node.Type != gruelparser.TypeParenthesis {
+		return explained
+	}
+
+	explained.Value = node.Value
+	explained.Parameters = make([]Explanation, len(node.Parameters))
+	for j := range node.Parameters {
+		explained.Parameters[j] = *e.evaluate(root, &node.Parameters[j], args, index)
+	}
+	f := e.functions[i]
+	if i == 0 {
+		f = root
+	}
+	if f == nil {
+		explained.Err = e.errors[i]
+		return explained
+	}
+	explained.Result, explained.Err = f.Call(args)
+	if b, ok := explained.Result.(uint64); ok && f.result == TypeBool {
+		explained.Result = b != 0
+	}
+	return explained
+}
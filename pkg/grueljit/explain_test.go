@@ -0,0 +1,76 @@
+package grueljit_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+func TestExplain(t *testing.T) {
+	f, err := grueljit.Compile("(&& (> amount 100) (! blocked))", map[string]byte{
+		"amount":  grueljit.TypeInt,
+		"blocked": grueljit.TypeBool,
+	})
+	assert.Nil(t, err)
+	e, err := f.Explain(map[string]any{"amount": 50, "blocked": false})
+	assert.Nil(t, err)
+	assert.Equal(t, "(&& (> amount[50] 100)=false (! blocked[false])=true)=false", e.String())
+	assert.Equal(t, "&&", e.Value)
+	assert.Equal(t, false, e.Result)
+	assert.Len(t, e.Parameters, 2)
+	assert.Equal(t, 4, e.Parameters[0].Pos)
+	assert.Equal(t, true, e.Parameters[0].Parameters[0].Symbol)
+	assert.Equal(t, 50, e.Parameters[0].Parameters[0].Result)
+	assert.Nil(t, e.Parameters[0].Parameters[1].Result)
+
+	e, err = f.Explain(map[string]any{"amount": 50})
+	assert.Nil(t, err)
+	assert.Equal(t, "(&& (> amount[50] 100)=false (! blocked[?])!parameter blocked not found)"+
+		"!parameter blocked not found", e.String())
+	f.Free()
+	_, err = f.Explain(nil)
+	assert.Equal(t, grueljit.ErrFreed, err)
+}
+
+func TestExplainErrors(t *testing.T) {
+	f, err := grueljit.Compile(`(+ (* user.age 2) (/ x 0))`, map[string]byte{
+		"user.age": grueljit.TypeInt,
+		"x":        grueljit.TypeInt,
+	})
+	assert.Nil(t, err)
+	e, err := f.Explain(map[string]any{"user": map[string]any{"age": 20}, "x": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "(+ (* user.age[20] 2)=40 (/ x[1] 0)!division by zero)!division by zero", e.String())
+	var runtimeErr *grueljit.RuntimeError
+	assert.ErrorAs(t, e.Parameters[1].Err, &runtimeErr)
+	assert.Equal(t, 1, runtimeErr.Line)
+	assert.Equal(t, 19, runtimeErr.Column)
+
+	g, err := f.Specialize(map[string]any{"x": 0})
+	assert.Nil(t, err)
+	e, err = g.Explain(map[string]any{"user": map[string]any{"age": 1}})
+	assert.Nil(t, err)
+	assert.Equal(t, "(+ (* user.age[1] 2)=2 (/ x[0] 0)!division by zero)!division by zero", e.String())
+	f.Free()
+	g.Free()
+
+	code, err := grueljit.CompileBytecode("(+ 1 2)", nil)
+	assert.Nil(t, err)
+	f, err = grueljit.Load(code)
+	assert.Nil(t, err)
+	_, err = f.Explain(nil)
+	assert.EqualError(t, err, "explaining requires the source")
+	f.Free()
+}
+
+func TestExplainStrings(t *testing.T) {
+	f, err := grueljit.Compile(`(== (get user "name") "a\"b")`, map[string]byte{
+		"user.name": grueljit.TypeString,
+	})
+	assert.Nil(t, err)
+	e, err := f.Explain(map[string]any{"user": map[string]any{"name": "a"}})
+	assert.Nil(t, err)
+	assert.Equal(t, `(== user.name["a"] "a\"b")=false`, e.String())
+	f.Free()
+}
@@ -0,0 +1,76 @@
+package grueljit_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/internal/gogen/golden"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+// Generated functions return typed results where Call returns uint64.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return uint64(1)
+		}
+		return uint64(0)
+	case int64:
+		return uint64(v)
+	default:
+		return v
+	}
+}
+
+func TestGoGen(t *testing.T) {
+	requireLibJit(t)
+	for _, c := range golden.Cases {
+		f, err := grueljit.Compile(c.Code, c.Symbols)
+		assert.Nil(t, err, c.Code)
+		if err == nil {
+			assertGolden(t, c, f)
+			f.Free()
+		}
+	}
+}
+
+// Runs the cases the native backend supports, which needs no LibJIT
+func TestGoGenNative(t *testing.T) {
+	compiled := 0
+	for _, c := range golden.Cases {
+		f, err := grueljit.Compile(c.Code, c.Symbols, grueljit.WithNativeBackend())
+		if err != nil {
+			assert.Contains(t, err.Error(), "not supported by the native backend", c.Code)
+			continue
+		}
+		assertGolden(t, c, f)
+		f.Free()
+		compiled++
+	}
+	assert.Greater(t, compiled, len(golden.Cases)/2)
+}
+
+// Compares the generated function of a case with a compiled one
+func assertGolden(t *testing.T, c golden.Case, f *grueljit.Function) {
+	for _, args := range c.Args {
+		expected, jitErr := f.Call(args)
+		actual, err := c.Call(args)
+		if jitErr != nil {
+			assert.EqualError(t, err, jitErr.Error(), c.Code)
+			continue
+		}
+		assert.Nil(t, err, c.Code)
+		actual = normalize(actual)
+		switch e, ok := expected.(float64); {
+		case ok && math.IsNaN(e):
+			assert.True(t, math.IsNaN(actual.(float64)), "%s %v", c.Code, args)
+		case ok && e != 0 && !math.IsInf(e, 0):
+			// LibJIT may call into libm, whose results can differ in the last bits.
+			assert.InEpsilon(t, e, actual, 1e-12, "%s %v", c.Code, args)
+		default:
+			assert.Equal(t, expected, actual, "%s %v", c.Code, args)
+		}
+	}
+}
@@ -1,4 +1,12 @@
+//go:build !nolibjit
+
 #include "gruel_jit.h"
+#include "_cgo_export.h"
+#include <math.h>
+#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
 
 jit_int is_jit_supported() {
   jit_init();
@@ -46,6 +54,356 @@ jit_long gruel_streq(void *s, void *t) {
                      str1->len) == 0;
 }
 
+static jit_long floor_div(jit_long a, jit_long b) {
+  jit_long q = a / b;
+  return (a % b != 0 && (a < 0) != (b < 0)) ? q - 1 : q;
+}
+
+static jit_long floor_mod(jit_long a, jit_long b) {
+  return a - floor_div(a, b) * b;
+}
+
+// Converts Unix nanoseconds into local seconds with a zone table
+// (see ir.zoneTable for the layout).
+static jit_long local_seconds(jit_long nanos, void *zone) {
+  jit_long seconds = floor_div(nanos, 1000000000);
+  jit_long *table = (jit_long *)zone;
+  jit_long n = table[0];
+  jit_long *entries = table + 1;
+  if (n == 0) {
+    return seconds;
+  }
+  jit_long lo = 0, hi = n - 1;
+  while (lo < hi) {
+    jit_long mid = (lo + hi + 1) / 2;
+    if (entries[2 * mid] <= seconds) {
+      lo = mid;
+    } else {
+      hi = mid - 1;
+    }
+  }
+  return seconds + entries[2 * lo + 1];
+}
+
+// Days since 1970-01-01 into a civil date, from Howard Hinnant's algorithms.
+static void civil_from_days(jit_long days, jit_long *year, jit_long *month,
+                            jit_long *day) {
+  days += 719468;
+  jit_long era = floor_div(days, 146097);
+  jit_long doe = days - era * 146097;
+  jit_long yoe = (doe - doe / 1460 + doe / 36524 - doe / 146096) / 365;
+  jit_long doy = doe - (365 * yoe + yoe / 4 - yoe / 100);
+  jit_long mp = (5 * doy + 2) / 153;
+  *day = doy - (153 * mp + 2) / 5 + 1;
+  *month = mp < 10 ? mp + 3 : mp - 9;
+  *year = yoe + era * 400 + (*month <= 2);
+}
+
+jit_long gruel_hour(jit_long t, void *zone) {
+  return floor_mod(local_seconds(t, zone), 86400) / 3600;
+}
+
+jit_long gruel_minute(jit_long t, void *zone) {
+  return floor_mod(local_seconds(t, zone), 3600) / 60;
+}
+
+// Sunday being 0, like time.Weekday
+jit_long gruel_weekday(jit_long t, void *zone) {
+  return floor_mod(floor_div(local_seconds(t, zone), 86400) + 4, 7);
+}
+
+jit_long gruel_day_of_month(jit_long t, void *zone) {
+  jit_long year, month, day;
+  civil_from_days(floor_div(local_seconds(t, zone), 86400), &year, &month,
+                  &day);
+  return day;
+}
+
+jit_long gruel_month(jit_long t, void *zone) {
+  jit_long year, month, day;
+  civil_from_days(floor_div(local_seconds(t, zone), 86400), &year, &month,
+                  &day);
+  return month;
+}
+
+jit_long gruel_year(jit_long t, void *zone) {
+  jit_long year, month, day;
+  civil_from_days(floor_div(local_seconds(t, zone), 86400), &year, &month,
+                  &day);
+  return year;
+}
+
+// Rounding modes, see decimal.RoundingMode
+enum Rounding {
+  ROUND_HALF_EVEN = 0,
+  ROUND_HALF_UP,
+  ROUND_DOWN,
+  ROUND_UP,
+  ROUND_FLOOR,
+  ROUND_CEILING,
+};
+
+// Divides n by d (non-zero) with the given rounding mode, see decimal.divRound
+static __int128 div_round(__int128 n, __int128 d, int mode) {
+  __int128 q = n / d;
+  __int128 r = n % d;
+  if (r == 0) {
+    return q;
+  }
+  int away = (n < 0) != (d < 0) ? -1 : 1;
+  __int128 half = (r < 0 ? -r : r) * 2;
+  __int128 abs_d = d < 0 ? -d : d;
+  int round = 0;
+  switch (mode) {
+  case ROUND_HALF_EVEN:
+    round = half > abs_d || (half == abs_d && (q & 1) != 0);
+    break;
+  case ROUND_HALF_UP:
+    round = half >= abs_d;
+    break;
+  case ROUND_DOWN:
+    break;
+  case ROUND_UP:
+    round = 1;
+    break;
+  case ROUND_FLOOR:
+    round = away < 0;
+    break;
+  case ROUND_CEILING:
+    round = away > 0;
+    break;
+  }
+  return round ? q + away : q;
+}
+
+// Clamps out of range decimals, which CHECK_OVERFLOW reports unless lenient
+static jit_long dec_clamp(__int128 value) {
+  if (value > INT64_MAX) {
+    return INT64_MAX;
+  }
+  if (value < INT64_MIN) {
+    return INT64_MIN;
+  }
+  return (jit_long)value;
+}
+
+static int dec_out_of_range(__int128 value) {
+  return value > INT64_MAX || value < INT64_MIN;
+}
+
+static __int128 dec_product(jit_long a, jit_long b) {
+  return div_round((__int128)a * b, DECIMAL_SCALE, ROUND_HALF_EVEN);
+}
+
+jit_long gruel_dec_mul(jit_long a, jit_long b) {
+  return dec_clamp(dec_product(a, b));
+}
+
+jit_long gruel_dec_mul_overflows(jit_long a, jit_long b) {
+  return dec_out_of_range(dec_product(a, b));
+}
+
+jit_long gruel_dec_div(jit_long a, jit_long b) {
+  if (b == 0) {
+    return 0;
+  }
+  return (jit_long)div_round((__int128)a * DECIMAL_SCALE, b, ROUND_HALF_EVEN);
+}
+
+jit_long gruel_dec_from_int(jit_long i) {
+  return dec_clamp((__int128)i * DECIMAL_SCALE);
+}
+
+jit_long gruel_dec_from_int_overflows(jit_long i) {
+  return dec_out_of_range((__int128)i * DECIMAL_SCALE);
+}
+
+// Converting NaN or values out of range into integers is undefined in C.
+jit_long gruel_dec_from_float(jit_float64 f) {
+  jit_float64 scaled = rint(f * DECIMAL_SCALE);
+  if (isnan(scaled)) {
+    return 0;
+  }
+  if (scaled >= 0x1p63) {
+    return INT64_MAX;
+  }
+  if (scaled < -0x1p63) {
+    return INT64_MIN;
+  }
+  return (jit_long)scaled;
+}
+
+jit_long gruel_dec_from_float_overflows(jit_float64 f) {
+  jit_float64 scaled = rint(f * DECIMAL_SCALE);
+  return isnan(scaled) || scaled >= 0x1p63 || scaled < -0x1p63;
+}
+
+static jit_long dec_round(jit_long value, jit_long places, int mode) {
+  if (places >= DECIMAL_DIGITS) {
+    return value;
+  }
+  if (DECIMAL_DIGITS - places > 18) {
+    return 0;
+  }
+  jit_long factor = 1;
+  for (jit_long i = places; i < DECIMAL_DIGITS; i++) {
+    factor *= 10;
+  }
+  return (jit_long)(div_round(value, factor, mode) * factor);
+}
+
+jit_long gruel_dec_round_half_even(jit_long value, jit_long places) {
+  return dec_round(value, places, ROUND_HALF_EVEN);
+}
+
+jit_long gruel_dec_round_half_up(jit_long value, jit_long places) {
+  return dec_round(value, places, ROUND_HALF_UP);
+}
+
+jit_long gruel_dec_round_down(jit_long value, jit_long places) {
+  return dec_round(value, places, ROUND_DOWN);
+}
+
+jit_long gruel_dec_round_up(jit_long value, jit_long places) {
+  return dec_round(value, places, ROUND_UP);
+}
+
+jit_long gruel_dec_round_floor(jit_long value, jit_long places) {
+  return dec_round(value, places, ROUND_FLOOR);
+}
+
+jit_long gruel_dec_round_ceiling(jit_long value, jit_long places) {
+  return dec_round(value, places, ROUND_CEILING);
+}
+
+jit_long gruel_dec_to_int(jit_long value) { return value / DECIMAL_SCALE; }
+
+jit_float64 gruel_dec_to_float(jit_long value) {
+  return (jit_float64)value / DECIMAL_SCALE;
+}
+
+// Characters available in a scratch buffer after its go_string header,
+// see ir.scratchWords
+#define SCRATCH_CHARS 32
+
+static char *scratch_chars(void *scratch) {
+  return (char *)((go_string *)scratch + 1);
+}
+
+static void *scratch_string(void *scratch, int len) {
+  go_string *s = (go_string *)scratch;
+  s->ptr = (jit_long)scratch_chars(scratch);
+  s->len = len;
+  return s;
+}
+
+void *gruel_format_int(void *scratch, jit_long value) {
+  return scratch_string(scratch, snprintf(scratch_chars(scratch), SCRATCH_CHARS,
+                                          "%lld", (long long)value));
+}
+
+void *gruel_format_uint(void *scratch, jit_ulong value) {
+  return scratch_string(scratch,
+                        snprintf(scratch_chars(scratch), SCRATCH_CHARS, "%llu",
+                                 (unsigned long long)value));
+}
+
+void *gruel_format_bool(void *scratch, jit_long value) {
+  return scratch_string(scratch, snprintf(scratch_chars(scratch), SCRATCH_CHARS,
+                                          "%s", value ? "true" : "false"));
+}
+
+// Formats like decimal.Decimal.String, without trailing zeros
+void *gruel_format_decimal(void *scratch, jit_long value) {
+  char *buf = scratch_chars(scratch);
+  jit_ulong units = value < 0 ? -(jit_ulong)value : (jit_ulong)value;
+  jit_ulong fraction = units % DECIMAL_SCALE;
+  int n = snprintf(buf, SCRATCH_CHARS, "%s%llu", value < 0 ? "-" : "",
+                   (unsigned long long)(units / DECIMAL_SCALE));
+  if (fraction != 0) {
+    int digits = DECIMAL_DIGITS;
+    for (; fraction % 10 == 0; fraction /= 10) {
+      digits--;
+    }
+    n += snprintf(buf + n, SCRATCH_CHARS - n, ".%0*llu", digits,
+                  (unsigned long long)fraction);
+  }
+  return scratch_string(scratch, n);
+}
+
+// Formats like strconv.FormatFloat(value, 'g', -1, bits)
+static void *format_float(void *scratch, jit_float64 value, int bits) {
+  char *buf = scratch_chars(scratch);
+  if (isnan(value)) {
+    return scratch_string(scratch, snprintf(buf, SCRATCH_CHARS, "NaN"));
+  }
+  if (isinf(value)) {
+    return scratch_string(
+        scratch, snprintf(buf, SCRATCH_CHARS, value > 0 ? "+Inf" : "-Inf"));
+  }
+
+  // The shortest digits that read back into the same value
+  char repr[SCRATCH_CHARS];
+  for (int precision = 1; precision <= 17; precision++) {
+    snprintf(repr, sizeof(repr), "%.*e", precision - 1, value);
+    jit_float64 parsed = strtod(repr, NULL);
+    if (bits == 32 ? (jit_float32)parsed == (jit_float32)value
+                   : parsed == value) {
+      break;
+    }
+  }
+
+  int n = 0;
+  char *p = repr;
+  if (*p == '-') {
+    buf[n++] = '-';
+    p++;
+  }
+  char digits[SCRATCH_CHARS];
+  int nd = 0;
+  for (; *p != 'e'; p++) {
+    if (*p != '.') {
+      digits[nd++] = *p;
+    }
+  }
+  int exp = atoi(p + 1);
+  int dp = exp + 1;
+
+  // Like Go, %e is used for exponents < -4 or >= 6
+  if (exp < -4 || exp >= 6) {
+    buf[n++] = digits[0];
+    if (nd > 1) {
+      buf[n++] = '.';
+      memcpy(buf + n, digits + 1, nd - 1);
+      n += nd - 1;
+    }
+    n += snprintf(buf + n, SCRATCH_CHARS - n, "e%c%02d", exp < 0 ? '-' : '+',
+                  exp < 0 ? -exp : exp);
+    return scratch_string(scratch, n);
+  }
+  if (dp <= 0) {
+    buf[n++] = '0';
+  }
+  for (int i = 0; i < dp; i++) {
+    buf[n++] = i < nd ? digits[i] : '0';
+  }
+  if (nd > dp) {
+    buf[n++] = '.';
+    for (int i = dp; i < nd; i++) {
+      buf[n++] = i < 0 ? '0' : digits[i];
+    }
+  }
+  return scratch_string(scratch, n);
+}
+
+void *gruel_format_float(void *scratch, jit_float64 value) {
+  return format_float(scratch, value, 64);
+}
+
+void *gruel_format_float32(void *scratch, jit_float32 value) {
+  return format_float(scratch, value, 32);
+}
+
 jit_value_t gruel_insn_eq(jit_function_t func, jit_value_t lhs,
                           jit_value_t rhs) {
   if (jit_value_get_type(lhs) == jit_type_void_ptr &&
@@ -70,6 +428,234 @@ jit_value_t gruel_insn_ne(jit_function_t func, jit_value_t lhs,
   return jit_insn_to_not_bool(func, eq);
 }
 
+// Shifts uint64 values logically, like Go does
+jit_value_t gruel_insn_shr(jit_function_t func, jit_value_t value,
+                           jit_value_t count) {
+  if (jit_value_get_type(value) == jit_type_ulong) {
+    count = jit_insn_convert(func, count, jit_type_ulong, 0);
+  }
+  return jit_insn_shr(func, value, count);
+}
+
+// Shifts values logically at their own widths, like Go does with unsigned values
+//
+// LibJIT promotes both operands to a common type, which would sign-extend
+// small integers shifted by 64-bit counts, so they are zero-extended first.
+jit_value_t gruel_insn_ushr(jit_function_t func, jit_value_t value,
+                            jit_value_t count) {
+  jit_type_t type = jit_value_get_type(value);
+  jit_type_t unsigned_type;
+  switch (jit_type_get_size(type)) {
+  case 1:
+    unsigned_type = jit_type_ubyte;
+    break;
+  case 2:
+    unsigned_type = jit_type_ushort;
+    break;
+  case 4:
+    unsigned_type = jit_type_uint;
+    break;
+  default:
+    unsigned_type = jit_type_ulong;
+    count = jit_insn_convert(func, count, jit_type_ulong, 0);
+    break;
+  }
+  value = jit_insn_convert(func, value, unsigned_type, 0);
+  return jit_insn_convert(func, jit_insn_ushr(func, value, count), type, 0);
+}
+
+// LibJIT types of the sized numeric types, NULL for others
+static jit_type_t sized_type(int type) {
+  switch (type) {
+  case GTYPE_INT8:
+    return jit_type_sbyte;
+  case GTYPE_INT16:
+    return jit_type_short;
+  case GTYPE_INT32:
+    return jit_type_int;
+  case GTYPE_UINT8:
+    return jit_type_ubyte;
+  case GTYPE_UINT16:
+    return jit_type_ushort;
+  case GTYPE_UINT32:
+    return jit_type_uint;
+  case GTYPE_UINT64:
+    return jit_type_ulong;
+  case GTYPE_FLOAT32:
+    return jit_type_float32;
+  default:
+    return NULL;
+  }
+}
+
+// Reports a fault through the error slot (the first scratch word)
+// and returns early if the condition holds
+static void fault_if(jit_function_t function, jit_value_t params,
+                     jit_long argc, jit_value_t condition, jit_long fault) {
+  jit_label_t ok = jit_label_undefined;
+  jit_insn_branch_if_not(function, condition, &ok);
+  jit_insn_store_relative(
+      function, params, argc * 8,
+      jit_value_create_long_constant(function, jit_type_long, fault));
+  jit_insn_return(function,
+                  jit_value_create_long_constant(function, jit_type_long, 0));
+  jit_insn_label(function, &ok);
+}
+
+// Checks the operands of an operator before applying it
+// (the first operand on the top of the stack)
+static void check_operands(jit_function_t function, jit_value_t params,
+                           jit_long argc, int check, jit_long fault,
+                           jit_value_t arg0, jit_value_t arg1) {
+  switch (check) {
+  case CHECK_DIVISOR:
+    fault_if(function, params, argc,
+             jit_insn_eq(function, arg1,
+                         jit_value_create_long_constant(function,
+                                                        jit_type_long, 0)),
+             fault);
+    break;
+  case CHECK_SHIFT: {
+    // Values are shifted at their own widths, see gruel_insn_ushr.
+    jit_ulong width = jit_type_get_size(jit_value_get_type(arg0)) * 8;
+    jit_value_t count = jit_insn_convert(function, arg1, jit_type_ulong, 0);
+    fault_if(function, params, argc,
+             jit_insn_ge(function, count,
+                         jit_value_create_long_constant(
+                             function, jit_type_ulong, (jit_long)width)),
+             fault);
+    break;
+  }
+  }
+}
+
+// Reports decimal operators whose results would be out of range,
+// see ir.overflowOpcodes (the first operand on the top of the stack)
+static int check_overflow(jit_function_t function, jit_value_t params,
+                          jit_long argc, jit_long opcode, jit_long fault,
+                          jit_value_t arg0, jit_value_t arg1) {
+  jit_intrinsic_descr_t descr = {jit_type_long, NULL, jit_type_long,
+                                 jit_type_long};
+  void *func;
+  switch (opcode) {
+  case OPCODE_DEC_MUL:
+    func = (void *)&gruel_dec_mul_overflows;
+    break;
+  case OPCODE_DEC_FROM_INT:
+    func = (void *)&gruel_dec_from_int_overflows;
+    arg1 = jit_value_create_long_constant(function, jit_type_long, 0);
+    break;
+  case OPCODE_DEC_FROM_FLOAT:
+    func = (void *)&gruel_dec_from_float_overflows;
+    descr.arg1_type = jit_type_float64;
+    arg1 = jit_value_create_long_constant(function, jit_type_long, 0);
+    break;
+  default:
+    return 0;
+  }
+  jit_value_t overflows =
+      jit_insn_call_intrinsic(function, NULL, func, &descr, arg0, arg1);
+  fault_if(function, params, argc, jit_insn_to_bool(function, overflows),
+           fault);
+  return 1;
+}
+
+// LibJIT types of values of the given type
+static jit_type_t value_type(int type) {
+  switch (type) {
+  case GTYPE_FLOAT:
+    return jit_type_float64;
+  case GTYPE_STRING:
+    return jit_type_void_ptr;
+  default:
+    if (sized_type(type) != NULL) {
+      return sized_type(type);
+    }
+    return jit_type_long;
+  }
+}
+
+// Calls a host function through the Go trampoline gruelCallHost
+//
+// The frame holds the function handle, the call context (copied from
+// the second scratch word), the status, the result and the arguments.
+static jit_value_t call_host(jit_function_t function, jit_value_t params,
+                             jit_long argc, jit_value_t frame,
+                             jit_value_t handle, jit_value_t *args, int n,
+                             int result, jit_long fault) {
+  jit_insn_store_relative(function, frame, 0, handle);
+  jit_insn_store_relative(
+      function, frame, 8,
+      jit_insn_load_relative(function, params, argc * 8 + 8, jit_type_long));
+  // Frames are reused across calls.
+  jit_insn_store_relative(
+      function, frame, 16,
+      jit_value_create_long_constant(function, jit_type_long, 0));
+  for (int i = 0; i < n; i++) {
+    jit_insn_store_relative(function, frame, (HOST_FRAME_WORDS + i) * 8,
+                            args[i]);
+  }
+  jit_type_t param = jit_type_void_ptr;
+  jit_type_t signature =
+      jit_type_create_signature(jit_abi_cdecl, jit_type_void, &param, 1, 1);
+  jit_insn_call_native(function, "gruelCallHost", (void *)&gruelCallHost,
+                       signature, &frame, 1, JIT_CALL_NOTHROW);
+  fault_if(function, params, argc,
+           jit_insn_load_relative(function, frame, 16, jit_type_long), fault);
+  return jit_insn_load_relative(function, frame, 24, value_type(result));
+}
+
+// Native functions registered at runtime, indexed by opcode - FIRST_INTRINSIC
+static struct {
+  void *func;
+  jit_type_t signature;
+  int argc;
+} intrinsics[MAX_INTRINSICS];
+
+// Registers an intrinsic, with argument and result types from enum Type
+//
+// Registration is serialized by ir.RegisterIntrinsic, and happens before
+// any code referring to the opcode gets compiled.
+jit_int register_intrinsic(jit_long opcode, void *func, jit_long argc,
+                           char *types, jit_int result) {
+  jit_long index = opcode - FIRST_INTRINSIC;
+  if (index < 0 || index >= MAX_INTRINSICS || argc > MAX_INTRINSIC_ARGS ||
+      intrinsics[index].func != NULL) {
+    return 0;
+  }
+  jit_type_t params[MAX_INTRINSIC_ARGS];
+  for (int i = 0; i < argc; i++) {
+    params[i] = value_type(types[i]);
+  }
+  jit_type_t signature = jit_type_create_signature(
+      jit_abi_cdecl, value_type(result), params, argc, 1);
+  if (signature == NULL) {
+    return 0;
+  }
+  intrinsics[index].signature = signature;
+  intrinsics[index].argc = argc;
+  intrinsics[index].func = func;
+  return 1;
+}
+
+// Calls a registered intrinsic with arguments on the stack, see compile_opcodes
+static jit_value_t call_intrinsic(jit_function_t function, jit_long opcode,
+                                  jit_long *stack, int sp) {
+  jit_long index = opcode - FIRST_INTRINSIC;
+  if (index < 0 || index >= MAX_INTRINSICS ||
+      intrinsics[index].func == NULL || sp < intrinsics[index].argc) {
+    return NULL;
+  }
+  // The first argument lies on the top.
+  jit_value_t args[MAX_INTRINSIC_ARGS];
+  for (int i = 0; i < intrinsics[index].argc; i++) {
+    args[i] = (jit_value_t)stack[sp - 1 - i];
+  }
+  return jit_insn_call_native(function, NULL, intrinsics[index].func,
+                              intrinsics[index].signature, args,
+                              intrinsics[index].argc, JIT_CALL_NOTHROW);
+}
+
 jit_long call_jit_function(jit_long function, jit_long args) {
   if (function == 0) {
     return 0;
@@ -80,6 +666,12 @@ jit_long call_jit_function(jit_long function, jit_long args) {
   return ((jit_long(*)(jit_long *))entry)(parameters);
 }
 
+// Called through cgo instead of internal/caller,
+// so that host functions may call back into Go
+jit_long call_jit_function_cgo(jit_long function, jit_long *args) {
+  return call_jit_function(function, (jit_long)args);
+}
+
 #define BINARY_OP(opcode, func)                                                \
   case (opcode):                                                               \
     if (sp < 2) {                                                              \
@@ -146,6 +738,59 @@ jit_long call_jit_function(jit_long function, jit_long args) {
         (jit_value_t)code[sp - 1]);                                            \
     break
 
+#define TIME_OP(opcode, func)                                                  \
+  case (opcode):                                                               \
+    if (sp < 2 ||                                                              \
+        jit_value_get_type((jit_value_t)code[sp - 1]) != jit_type_void_ptr) {  \
+      jit_context_destroy(context);                                            \
+      return 0;                                                                \
+    }                                                                          \
+    sp--;                                                                      \
+    jit_intrinsic_descr_t sig_##func = {jit_type_long, NULL, jit_type_long,    \
+                                        jit_type_void_ptr};                    \
+    code[sp - 1] = (jit_long)jit_insn_call_intrinsic(                          \
+        function, NULL, (void *)&func, &sig_##func, (jit_value_t)code[sp - 1], \
+        (jit_value_t)code[sp]);                                                \
+    break
+
+#define NATIVE1_OP(opcode, func, ret_type, type1)                              \
+  case (opcode):                                                               \
+    if (sp < 1) {                                                              \
+      jit_context_destroy(context);                                            \
+      return 0;                                                                \
+    }                                                                          \
+    jit_intrinsic_descr_t sig_##func = {jit_type_##ret_type, NULL,             \
+                                        jit_type_##type1, jit_type_long};      \
+    code[sp - 1] = (jit_long)jit_insn_call_intrinsic(                          \
+        function, NULL, (void *)&func, &sig_##func, (jit_value_t)code[sp - 1], \
+        jit_value_create_long_constant(function, jit_type_long, 0));           \
+    /* See UNSTRING_OP for the placeholding constant. */                       \
+    break
+
+#define NATIVE2_OP(opcode, func, ret_type, type1, type2)                       \
+  case (opcode):                                                               \
+    if (sp < 2) {                                                              \
+      jit_context_destroy(context);                                            \
+      return 0;                                                                \
+    }                                                                          \
+    sp--;                                                                      \
+    jit_intrinsic_descr_t sig_##func = {jit_type_##ret_type, NULL,             \
+                                        jit_type_##type1, jit_type_##type2};   \
+    code[sp - 1] = (jit_long)jit_insn_call_intrinsic(                          \
+        function, NULL, (void *)&func, &sig_##func, (jit_value_t)code[sp],     \
+        (jit_value_t)code[sp - 1]);                                            \
+    break
+
+#define CONVERT_OP(opcode, type)                                               \
+  case (opcode):                                                               \
+    if (sp < 1) {                                                              \
+      jit_context_destroy(context);                                            \
+      return 0;                                                                \
+    }                                                                          \
+    code[sp - 1] = (jit_long)jit_insn_convert(                                 \
+        function, (jit_value_t)code[sp - 1], jit_type_##type, 0);              \
+    break
+
 jit_long compile_opcodes(jit_long length, jit_long *code, jit_long argc,
                          char *argv) {
   jit_context_t context = jit_context_create();
@@ -171,6 +816,26 @@ jit_long compile_opcodes(jit_long length, jit_long *code, jit_long argc,
     int type = code[pc] & 0xff;
     jit_long value = code[pc + 1];
     if (type == GTYPE_PARENTHESIS) {
+      // See ir.IrBuilder.emitOperator for the encoding.
+      int check = (code[pc] >> 8) & 0xff;
+      jit_long fault = ((jit_ulong)code[pc] >> 32) << 8 | check;
+      if (check == CHECK_DIVISOR || check == CHECK_SHIFT) {
+        if (sp < 2) {
+          jit_context_destroy(context);
+          return 0;
+        }
+        check_operands(function, paramBase, argc, check, fault,
+                       (jit_value_t)code[sp - 1], (jit_value_t)code[sp - 2]);
+      }
+      if (check == CHECK_OVERFLOW &&
+          (sp < (value == OPCODE_DEC_MUL ? 2 : 1) ||
+           !check_overflow(function, paramBase, argc, value, fault,
+                           (jit_value_t)code[sp - 1],
+                           value == OPCODE_DEC_MUL ? (jit_value_t)code[sp - 2]
+                                                   : NULL))) {
+        jit_context_destroy(context);
+        return 0;
+      }
       switch (value) {
         //@start maintained by operators.go
         // `+`(2)
@@ -196,9 +861,9 @@ jit_long compile_opcodes(jit_long length, jit_long *code, jit_long argc,
         // `<<`(2)
         BINARY_OP(0x0b, jit_insn_shl);
         // `>>`(2)
-        BINARY_OP(0x0c, jit_insn_shr);
+        BINARY_OP(0x0c, gruel_insn_shr);
         // `>>>`(2)
-        BINARY_OP(0x0d, jit_insn_ushr);
+        BINARY_OP(0x0d, gruel_insn_ushr);
         // `&&`(2)
         LOGIC_OP (0x20, jit_insn_and);
         // `||`(2)
@@ -285,26 +950,128 @@ jit_long compile_opcodes(jit_long length, jit_long *code, jit_long argc,
         UNSTRING_OP(0x80, gruel_strlen, long);
         // `index`(2)
         BISTRING_OP(0x81, gruel_index_of, long, void_ptr);
+        // `hour`(1)
+        TIME_OP  (0x90, gruel_hour);
+        // `minute`(1)
+        TIME_OP  (0x91, gruel_minute);
+        // `weekday`(1)
+        TIME_OP  (0x92, gruel_weekday);
+        // `day-of-month`(1)
+        TIME_OP  (0x93, gruel_day_of_month);
+        // `month`(1)
+        TIME_OP  (0x94, gruel_month);
+        // `year`(1)
+        TIME_OP  (0x95, gruel_year);
+        // `*`(2)
+        NATIVE2_OP(0xa0, gruel_dec_mul, long, long, long);
+        // `/`(2)
+        NATIVE2_OP(0xa1, gruel_dec_div, long, long, long);
+        // `->decimal`(1)
+        NATIVE1_OP(0xa2, gruel_dec_from_int, long, long);
+        // `->decimal`(1)
+        NATIVE1_OP(0xa3, gruel_dec_from_float, long, float64);
+        // `round-half-even`(2)
+        NATIVE2_OP(0xa4, gruel_dec_round_half_even, long, long, long);
+        // `round-half-up`(2)
+        NATIVE2_OP(0xa5, gruel_dec_round_half_up, long, long, long);
+        // `round-down`(2)
+        NATIVE2_OP(0xa6, gruel_dec_round_down, long, long, long);
+        // `round-up`(2)
+        NATIVE2_OP(0xa7, gruel_dec_round_up, long, long, long);
+        // `round-floor`(2)
+        NATIVE2_OP(0xa8, gruel_dec_round_floor, long, long, long);
+        // `round-ceiling`(2)
+        NATIVE2_OP(0xa9, gruel_dec_round_ceiling, long, long, long);
+        // `->int`(1)
+        CONVERT_OP(0xb0, long);
+        // `->int`(1)
+        NATIVE1_OP(0xb1, gruel_dec_to_int, long, long);
+        // `->uint`(1)
+        CONVERT_OP(0xb2, ulong);
+        // `->float`(1)
+        CONVERT_OP(0xb3, float64);
+        // `->float`(1)
+        NATIVE1_OP(0xb4, gruel_dec_to_float, float64, long);
+        // `->int8`(1)
+        CONVERT_OP(0xb5, sbyte);
+        // `->int16`(1)
+        CONVERT_OP(0xb6, short);
+        // `->int32`(1)
+        CONVERT_OP(0xb7, int);
+        // `->int64`(1)
+        CONVERT_OP(0xb8, long);
+        // `->uint8`(1)
+        CONVERT_OP(0xb9, ubyte);
+        // `->uint16`(1)
+        CONVERT_OP(0xba, ushort);
+        // `->uint32`(1)
+        CONVERT_OP(0xbb, uint);
+        // `->uint64`(1)
+        CONVERT_OP(0xbc, ulong);
+        // `->float32`(1)
+        CONVERT_OP(0xbd, float32);
+        // `->float64`(1)
+        CONVERT_OP(0xbe, float64);
+        // `->string`(1)
+        NATIVE2_OP(0xc0, gruel_format_int, void_ptr, void_ptr, long);
+        // `->string`(1)
+        NATIVE2_OP(0xc1, gruel_format_uint, void_ptr, void_ptr, ulong);
+        // `->string`(1)
+        NATIVE2_OP(0xc2, gruel_format_float, void_ptr, void_ptr, float64);
+        // `->string`(1)
+        NATIVE2_OP(0xc3, gruel_format_float32, void_ptr, void_ptr, float32);
+        // `->string`(1)
+        NATIVE2_OP(0xc4, gruel_format_bool, void_ptr, void_ptr, long);
+        // `->string`(1)
+        NATIVE2_OP(0xc5, gruel_format_decimal, void_ptr, void_ptr, long);
+        // `->string`(1)
+        CONVERT_OP(0xc6, void_ptr);
         //@end maintained by operators.go
-      default:
+      default: {
+        jit_value_t ret = call_intrinsic(function, value, code, sp);
+        if (ret == NULL) {
+          jit_context_destroy(context);
+          return 0;
+        }
+        sp -= intrinsics[value - FIRST_INTRINSIC].argc - 1;
+        code[sp - 1] = (jit_long)ret;
+        break;
+      }
+      }
+      if (check == CHECK_NAN) {
+        fault_if(function, paramBase, argc,
+                 jit_insn_is_nan(function, (jit_value_t)code[sp - 1]), fault);
+      }
+    } else if (type == GTYPE_SYMBOL) {
+      code[sp] = (jit_long)jit_insn_load_relative(
+          function, paramBase, value * 8, value_type(argv[value]));
+      sp++;
+    } else if (type == GTYPE_HOST) {
+      // See ir.IrBuilder.pushHost for the encoding.
+      int n = (code[pc] >> 8) & 0xff;
+      int result = (code[pc] >> 16) & 0xff;
+      jit_long fault = ((jit_ulong)code[pc] >> 32) << 8 | CHECK_HOST;
+      if (sp < n + 1) {
         jit_context_destroy(context);
         return 0;
       }
-    } else if (type == GTYPE_SYMBOL) {
-      jit_type_t ptrType;
-      switch (argv[value]) {
-      case GTYPE_FLOAT:
-        ptrType = jit_type_float64;
-        break;
-      case GTYPE_STRING:
-        ptrType = jit_type_void_ptr;
-        break;
-      default:
-        ptrType = jit_type_long;
-        break;
+      // The first argument lies right below the handle.
+      jit_value_t args[256];
+      for (int i = 0; i < n; i++) {
+        args[i] = (jit_value_t)code[sp - 2 - i];
       }
-      code[sp] = (jit_long)jit_insn_load_relative(function, paramBase,
-                                                  value * 8, ptrType);
+      jit_value_t frame =
+          jit_insn_add_relative(function, paramBase, argc * 8 + value);
+      jit_value_t ret =
+          call_host(function, paramBase, argc, frame,
+                    (jit_value_t)code[sp - 1], args, n, result, fault);
+      sp -= n + 1;
+      code[sp] = (jit_long)ret;
+      sp++;
+    } else if (type == GTYPE_SCRATCH) {
+      // Scratch buffers follow the parameters.
+      code[sp] = (jit_long)jit_insn_add_relative(function, paramBase,
+                                                 argc * 8 + value);
       sp++;
     } else {
       jit_constant_t c;
@@ -316,16 +1083,29 @@ jit_long compile_opcodes(jit_long length, jit_long *code, jit_long argc,
         c.type = jit_type_float64;
         break;
       case GTYPE_INT:
+      case GTYPE_TIME:
+      case GTYPE_DURATION:
+      case GTYPE_DECIMAL:
         c.type = jit_type_long;
         break;
       case GTYPE_STRING:
         c.type = jit_type_void_ptr;
         break;
       default:
-        jit_context_destroy(context);
-        return 0;
+        c.type = sized_type(type);
+        if (c.type == NULL) {
+          jit_context_destroy(context);
+          return 0;
+        }
+        break;
       }
       c.un.long_value = value;
+      if (type == GTYPE_FLOAT32) {
+        // Adapted float constants are still stored as float64.
+        jit_float64 f;
+        memcpy(&f, &value, sizeof(f));
+        c.un.float32_value = (jit_float32)f;
+      }
       code[sp] = (jit_long)jit_value_create_constant(function, &c);
       sp++;
     }
@@ -338,6 +1118,9 @@ jit_long compile_opcodes(jit_long length, jit_long *code, jit_long argc,
   }
 
   jit_value_t ret = (jit_value_t)code[sp - 1];
+  if (jit_value_get_type(ret) == jit_type_float32) {
+    ret = jit_insn_convert(function, ret, jit_type_float64, 0);
+  }
 
   // Stores float64 in long.
   if (jit_value_get_type(ret) == jit_type_float64) {
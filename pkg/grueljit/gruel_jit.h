@@ -10,8 +10,50 @@ enum Type {
   GTYPE_FLOAT,
   GTYPE_STRING,
   GTYPE_SYMBOL,
+  GTYPE_TIME,
+  GTYPE_DURATION,
+  GTYPE_DECIMAL,
+  GTYPE_INT8,
+  GTYPE_INT16,
+  GTYPE_INT32,
+  GTYPE_UINT8,
+  GTYPE_UINT16,
+  GTYPE_UINT32,
+  GTYPE_UINT64,
+  GTYPE_FLOAT32,
+  // Only used in the IR: a call to a host function
+  GTYPE_HOST = 0x7e,
+  // Only used in the IR: the address of a per-call scratch buffer
+  GTYPE_SCRATCH = 0x7f,
 };
 
+// Runtime checks of operators, see ir.Check*
+enum Check {
+  CHECK_NONE = 0,
+  CHECK_DIVISOR,
+  CHECK_SHIFT,
+  CHECK_NAN,
+  CHECK_HOST,
+  CHECK_OVERFLOW,
+};
+
+// Decimal operators checked with CHECK_OVERFLOW, see ir.overflowOpcodes
+#define OPCODE_DEC_MUL 0xa0
+#define OPCODE_DEC_FROM_INT 0xa2
+#define OPCODE_DEC_FROM_FLOAT 0xa3
+
+// Words of a host call frame before the arguments, see ir.hostFrameWords
+#define HOST_FRAME_WORDS 4
+
+// Opcodes of intrinsics registered at runtime, see ir.FirstIntrinsic
+#define FIRST_INTRINSIC 0x100
+#define MAX_INTRINSICS 0x100
+#define MAX_INTRINSIC_ARGS 8
+
+// Digits after the decimal point of decimals, see decimal.Digits
+#define DECIMAL_DIGITS 6
+#define DECIMAL_SCALE 1000000
+
 typedef struct {
   jit_long ptr;
   jit_long len;
@@ -22,5 +64,8 @@ jit_long compile_opcodes(jit_long length, jit_long *code, jit_long argc,
                          char *argv);
 void free_function(jit_long func);
 jit_long call_jit_function(jit_long function, jit_long args);
+jit_long call_jit_function_cgo(jit_long function, jit_long *args);
+jit_int register_intrinsic(jit_long opcode, void *func, jit_long argc,
+                           char *types, jit_int result);
 
 #endif /* !GRUEL_JIT_H */
\ No newline at end of file
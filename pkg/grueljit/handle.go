@@ -0,0 +1,93 @@
+package grueljit
+
+import "sync/atomic"
+
+// A reference to the current version of a rule, swappable while in use
+//
+// Calls through the handle use whatever version is current when they start.
+// Replaced versions are freed once their in-flight calls finish.
+type Handle struct {
+	function atomic.Pointer[Function]
+}
+
+// Creates a handle owning the function
+func NewHandle(f *Function) *Handle {
+	h := &Handle{}
+	h.function.Store(f)
+	return h
+}
+
+// Compiles a new version and swaps it in
+func (h *Handle) Recompile(code string, symbols map[string]byte, opts ...Option) error {
+	f, err := Compile(code, symbols, opts...)
+	if err != nil {
+		return err
+	}
+	h.Swap(f)
+	return nil
+}
+
+// Replaces the current version, which gets freed after in-flight calls
+//
+// Swapping in the current version again does nothing.
+func (h *Handle) Swap(f *Function) {
+	if old := h.function.Swap(f); old != nil && old != f {
+		old.Free()
+	}
+}
+
+// The current version, which may get freed by a swap at any time
+func (h *Handle) Function() *Function {
+	return h.function.Load()
+}
+
+// Calls the current version, see Function.Call
+func (h *Handle) Call(args map[string]any) (any, error) {
+	for {
+		f := h.function.Load()
+		if f == nil {
+			return nil, ErrFreed
+		}
+		v, err := f.Call(args)
+		// Retry if swapped out and freed before the call started.
+		if err != ErrFreed || h.function.Load() == f {
+			return v, err
+		}
+	}
+}
+
+// Calls the current version, see Function.CallRaw
+//
+// Versions may differ in their parameters, so this is only useful
+// if all versions share the same symbols.
+func (h *Handle) CallRaw(params []uint64) (uint64, error) {
+	for {
+		f := h.function.Load()
+		if f == nil {
+			return 0, ErrFreed
+		}
+		v, err := f.CallRaw(params)
+		if err != ErrFreed || h.function.Load() == f {
+			return v, err
+		}
+	}
+}
+
+// Calls the current version, see Function.CallRawFunc
+func (h *Handle) CallRawFunc(params []uint64, use func(result uint64)) error {
+	for {
+		f := h.function.Load()
+		if f == nil {
+			return ErrFreed
+		}
+		err := f.CallRawFunc(params, use)
+		if err != ErrFreed || h.function.Load() == f {
+			return err
+		}
+	}
+}
+
+// Frees the current version, after which calls fail with ErrFreed
+func (h *Handle) Close() {
+	h.Swap(nil)
+}
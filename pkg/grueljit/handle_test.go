@@ -0,0 +1,101 @@
+package grueljit_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+func TestFree(t *testing.T) {
+	f, err := grueljit.Compile("(+ x 1)", map[string]byte{"x": grueljit.TypeInt})
+	assert.Nil(t, err)
+	f.Free()
+	f.Free()
+	_, err = f.Call(map[string]any{"x": 1})
+	assert.ErrorIs(t, err, grueljit.ErrFreed)
+	_, err = f.CallRaw([]uint64{1})
+	assert.ErrorIs(t, err, grueljit.ErrFreed)
+}
+
+func TestFreeInFlight(t *testing.T) {
+	requireLibJit(t)
+	entered, resume := make(chan struct{}), make(chan struct{})
+	r := grueljit.NewRegistry()
+	assert.Nil(t, r.Register("wait", grueljit.Signature{
+		Args: []byte{grueljit.TypeInt}, Result: grueljit.TypeInt,
+	}, func(i int) int {
+		entered <- struct{}{}
+		<-resume
+		return i
+	}))
+	f, err := grueljit.Compile("(+ (wait x) 1)", map[string]byte{"x": grueljit.TypeInt},
+		grueljit.WithRegistry(r))
+	assert.Nil(t, err)
+
+	result := make(chan any)
+	go func() {
+		v, err := f.Call(map[string]any{"x": 41})
+		assert.Nil(t, err)
+		result <- v
+	}()
+	<-entered
+	// The code stays alive until the call returns.
+	f.Free()
+	close(resume)
+	assert.Equal(t, uint64(42), <-result)
+	_, err = f.Call(map[string]any{"x": 41})
+	assert.ErrorIs(t, err, grueljit.ErrFreed)
+}
+
+func TestHandle(t *testing.T) {
+	symbols := map[string]byte{"x": grueljit.TypeInt}
+	f, err := grueljit.Compile("(+ x 0)", symbols)
+	assert.Nil(t, err)
+	h := grueljit.NewHandle(f)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				v, err := h.Call(map[string]any{"x": 1})
+				assert.Nil(t, err)
+				// Any version may answer, but never a freed one.
+				assert.Contains(t, []any{uint64(1), uint64(2), uint64(3)}, v)
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, h.Recompile([]string{"(+ x 1)", "(+ x 2)"}[i%2], symbols))
+	}
+	wg.Wait()
+
+	assert.NotNil(t, h.Recompile("(+ x", symbols))
+	v, err := h.Call(map[string]any{"x": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), v)
+
+	old := h.Function()
+	h.Close()
+	_, err = h.Call(map[string]any{"x": 1})
+	assert.ErrorIs(t, err, grueljit.ErrFreed)
+	_, err = old.Call(map[string]any{"x": 1})
+	assert.ErrorIs(t, err, grueljit.ErrFreed)
+}
+
+func TestHandleSwapSame(t *testing.T) {
+	f, err := grueljit.Compile("(+ x 1)", map[string]byte{"x": grueljit.TypeInt})
+	assert.Nil(t, err)
+	h := grueljit.NewHandle(f)
+	h.Swap(f)
+	assert.Same(t, f, h.Function())
+	v, err := h.Call(map[string]any{"x": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), v)
+	h.Close()
+	_, err = f.Call(map[string]any{"x": 1})
+	assert.ErrorIs(t, err, grueljit.ErrFreed)
+}
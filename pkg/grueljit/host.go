@@ -0,0 +1,237 @@
+package grueljit
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/internal/ir"
+	"github.com/yesh0/gruel/pkg/decimal"
+)
+
+// Words of a host call frame before the arguments, see ir.hostFrameWords
+const hostFrameWords = 4
+
+// The types of a host function, using the Type* constants
+type Signature struct {
+	Args   []byte
+	Result byte
+}
+
+// A set of host functions
+//
+// Different tenants may use different registries to expose different functions,
+// see WithRegistry.
+type Registry struct {
+	mutex     sync.RWMutex
+	functions map[string]*hostFunction
+}
+
+type hostFunction struct {
+	name      string
+	signature Signature
+	fn        reflect.Value
+	// Whether the function returns an error as its second result
+	fallible bool
+	// Handles are never deleted, since compiled code may refer to them.
+	handle uint64
+}
+
+// States of a call, passed to host functions through the scratch buffer
+type hostContext struct {
+	location *time.Location
+	// Strings returned by host functions and their headers, kept alive
+	// until the result is converted
+	objects []string
+	strings []*ir.GoString
+	err     error
+}
+
+var (
+	defaultRegistry = NewRegistry()
+	timeType        = reflect.TypeOf(time.Time{})
+	decimalType     = reflect.TypeOf(decimal.Decimal(0))
+	errorType       = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+func NewRegistry() *Registry {
+	return &Registry{functions: make(map[string]*hostFunction)}
+}
+
+// Registers a host function into the default registry
+//
+// See Registry.Register for the requirements.
+func RegisterFunction(name string, signature Signature, fn any) error {
+	return defaultRegistry.Register(name, signature, fn)
+}
+
+// Registers a host function
+//
+// The function must accept arguments convertible from the types in the signature,
+// and return a value of the result type, optionally followed by an error.
+// Decimals are passed as decimal.Decimal only, never as plain integers.
+// Errors (and panics) are reported as RuntimeError with FaultHost.
+func (r *Registry) Register(name string, signature Signature, fn any) error {
+	if ir.Reserved(name) {
+		return fmt.Errorf("function name %s is reserved", name)
+	}
+	if len(signature.Args) > math.MaxUint8 {
+		return fmt.Errorf("function %s has too many arguments", name)
+	}
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return fmt.Errorf("function %s is not a function", name)
+	}
+	t := v.Type()
+	if t.IsVariadic() || t.NumIn() != len(signature.Args) {
+		return fmt.Errorf("function %s does not match its signature", name)
+	}
+	for i, arg := range signature.Args {
+		if !convertible(arg, t.In(i)) {
+			return fmt.Errorf("function %s does not accept %s as argument %d", name, t.In(i), i+1)
+		}
+	}
+	fallible := t.NumOut() == 2 && t.Out(1) == errorType
+	if t.NumOut() != 1 && !fallible || !convertible(signature.Result, t.Out(0)) {
+		return fmt.Errorf("function %s does not return its result type", name)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.functions[name]; ok {
+		return fmt.Errorf("function %s already registered", name)
+	}
+	f := &hostFunction{
+		name: name, signature: signature, fn: v, fallible: fallible,
+	}
+	f.handle = newHandle(f)
+	r.functions[name] = f
+	return nil
+}
+
+func (r *Registry) snapshot() map[string]ir.HostFunction {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	functions := make(map[string]ir.HostFunction, len(r.functions))
+	for name, f := range r.functions {
+		args := make([]gruelparser.TokenType, len(f.signature.Args))
+		for i, arg := range f.signature.Args {
+			args[i] = gruelparser.TokenType(arg)
+		}
+		functions[name] = ir.HostFunction{
+			Args:   args,
+			Result: gruelparser.TokenType(f.signature.Result),
+			Handle: f.handle,
+		}
+	}
+	return functions
+}
+
+// Whether values of a rule type convert from or into the Go type
+func convertible(t byte, goType reflect.Type) bool {
+	kind := goType.Kind()
+	switch t {
+	case TypeBool:
+		return kind == reflect.Bool
+	case TypeString:
+		return kind == reflect.String
+	case TypeFloat, TypeFloat32:
+		return kind == reflect.Float32 || kind == reflect.Float64
+	case TypeTime:
+		return goType.ConvertibleTo(timeType) && timeType.ConvertibleTo(goType)
+	case TypeDecimal:
+		// Plain integers would see the scaled words.
+		return goType == decimalType
+	case TypeInt, TypeDuration, TypeInt8, TypeInt16, TypeInt32,
+		TypeUint8, TypeUint16, TypeUint32, TypeUint64:
+		return reflect.Int <= kind && kind <= reflect.Uint64
+	default:
+		return false
+	}
+}
+
+// Decodes a word stored by compiled code
+func (ctx *hostContext) decode(t byte, word uint64) reflect.Value {
+	switch t {
+	case TypeBool:
+		return reflect.ValueOf(uint8(word) != 0)
+	case TypeFloat:
+		return reflect.ValueOf(math.Float64frombits(word))
+	case TypeFloat32:
+		return reflect.ValueOf(math.Float32frombits(uint32(word)))
+	case TypeString:
+		return reflect.ValueOf(goString(word))
+	case TypeTime:
+		return reflect.ValueOf(time.Unix(0, int64(word)).In(ctx.location))
+	case TypeInt8:
+		return reflect.ValueOf(int8(word))
+	case TypeInt16:
+		return reflect.ValueOf(int16(word))
+	case TypeInt32:
+		return reflect.ValueOf(int32(word))
+	case TypeUint8:
+		return reflect.ValueOf(uint8(word))
+	case TypeUint16:
+		return reflect.ValueOf(uint16(word))
+	case TypeUint32:
+		return reflect.ValueOf(uint32(word))
+	case TypeUint64:
+		return reflect.ValueOf(word)
+	case TypeDecimal:
+		return reflect.ValueOf(decimal.Decimal(word))
+	default:
+		return reflect.ValueOf(int64(word))
+	}
+}
+
+// Encodes a result into a word for compiled code
+func (ctx *hostContext) encode(t byte, v reflect.Value) uint64 {
+	switch t {
+	case TypeBool:
+		if v.Bool() {
+			return 1
+		}
+		return 0
+	case TypeFloat:
+		return math.Float64bits(v.Float())
+	case TypeFloat32:
+		return uint64(math.Float32bits(float32(v.Float())))
+	case TypeString:
+		// The header only holds the address of the bytes, which the GC ignores.
+		ctx.objects = append(ctx.objects, v.String())
+		hdr := (*reflect.StringHeader)(unsafe.Pointer(&ctx.objects[len(ctx.objects)-1]))
+		str := &ir.GoString{uint64(hdr.Data), uint64(hdr.Len)}
+		ctx.strings = append(ctx.strings, str)
+		return uint64(uintptr(unsafe.Pointer(&str[0])))
+	case TypeTime:
+		return uint64(v.Convert(timeType).Interface().(time.Time).UnixNano())
+	default:
+		if v.CanInt() {
+			return uint64(v.Int())
+		}
+		return v.Uint()
+	}
+}
+
+func (f *hostFunction) call(ctx *hostContext, args []uint64) (result uint64, err error) {
+	defer func() {
+		// Panics must not unwind through JIT code.
+		if r := recover(); r != nil {
+			err = fmt.Errorf("function %s panicked: %v", f.name, r)
+		}
+	}()
+	t := f.fn.Type()
+	in := make([]reflect.Value, len(args))
+	for i, arg := range f.signature.Args {
+		in[i] = ctx.decode(arg, args[i]).Convert(t.In(i))
+	}
+	out := f.fn.Call(in)
+	if f.fallible && !out[1].IsNil() {
+		return 0, out[1].Interface().(error)
+	}
+	return ctx.encode(f.signature.Result, out[0]), nil
+}
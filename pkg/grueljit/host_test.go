@@ -0,0 +1,170 @@
+package grueljit_test
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+func TestHostFunctions(t *testing.T) {
+	requireLibJit(t)
+	r := grueljit.NewRegistry()
+	assert.Nil(t, r.Register("clamp", grueljit.Signature{
+		Args:   []byte{grueljit.TypeInt, grueljit.TypeInt, grueljit.TypeInt},
+		Result: grueljit.TypeInt,
+	}, func(v, lo, hi int64) int64 {
+		if v < lo {
+			return lo
+		}
+		if v > hi {
+			return hi
+		}
+		return v
+	}))
+	assert.Nil(t, r.Register("upper", grueljit.Signature{
+		Args:   []byte{grueljit.TypeString},
+		Result: grueljit.TypeString,
+	}, strings.ToUpper))
+	assert.Nil(t, r.Register("year", grueljit.Signature{
+		Args:   []byte{grueljit.TypeTime},
+		Result: grueljit.TypeInt,
+	}, func(t time.Time) int { return t.Year() }))
+	assert.Nil(t, r.Register("lookup", grueljit.Signature{
+		Args:   []byte{grueljit.TypeString},
+		Result: grueljit.TypeFloat,
+	}, func(key string) (float64, error) {
+		if key == "pi" {
+			return 3.14, nil
+		}
+		return 0, errors.New("no such key")
+	}))
+
+	assert.Nil(t, r.Register("half", grueljit.Signature{
+		Args:   []byte{grueljit.TypeDecimal},
+		Result: grueljit.TypeDecimal,
+	}, func(d grueljit.Decimal) grueljit.Decimal { return d / 2 }))
+
+	symbols := map[string]byte{"i": grueljit.TypeInt, "s": grueljit.TypeString}
+	call := func(expr string, args map[string]any) (any, error) {
+		f, err := grueljit.Compile(expr, symbols, grueljit.WithRegistry(r))
+		assert.Nil(t, err, expr)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Free()
+		return f.Call(args)
+	}
+
+	v, err := call("(+ (clamp i 0 10) 1)", map[string]any{"i": 42})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(11), v)
+	v, err = call("(upper s)", map[string]any{"s": "gruel"})
+	assert.Nil(t, err)
+	assert.Equal(t, "GRUEL", v)
+	v, err = call("(== (upper s) \"ABC\")", map[string]any{"s": "abc"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), v)
+	v, err = call("(year #t\"2026-03-01T00:00:00Z\")", map[string]any{})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2026), v)
+	v, err = call("(* (lookup \"pi\") 2.)", map[string]any{})
+	assert.Nil(t, err)
+	assert.Equal(t, 6.28, v)
+	v, err = call("(half 3d)", map[string]any{})
+	assert.Nil(t, err)
+	assert.Equal(t, "1.5", v.(grueljit.Decimal).String())
+
+	_, err = call("(* 2.\n  (lookup s))", map[string]any{"s": "e"})
+	var runtimeErr *grueljit.RuntimeError
+	assert.ErrorAs(t, err, &runtimeErr)
+	assert.Equal(t, grueljit.FaultHost, runtimeErr.Fault)
+	assert.Equal(t, 2, runtimeErr.Line)
+	assert.Equal(t, "runtime error at 2:3: host function error in lookup: no such key", err.Error())
+	assert.EqualError(t, errors.Unwrap(err), "no such key")
+
+	// Functions are scoped to their registry.
+	_, err = grueljit.Compile("(clamp i 0 10)", symbols)
+	assert.NotNil(t, err)
+	_, err = grueljit.Compile("(clamp i 0)", symbols, grueljit.WithRegistry(r))
+	assert.NotNil(t, err)
+	_, err = grueljit.Compile("(upper i)", symbols, grueljit.WithRegistry(r))
+	assert.NotNil(t, err)
+}
+
+func TestHostPanics(t *testing.T) {
+	requireLibJit(t)
+	r := grueljit.NewRegistry()
+	assert.Nil(t, r.Register("boom", grueljit.Signature{
+		Args: []byte{grueljit.TypeInt}, Result: grueljit.TypeBool,
+	}, func(i int) bool { panic("boom") }))
+	f, err := grueljit.Compile("(boom 1)", nil, grueljit.WithRegistry(r))
+	assert.Nil(t, err)
+	_, err = f.Call(nil)
+	assert.EqualError(t, err, "runtime error at 1:1: host function error in boom: function boom panicked: boom")
+	f.Free()
+}
+
+func TestRegister(t *testing.T) {
+	r := grueljit.NewRegistry()
+	sig := grueljit.Signature{Args: []byte{grueljit.TypeInt}, Result: grueljit.TypeInt}
+	id := func(i int) int { return i }
+	assert.Nil(t, r.Register("id", sig, id))
+	assert.EqualError(t, r.Register("id", sig, id), "function id already registered")
+	assert.EqualError(t, r.Register("+", sig, id), "function name + is reserved")
+	assert.EqualError(t, r.Register("get", sig, id), "function name get is reserved")
+	assert.EqualError(t, r.Register("f", sig, 1), "function f is not a function")
+	assert.EqualError(t, r.Register("f", sig, func() int { return 0 }), "function f does not match its signature")
+	assert.EqualError(t, r.Register("f", sig, func(s string) int { return 0 }),
+		"function f does not accept string as argument 1")
+	assert.EqualError(t, r.Register("f", sig, func(i int) string { return "" }),
+		"function f does not return its result type")
+	assert.EqualError(t, r.Register("f", sig, func(i int) (int, int) { return 0, 0 }),
+		"function f does not return its result type")
+
+	// Decimals are never passed as their scaled words.
+	dec := grueljit.Signature{Args: []byte{grueljit.TypeDecimal}, Result: grueljit.TypeDecimal}
+	assert.Nil(t, r.Register("half", dec, func(d grueljit.Decimal) grueljit.Decimal { return d / 2 }))
+	assert.EqualError(t, r.Register("f", dec, func(i int64) grueljit.Decimal { return 0 }),
+		"function f does not accept int64 as argument 1")
+	assert.EqualError(t, r.Register("f", dec, func(d grueljit.Decimal) int64 { return 0 }),
+		"function f does not return its result type")
+}
+
+func TestHostStrings(t *testing.T) {
+	requireLibJit(t)
+	r := grueljit.NewRegistry()
+	assert.Nil(t, r.Register("repeat", grueljit.Signature{
+		Args: []byte{grueljit.TypeString}, Result: grueljit.TypeString,
+	}, func(s string) string {
+		// Only the context refers to the new string.
+		s = strings.Repeat(s, 1000)
+		runtime.GC()
+		return s
+	}))
+	f, err := grueljit.Compile("(repeat s)", map[string]byte{"s": grueljit.TypeString}, grueljit.WithRegistry(r))
+	assert.Nil(t, err)
+	defer f.Free()
+	for i := 0; i < 10; i++ {
+		v, err := f.Call(map[string]any{"s": "ab"})
+		assert.Nil(t, err)
+		assert.Equal(t, strings.Repeat("ab", 1000), v)
+	}
+
+	s := "ab"
+	params := make([]uint64, 1+f.ScratchSize())
+	params[0] = uint64(uintptr(unsafe.Pointer(&s)))
+	_, err = f.CallRaw(params)
+	assert.EqualError(t, err, "string results of host functions need CallRawFunc")
+	called := false
+	assert.Nil(t, f.CallRawFunc(params, func(result uint64) {
+		called = result != 0
+	}))
+	assert.True(t, called)
+	runtime.KeepAlive(&s)
+}
@@ -0,0 +1,47 @@
+// This package provides C functions for testing intrinsics.
+package cfuncs
+
+/*
+
+#cgo LDFLAGS: -lm
+#include <math.h>
+#include <stdint.h>
+
+int64_t cfuncs_gcd(int64_t a, int64_t b) {
+  while (b != 0) {
+    int64_t t = a % b;
+    a = b;
+    b = t;
+  }
+  return a < 0 ? -a : a;
+}
+
+double cfuncs_hypot(double x, double y) { return hypot(x, y); }
+
+// Strings are passed as pointers to {data, length}.
+int64_t cfuncs_count_byte(int64_t *s, int8_t c) {
+  int64_t n = 0;
+  for (int64_t i = 0; i < s[1]; i++) {
+    n += ((char *)s[0])[i] == c;
+  }
+  return n;
+}
+
+*/
+import "C"
+import "unsafe"
+
+// int64_t gcd(int64_t, int64_t)
+func Gcd() unsafe.Pointer {
+	return unsafe.Pointer(C.cfuncs_gcd)
+}
+
+// double hypot(double, double)
+func Hypot() unsafe.Pointer {
+	return unsafe.Pointer(C.cfuncs_hypot)
+}
+
+// int64_t count_byte(go_string *, int8_t)
+func CountByte() unsafe.Pointer {
+	return unsafe.Pointer(C.cfuncs_count_byte)
+}
@@ -0,0 +1,46 @@
+package grueljit
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/internal/ir"
+)
+
+// Opcodes available to intrinsics, from FirstIntrinsic to FirstIntrinsic+MaxIntrinsics-1
+const (
+	FirstIntrinsic = ir.FirstIntrinsic
+	MaxIntrinsics  = ir.MaxIntrinsics
+)
+
+// Registers a native function callable from rules like an operator
+//
+// The function must be a C function (or one following the C calling convention)
+// taking and returning values of the LibJIT types of the signature:
+// jit_long for integers, booleans, timestamps, durations and decimals,
+// jit_float64 for floats, and a go_string pointer for strings.
+// Sized types map to their C counterparts, like jit_sbyte for TypeInt8.
+//
+// Unlike host functions, intrinsics are called directly without any checks
+// and are shared by all functions compiled afterwards. Registries may already
+// hold host functions of the same name, which then fail to compile.
+// The opcode, chosen by the caller, identifies the intrinsic in compiled code
+// and must be unique. Intrinsics cannot be unregistered.
+func RegisterIntrinsic(name string, opcode int, signature Signature, function unsafe.Pointer) error {
+	if function == nil {
+		return fmt.Errorf("intrinsic %s has no function", name)
+	}
+	args := make([]gruelparser.TokenType, len(signature.Args))
+	for i, arg := range signature.Args {
+		args[i] = gruelparser.TokenType(arg)
+	}
+	intrinsic := ir.Intrinsic{
+		Opcode: opcode,
+		Args:   args,
+		Result: gruelparser.TokenType(signature.Result),
+	}
+	return ir.RegisterIntrinsic(name, intrinsic, func() error {
+		return registerIntrinsic(name, opcode, signature, function)
+	})
+}
@@ -0,0 +1,75 @@
+//go:build !nolibjit
+
+package grueljit_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/grueljit"
+	"github.com/yesh0/gruel/pkg/grueljit/internal/cfuncs"
+)
+
+var (
+	registerIntrinsics sync.Once
+	// Holds a host function registered before an intrinsic of the same name
+	shadowed = grueljit.NewRegistry()
+)
+
+func TestIntrinsics(t *testing.T) {
+	registerIntrinsics.Do(func() {
+		assert.Nil(t, grueljit.RegisterIntrinsic("gcd", grueljit.FirstIntrinsic, grueljit.Signature{
+			Args: []byte{grueljit.TypeInt, grueljit.TypeInt}, Result: grueljit.TypeInt,
+		}, cfuncs.Gcd()))
+		assert.Nil(t, grueljit.RegisterIntrinsic("hypot", grueljit.FirstIntrinsic+1, grueljit.Signature{
+			Args: []byte{grueljit.TypeFloat, grueljit.TypeFloat}, Result: grueljit.TypeFloat,
+		}, cfuncs.Hypot()))
+		assert.Nil(t, grueljit.RegisterIntrinsic("count-byte", grueljit.FirstIntrinsic+2, grueljit.Signature{
+			Args: []byte{grueljit.TypeString, grueljit.TypeInt8}, Result: grueljit.TypeInt,
+		}, cfuncs.CountByte()))
+		sig := grueljit.Signature{Args: []byte{grueljit.TypeInt, grueljit.TypeInt}, Result: grueljit.TypeInt}
+		assert.Nil(t, shadowed.Register("gcd-or-host", sig, func(a, b int64) int64 { return 0 }))
+		assert.Nil(t, grueljit.RegisterIntrinsic("gcd-or-host", grueljit.FirstIntrinsic+3, sig, cfuncs.Gcd()))
+	})
+
+	symbols := map[string]byte{"i": grueljit.TypeInt, "f": grueljit.TypeFloat, "s": grueljit.TypeString}
+	assertCall := func(expr string, args map[string]any, expected any) {
+		f, err := grueljit.Compile(expr, symbols)
+		assert.Nil(t, err, expr)
+		if err != nil {
+			return
+		}
+		v, err := f.Call(args)
+		assert.Nil(t, err, expr)
+		assert.Equal(t, expected, v, expr)
+		f.Free()
+	}
+	assertCall("(gcd i 18)", map[string]any{"i": -12}, uint64(6))
+	assertCall("(+ (gcd (gcd i 20) 15) 1)", map[string]any{"i": 100}, uint64(6))
+	assertCall("(hypot f 4.)", map[string]any{"f": 3.}, 5.)
+	assertCall("(count-byte s 97)", map[string]any{"s": "banana"}, uint64(3))
+
+	for _, expr := range []string{"(gcd i)", "(gcd f i)", "(count-byte s 300)", "(hypot i f)"} {
+		_, err := grueljit.Compile(expr, symbols)
+		assert.NotNil(t, err, expr)
+	}
+
+	sig := grueljit.Signature{Args: []byte{grueljit.TypeInt}, Result: grueljit.TypeInt}
+	assert.EqualError(t, grueljit.RegisterIntrinsic("gcd2", grueljit.FirstIntrinsic, sig, cfuncs.Gcd()),
+		"conflicting opcode for gcd2 and gcd")
+	assert.EqualError(t, grueljit.RegisterIntrinsic("gcd", grueljit.FirstIntrinsic+3, sig, cfuncs.Gcd()),
+		"operator gcd already defined")
+	assert.EqualError(t, grueljit.RegisterIntrinsic("+", grueljit.FirstIntrinsic+3, sig, cfuncs.Gcd()),
+		"operator + already defined")
+	assert.EqualError(t, grueljit.RegisterIntrinsic("gcd2", 0x01, sig, cfuncs.Gcd()),
+		"opcode 0x1 of gcd2 out of range")
+	assert.EqualError(t, grueljit.RegisterIntrinsic("gcd2", grueljit.FirstIntrinsic+3, sig, nil),
+		"intrinsic gcd2 has no function")
+	assert.EqualError(t, grueljit.RegisterFunction("gcd", sig, func(i int) int { return i }),
+		"function name gcd is reserved")
+
+	_, err := grueljit.Compile("(gcd-or-host i 18)", symbols, grueljit.WithRegistry(shadowed))
+	assert.EqualError(t, err, "operator gcd-or-host is both an intrinsic and a host function")
+	assertCall("(gcd-or-host i 18)", map[string]any{"i": 12}, uint64(6))
+}
@@ -1,23 +1,18 @@
 package grueljit
 
-/*
-
-#cgo CFLAGS:  -I../../libjit/include
-#cgo LDFLAGS: -L../../libjit -ljit -lm
-#include "gruel_jit.h"
-
-*/
-import "C"
 import (
 	"fmt"
 	"math"
 	"reflect"
 	"runtime"
+	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/yesh0/gruel/internal/caller"
 	"github.com/yesh0/gruel/internal/gruelparser"
 	"github.com/yesh0/gruel/internal/ir"
+	"github.com/yesh0/gruel/internal/native"
 )
 
 //go:generate go run ../../build/ir/operators.go gruel_jit.c
@@ -27,150 +22,341 @@ const (
 	TypeInt    byte = byte(gruelparser.TypeInt)
 	TypeFloat  byte = byte(gruelparser.TypeFloat)
 	TypeString byte = byte(gruelparser.TypeString)
+	// Timestamps, passed as time.Time and stored as Unix nanoseconds
+	TypeTime byte = byte(gruelparser.TypeTime)
+	// Durations, passed as time.Duration
+	TypeDuration byte = byte(gruelparser.TypeDuration)
+	// Fixed-point decimals, passed as Decimal, strings, *big.Rat or numbers
+	//
+	// Multiplication and division round half to even, see package decimal.
+	TypeDecimal byte = byte(gruelparser.TypeDecimal)
+	// Sized numeric types, which wrap around like their Go counterparts
+	TypeInt8    byte = byte(gruelparser.TypeInt8)
+	TypeInt16   byte = byte(gruelparser.TypeInt16)
+	TypeInt32   byte = byte(gruelparser.TypeInt32)
+	TypeUint8   byte = byte(gruelparser.TypeUint8)
+	TypeUint16  byte = byte(gruelparser.TypeUint16)
+	TypeUint32  byte = byte(gruelparser.TypeUint32)
+	TypeUint64  byte = byte(gruelparser.TypeUint64)
+	TypeFloat32 byte = byte(gruelparser.TypeFloat32)
 )
 
+// Type names, as in schemas and declarations
+var typeNames = map[string]byte{
+	"bool":     TypeBool,
+	"int":      TypeInt,
+	"int64":    TypeInt,
+	"float":    TypeFloat,
+	"float64":  TypeFloat,
+	"string":   TypeString,
+	"time":     TypeTime,
+	"duration": TypeDuration,
+	"decimal":  TypeDecimal,
+	"int8":     TypeInt8,
+	"int16":    TypeInt16,
+	"int32":    TypeInt32,
+	"uint8":    TypeUint8,
+	"uint16":   TypeUint16,
+	"uint32":   TypeUint32,
+	"uint64":   TypeUint64,
+	"float32":  TypeFloat32,
+}
+
+// Looks up a type by name, like "int" or "decimal"
+func ParseType(name string) (byte, error) {
+	t, ok := typeNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown type %s", name)
+	}
+	return t, nil
+}
+
+// A compiled function
+//
+// Functions are safe for concurrent calls. Free may be called at any time:
+// in-flight calls finish normally, and the code is destroyed after the last one,
+// while later calls fail with ErrFreed.
 type Function struct {
-	function   uint64
-	arg_types  []byte
-	arg_map    map[string]int
-	max_stack  int
-	stringc    int
-	float      bool
+	// Either a jit_function_t or code from the native backend
+	function  uint64
+	code      *native.Code
+	arg_types []byte
+	arg_map   map[string]int
+	paths     [][]string
+	max_stack int
+	// Estimated executable memory in bytes
+	size    int
+	stringc int
+	scratch int
+	// Whether the function calls host functions
+	hosts    bool
+	float    bool
+	result   byte
+	location *time.Location
+	// Runtime errors indexed by check sites
+	errors     []RuntimeError
 	references any
+	// The IR and the options, kept for Specialize
+	program *ir.IrBuilder
+	options options
+	// Sub-expressions for Explain, nil without the source
+	explain *explainer
+	// Counters of boolean sub-expressions, see WithCoverage
+	coverage *Coverage
+	// In-flight calls, plus one until Free is called
+	refs  atomic.Int64
+	freed atomic.Bool
 }
 
-func Compile(code string, symbols map[string]byte) (*Function, error) {
+func Compile(code string, symbols map[string]byte, opts ...Option) (*Function, error) {
 	ast, err := gruelparser.Parse(code)
 	if err != nil {
 		return nil, err
 	}
-	builder, err := ir.Compile(&ast, symbols)
+	return compileAst(code, ast, symbols, collectOptions(opts))
+}
+
+// Compiles a parsed rule, whose positions point into the source
+func compileAst(code string, ast gruelparser.GruelAstNode, symbols map[string]byte, o options) (*Function, error) {
+	builder, err := ir.Compile(&ast, symbols, o.ir)
+	if err != nil {
+		return nil, err
+	}
+	f, err := newFunction(builder, code, o)
 	if err != nil {
 		return nil, err
 	}
-	f, err := compileOpcodes(builder)
-	if f != nil {
-		runtime.SetFinalizer(f, free)
+	f.explain = &explainer{source: code, ast: ast, symbols: symbols, options: o}
+	if o.coverage {
+		f.coverage, err = newCoverage(f, code, ast, symbols, o)
+		if err != nil {
+			f.Free()
+			return nil, err
+		}
 	}
-	return f, err
+	return f, nil
+}
+
+func newFunction(builder *ir.IrBuilder, code string, o options) (*Function, error) {
+	f := &Function{
+		arg_map:    builder.ArgMap(),
+		paths:      splitPaths(builder.ArgMap()),
+		references: builder.References(),
+		result:     byte(builder.ResultType()),
+		location:   builder.Location(),
+		arg_types:  builder.Args(),
+		stringc:    builder.StringArgc(),
+		scratch:    builder.ScratchSize(),
+		max_stack:  builder.MaxStack() + 256,
+		program:    builder,
+		options:    o,
+	}
+	var err error
+	if o.native || !libjit {
+		err = f.compileNative(builder)
+	} else {
+		err = f.compileOpcodes(builder)
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.refs.Store(1)
+	f.errors = runtimeErrors(code, builder.Sites())
+	for _, site := range builder.Sites() {
+		f.hosts = f.hosts || site.Check == ir.CheckHost
+	}
+	runtime.SetFinalizer(f, free)
+	return f, nil
 }
 
 func free(f *Function) {
 	f.Free()
 }
 
-// Compiles the byte code and returns a function handle.
-func compileOpcodes(ir *ir.IrBuilder) (*Function, error) {
-	code := ir.Code()
-	args := ir.Args()
-	var args_ptr *C.char
-	if len(args) != 0 {
-		args_ptr = (*C.char)(unsafe.Pointer(&args[0]))
+// Compiles the IR with the native backend
+func (f *Function) compileNative(builder *ir.IrBuilder) error {
+	code, err := native.Compile(builder)
+	if err != nil {
+		return err
 	}
+	f.code = code
+	f.float = f.result == TypeFloat
+	f.size = code.Size()
+	f.max_stack = code.Stack() + 256
+	return nil
+}
 
-	handle := uint64(C.compile_opcodes(
-		(C.long)(len(code)/8),
-		(*C.long)(unsafe.Pointer(&code[0])),
-		(C.long)(len(args)),
-		args_ptr,
-	))
-
-	if handle == 0 {
-		return nil, fmt.Errorf("unexpected error when passing to libjit")
+// Frees the resources once in-flight calls finish.
+func (f *Function) Free() {
+	if f.freed.CompareAndSwap(false, true) {
+		f.release()
 	}
+}
 
-	runtime.KeepAlive(args)
-	runtime.KeepAlive(code)
-	var float bool
-	if code[0] == 0xff {
-		float = true
+// Keeps the code alive for a call, returning false if already freed
+func (f *Function) acquire() bool {
+	for {
+		refs := f.refs.Load()
+		if refs == 0 {
+			return false
+		}
+		if f.refs.CompareAndSwap(refs, refs+1) {
+			return true
+		}
 	}
-
-	return &Function{
-		function: handle, arg_map: ir.ArgMap(),
-		float: float, references: ir.References(),
-		arg_types: ir.Args(),
-		stringc:   ir.StringArgc(),
-		max_stack: ir.MaxStack() + 256,
-	}, nil
 }
 
-// Frees the resources.
-func (f *Function) Free() {
-	C.free_function((C.long)(f.function))
-	f.function = 0
+// Destroys the code when the last reference is gone
+func (f *Function) release() {
+	if f.refs.Add(-1) == 0 {
+		if f.code != nil {
+			f.code.Free()
+		} else {
+			freeFunction(f.function)
+		}
+		if f.explain != nil {
+			f.explain.free()
+		}
+		if f.coverage != nil {
+			f.coverage.free()
+		}
+	}
 }
 
 func (f *Function) convertResult(v uint64, err error) (any, error) {
-	if f.Float() {
+	switch {
+	case f.result == TypeFloat32:
+		return float32(math.Float64frombits(v)), err
+	case f.Float():
 		return math.Float64frombits(v), err
-	} else {
+	case f.result == TypeTime:
+		return time.Unix(0, int64(v)).In(f.location), err
+	case f.result == TypeDuration:
+		return time.Duration(v), err
+	case f.result == TypeDecimal:
+		return Decimal(v), err
+	case f.result == TypeString:
+		return goString(v), err
+	case f.result == TypeInt8:
+		return int8(v), err
+	case f.result == TypeInt16:
+		return int16(v), err
+	case f.result == TypeInt32:
+		return int32(v), err
+	case f.result == TypeUint8:
+		return uint8(v), err
+	case f.result == TypeUint16:
+		return uint16(v), err
+	case f.result == TypeUint32:
+		return uint32(v), err
+	case f.result == TypeUint64:
+		return v, err
+	default:
 		return v, err
 	}
 }
 
+// Copies a string pointed to by a GoString
+func goString(ptr uint64) string {
+	if ptr == 0 {
+		return ""
+	}
+	var header []uint64
+	words := (*reflect.SliceHeader)(unsafe.Pointer(&header))
+	words.Data, words.Len, words.Cap = uintptr(ptr), 2, 2
+	var data []byte
+	chars := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+	chars.Data, chars.Len, chars.Cap = uintptr(header[0]), int(header[1]), int(header[1])
+	return string(data)
+}
+
 func (f *Function) Call(args map[string]any) (any, error) {
+	if !f.acquire() {
+		return nil, ErrFreed
+	}
+	defer f.release()
+	result, err := f.callArgs(args)
+	if f.coverage != nil {
+		f.coverage.count(args, result, err)
+	}
+	return result, err
+}
+
+// Converts the arguments and calls the function, without acquiring it
+func (f *Function) callArgs(args map[string]any) (any, error) {
 	argc := len(f.arg_map)
-	if argc == 0 {
-		return f.convertResult(f.CallRaw(nil))
+	if argc == 0 && f.scratch == 0 {
+		return f.convertResult(f.call(nil, nil))
 	}
 
-	if args == nil {
+	if args == nil && argc != 0 {
 		return nil, fmt.Errorf("requires parameters")
 	}
 
-	params := make([]uint64, argc+2*f.stringc)
-	strings := params[argc:]
+	// Scratch buffers for intrinsics follow the parameters.
+	params := make([]uint64, argc+f.scratch+2*f.stringc)
+	strings := params[argc+f.scratch:]
 	for name, index := range f.arg_map {
-		value, ok := args[name]
+		value, ok := f.lookup(args, name, index)
 		if !ok {
 			return nil, fmt.Errorf("parameter %s not found", name)
 		}
-		target := f.arg_types[index]
-		if v, ok := value.(string); ok {
-			if target == TypeString {
-				hdr := (*reflect.StringHeader)(unsafe.Pointer(&v))
-				strings[0] = uint64(hdr.Data)
-				strings[1] = uint64(hdr.Len)
-				params[index] = uint64(uintptr(unsafe.Pointer(&strings[0])))
-				strings = strings[2:]
-			} else {
-				return nil, fmt.Errorf("unsupported conversion from string")
-			}
-		} else {
-			if target == TypeString {
-				return nil, fmt.Errorf("unsupported conversion into string")
-			} else {
-				converted, err := convertType(value, target)
-				if err == nil {
-					params[index] = converted
-				} else {
-					return nil, err
-				}
-			}
+		if v, ok := value.(string); ok && f.arg_types[index] == TypeString {
+			hdr := (*reflect.StringHeader)(unsafe.Pointer(&v))
+			strings[0] = uint64(hdr.Data)
+			strings[1] = uint64(hdr.Len)
+			params[index] = uint64(uintptr(unsafe.Pointer(&strings[0])))
+			strings = strings[2:]
+			continue
+		}
+		converted, err := convertArg(value, f.arg_types[index])
+		if err != nil {
+			return nil, err
 		}
+		params[index] = converted
+	}
+	// Results may point into the parameters or strings returned by host functions.
+	ctx := &hostContext{location: f.location}
+	result, err := f.convertResult(f.call(params, ctx))
+	runtime.KeepAlive(params)
+	runtime.KeepAlive(ctx)
+	return result, err
+}
+
+// Converts a non-string argument into the parameter type
+func convertArg(value any, target byte) (uint64, error) {
+	if target == TypeDecimal {
+		return convertDecimal(value)
 	}
-	return f.convertResult(f.CallRaw(params))
+	if _, ok := value.(string); ok {
+		return 0, fmt.Errorf("unsupported conversion from string")
+	}
+	if target == TypeString {
+		return 0, fmt.Errorf("unsupported conversion into string")
+	}
+	return convertType(value, target)
 }
 
 func convertType(param any, target byte) (uint64, error) {
 	var out uint64
 	var realType = TypeInt
+	// Whether out holds an unsigned value, which may exceed math.MaxInt64
+	unsigned := false
 	switch v := param.(type) {
 	case bool:
 		if v {
 			out = 1
 		}
 	case uint:
-		out = uint64(v)
+		out, unsigned = uint64(v), true
 	case uint8:
-		out = uint64(v)
+		out, unsigned = uint64(v), true
 	case uint16:
-		out = uint64(v)
+		out, unsigned = uint64(v), true
 	case uint32:
-		out = uint64(v)
+		out, unsigned = uint64(v), true
 	case uint64:
-		out = uint64(v)
+		out, unsigned = v, true
 	case int:
 		out = uint64(v)
 	case int8:
@@ -187,29 +373,139 @@ func convertType(param any, target byte) (uint64, error) {
 	case float64:
 		out = math.Float64bits(v)
 		realType = TypeFloat
+	case time.Time:
+		out = uint64(v.UnixNano())
+		realType = TypeTime
+	case time.Duration:
+		out = uint64(v)
+		realType = TypeDuration
 	default:
 		return 0, fmt.Errorf("unsupported type")
 	}
+	if (target == TypeTime || target == TypeDuration || realType == TypeTime ||
+		realType == TypeDuration) && target != realType {
+		return 0, fmt.Errorf("unsupported conversion between time types")
+	}
+	var f float64
+	switch {
+	case realType == TypeFloat:
+		f = math.Float64frombits(out)
+	case unsigned:
+		f = float64(out)
+	default:
+		f = float64(int64(out))
+	}
 	switch {
 	case target == TypeFloat:
-		if realType != TypeFloat {
-			out = math.Float64bits(float64(out))
-		}
+		out = math.Float64bits(f)
 	case target == TypeBool:
 		if out != 0 {
 			out = 1
 		}
-	case target == TypeInt:
+	case target == TypeFloat32:
+		// Only the lower 32 bits are loaded.
+		out = uint64(math.Float32bits(float32(f)))
+	case target == TypeInt || TypeInt8 <= target && target <= TypeUint64:
 		if realType == TypeFloat {
-			out = uint64(math.Float64frombits(out))
+			// Floats are truncated, within the range of the target.
+			switch {
+			case target == TypeUint64 && 0 <= f && f < 1<<64:
+				out, unsigned = uint64(f), true
+			case -(1<<63) <= f && f < 1<<63:
+				out, unsigned = uint64(int64(f)), false
+			default:
+				return 0, fmt.Errorf("%v overflows %s", param, typeName(target))
+			}
+		}
+		if !fits(out, unsigned, target) {
+			return 0, fmt.Errorf("%v overflows %s", param, typeName(target))
 		}
 	}
 	return out, nil
 }
 
+// Whether an integer is within the range of an integer type
+//
+// Ints take any 64 bits, like unsigned values passed for their bits.
+func fits(v uint64, unsigned bool, target byte) bool {
+	switch target {
+	case TypeInt:
+		return true
+	case TypeUint64:
+		return unsigned || int64(v) >= 0
+	}
+	if unsigned && v > math.MaxInt64 {
+		return false
+	}
+	i := int64(v)
+	switch target {
+	case TypeInt8:
+		return math.MinInt8 <= i && i <= math.MaxInt8
+	case TypeInt16:
+		return math.MinInt16 <= i && i <= math.MaxInt16
+	case TypeInt32:
+		return math.MinInt32 <= i && i <= math.MaxInt32
+	case TypeUint8:
+		return 0 <= i && i <= math.MaxUint8
+	case TypeUint16:
+		return 0 <= i && i <= math.MaxUint16
+	case TypeUint32:
+		return 0 <= i && i <= math.MaxUint32
+	default:
+		return true
+	}
+}
+
+// The name of a type, preferring short ones like int over int64
+func typeName(t byte) string {
+	name := ""
+	for n, v := range typeNames {
+		if v == t && (name == "" || len(n) < len(name)) {
+			name = n
+		}
+	}
+	if name == "" {
+		return fmt.Sprintf("type %d", t)
+	}
+	return name
+}
+
 // Calls the function
+//
+// The parameters must be followed by ScratchSize() words of scratch space.
+// String results may point into the parameters, which must stay alive while
+// the result is read. Functions returning strings from host functions need
+// CallRawFunc instead.
 func (f *Function) CallRaw(params []uint64) (uint64, error) {
-	argc := len(f.arg_map)
+	if f.hosts && f.result == TypeString {
+		return 0, fmt.Errorf("string results of host functions need CallRawFunc")
+	}
+	var result uint64
+	err := f.CallRawFunc(params, func(v uint64) { result = v })
+	return result, err
+}
+
+// Calls the function like CallRaw, passing the result to use
+//
+// Strings returned by host functions stay alive until use returns,
+// so use should copy string results it keeps.
+func (f *Function) CallRawFunc(params []uint64, use func(result uint64)) error {
+	if !f.acquire() {
+		return ErrFreed
+	}
+	defer f.release()
+	ctx := &hostContext{location: f.location}
+	result, err := f.call(params, ctx)
+	if err == nil {
+		use(result)
+	}
+	runtime.KeepAlive(ctx)
+	return err
+}
+
+// Runs the function, with a context that callers keep alive while reading results
+func (f *Function) call(params []uint64, ctx *hostContext) (uint64, error) {
+	argc := len(f.arg_map) + f.scratch
 	if params != nil {
 		if len(params) < argc {
 			return 0, fmt.Errorf("arguments not enough")
@@ -218,21 +514,40 @@ func (f *Function) CallRaw(params []uint64) (uint64, error) {
 		return 0, fmt.Errorf("no arguments provided")
 	}
 
-	ret := caller.CallJit(
-		f.function,
-		params,
-		uint64(f.max_stack),
-	)
+	var ret uint64
+	if f.code != nil {
+		ret = caller.CallNative(f.code.Entry(), params, uint64(f.max_stack))
+	} else {
+		ret = f.callLibJit(params, ctx)
+	}
 	runtime.KeepAlive(params)
+	if len(f.errors) != 0 {
+		// The error slot is the first scratch word.
+		slot := &params[len(f.arg_map)]
+		if *slot != 0 {
+			err := f.runtimeError(*slot, ctx)
+			*slot = 0
+			return 0, err
+		}
+	}
 	return ret, nil
 }
 
+// Estimated executable memory taken by the function, in bytes
+func (f *Function) Size() int {
+	return f.size
+}
+
+// Scratch space needed after the parameters by CallRaw, in words
+func (f *Function) ScratchSize() int {
+	return f.scratch
+}
+
 func (f *Function) Float() bool {
 	return f.float
 }
 
-// Returns false if the code is interpreted
-// (which may very likely overflow the stack).
-func IsJit() bool {
-	return C.is_jit_supported() != 0
+// The type of the result, one of the Type* constants
+func (f *Function) ResultType() byte {
+	return f.result
 }
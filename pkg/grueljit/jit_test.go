@@ -8,15 +8,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/yesh0/gruel/internal/caller"
 	"github.com/yesh0/gruel/internal/ir"
 	"github.com/yesh0/gruel/pkg/grueljit"
 )
 
-func TestCaller(t *testing.T) {
-	assert.Equal(t, uint64(0), caller.CallJit(0, nil, 0))
-}
-
 func assertResult(t *testing.T, expr string, result any) {
 	f, err := grueljit.Compile(expr, nil)
 	assert.Nil(t, err)
@@ -57,10 +52,6 @@ func TestJit(t *testing.T) {
 	assertResult(t, "(+ 1.23 0.00456)", 1.23456)
 	assertResult(t, "(/ 10 0.5)", 20.0)
 
-	// Integer zero-division
-	assertResult(t, "(/ 123000 0)", 0)
-	assertResult(t, "(% 123000 0)", 0)
-
 	assertResult(t, "(+ (- (* (/ 4 (% 6 5)) 3) 2) 1)", (4/(6%5))*3-2+1)
 }
 
@@ -112,10 +103,20 @@ var string_only = []string{
 	"len", "index",
 }
 
+var time_only = []string{
+	"hour", "minute", "weekday", "day-of-month", "month", "year",
+}
+
+var decimal_only = []string{
+	"round-half-even", "round-half-up", "round-down", "round-up",
+	"round-floor", "round-ceiling",
+}
+
 func TestOps(t *testing.T) {
+	requireLibJit(t)
 	for name, ops := range ir.Operators {
 		no_arithmetic := false
-		for _, v := range string_only {
+		for _, v := range append(append(string_only, time_only...), decimal_only...) {
 			if v == name {
 				no_arithmetic = true
 			}
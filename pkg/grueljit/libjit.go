@@ -0,0 +1,126 @@
+//go:build !nolibjit
+
+package grueljit
+
+/*
+
+#cgo CFLAGS:  -I../../libjit/include
+#cgo LDFLAGS: -L../../libjit -ljit -lm
+#include "gruel_jit.h"
+
+*/
+import "C"
+import (
+	"fmt"
+	"runtime"
+	"runtime/cgo"
+	"unsafe"
+
+	"github.com/yesh0/gruel/internal/caller"
+	"github.com/yesh0/gruel/internal/ir"
+)
+
+// Whether LibJIT is linked, unless built with the nolibjit tag
+const libjit = true
+
+// Rough estimates of executable memory: LibJIT contexts take a page at least,
+// and each IR instruction takes a few machine instructions.
+const (
+	contextOverhead = 4096
+	instructionSize = 32
+)
+
+// Compiles the byte code with LibJIT.
+func (f *Function) compileOpcodes(ir *ir.IrBuilder) error {
+	// LibJIT compilation overwrites the code, which Specialize still needs.
+	code := append([]byte(nil), ir.Code()...)
+	args := ir.Args()
+	var args_ptr *C.char
+	if len(args) != 0 {
+		args_ptr = (*C.char)(unsafe.Pointer(&args[0]))
+	}
+
+	handle := uint64(C.compile_opcodes(
+		(C.long)(len(code)/8),
+		(*C.long)(unsafe.Pointer(&code[0])),
+		(C.long)(len(args)),
+		args_ptr,
+	))
+
+	if handle == 0 {
+		return fmt.Errorf("unexpected error when passing to libjit")
+	}
+
+	runtime.KeepAlive(args)
+	runtime.KeepAlive(code)
+	f.function = handle
+	f.float = code[0] == 0xff
+	f.size = contextOverhead + len(code)/16*instructionSize
+	return nil
+}
+
+func freeFunction(function uint64) {
+	C.free_function((C.long)(function))
+}
+
+func (f *Function) callLibJit(params []uint64, ctx *hostContext) uint64 {
+	if !f.hosts {
+		return caller.CallJit(
+			f.function,
+			params,
+			uint64(f.max_stack),
+		)
+	}
+	// Host functions run Go code, which needs a cgo call instead of a stack switch.
+	handle := cgo.NewHandle(ctx)
+	// The context follows the error slot.
+	params[len(f.arg_map)+1] = uint64(handle)
+	ret := uint64(C.call_jit_function_cgo(
+		C.jit_long(f.function),
+		(*C.jit_long)(unsafe.Pointer(&params[0])),
+	))
+	handle.Delete()
+	return ret
+}
+
+// Identifies host functions in compiled code
+func newHandle(f *hostFunction) uint64 {
+	return uint64(cgo.NewHandle(f))
+}
+
+// The trampoline called by compiled code, see call_host in gruel_jit.c
+//
+//export gruelCallHost
+func gruelCallHost(frame unsafe.Pointer) {
+	words := unsafe.Slice((*uint64)(frame), hostFrameWords)
+	f := cgo.Handle(words[0]).Value().(*hostFunction)
+	ctx := cgo.Handle(words[1]).Value().(*hostContext)
+	words = unsafe.Slice((*uint64)(frame), hostFrameWords+len(f.signature.Args))
+	result, err := f.call(ctx, words[hostFrameWords:])
+	if err != nil {
+		ctx.err = err
+		words[2] = 1
+		return
+	}
+	words[3] = result
+}
+
+func registerIntrinsic(name string, opcode int, signature Signature, function unsafe.Pointer) error {
+	types := append([]byte(nil), signature.Args...)
+	if C.register_intrinsic(
+		C.jit_long(opcode),
+		function,
+		C.jit_long(len(types)),
+		(*C.char)(unsafe.Pointer(&types[0])),
+		C.jit_int(signature.Result),
+	) == 0 {
+		return fmt.Errorf("unable to register intrinsic %s", name)
+	}
+	return nil
+}
+
+// Returns false if the code is interpreted
+// (which may very likely overflow the stack).
+func IsJit() bool {
+	return C.is_jit_supported() != 0
+}
@@ -0,0 +1,17 @@
+//go:build !nolibjit
+
+package grueljit_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/internal/caller"
+)
+
+func TestCaller(t *testing.T) {
+	assert.Equal(t, uint64(0), caller.CallJit(0, nil, 0))
+}
+
+func requireLibJit(t *testing.T) {
+}
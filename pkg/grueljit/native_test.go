@@ -0,0 +1,67 @@
+package grueljit_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/internal/gogen/golden"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+func TestNative(t *testing.T) {
+	compiled := 0
+	for _, c := range golden.Cases {
+		f, err := grueljit.Compile(c.Code, c.Symbols, grueljit.WithNativeBackend())
+		if err != nil {
+			assert.Contains(t, err.Error(), "not supported by the native backend", c.Code)
+			continue
+		}
+		compiled++
+		for _, args := range c.Args {
+			expected, goErr := c.Call(args)
+			actual, err := f.Call(args)
+			if goErr != nil {
+				assert.EqualError(t, err, goErr.Error(), c.Code)
+				continue
+			}
+			assert.Nil(t, err, c.Code)
+			expected = normalize(expected)
+			if e, ok := expected.(float64); ok && math.IsNaN(e) {
+				assert.True(t, math.IsNaN(actual.(float64)), "%s %v", c.Code, args)
+			} else {
+				assert.Equal(t, expected, actual, "%s %v", c.Code, args)
+			}
+		}
+		f.Free()
+	}
+	assert.Greater(t, compiled, len(golden.Cases)/2)
+}
+
+func TestNativeErrors(t *testing.T) {
+	f, err := grueljit.Compile("(+ 1\n  (/ x 0))", map[string]byte{"x": grueljit.TypeInt},
+		grueljit.WithNativeBackend())
+	assert.Nil(t, err)
+	_, err = f.Call(map[string]any{"x": 1})
+	assert.EqualError(t, err, "runtime error at 2:3: division by zero in /")
+	f.Free()
+
+	f, err = grueljit.Compile("(/ x 0)", map[string]byte{"x": grueljit.TypeInt},
+		grueljit.WithNativeBackend(), grueljit.WithLenientMath())
+	assert.Nil(t, err)
+	v, err := f.Call(map[string]any{"x": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), v)
+	f.Free()
+
+	r := grueljit.NewRegistry()
+	assert.Nil(t, r.Register("double", grueljit.Signature{
+		Args: []byte{grueljit.TypeInt}, Result: grueljit.TypeInt,
+	}, func(i int) int { return 2 * i }))
+	_, err = grueljit.Compile("(double 1)", nil, grueljit.WithNativeBackend(), grueljit.WithRegistry(r))
+	assert.EqualError(t, err, "host functions are not supported by the native backend")
+	_, err = grueljit.Compile("(hour t)", map[string]byte{"t": grueljit.TypeTime}, grueljit.WithNativeBackend())
+	assert.EqualError(t, err, "operator hour is not supported by the native backend")
+	_, err = grueljit.Compile("(+ x 1)", map[string]byte{"x": grueljit.TypeInt8}, grueljit.WithNativeBackend())
+	assert.EqualError(t, err, "type int8 is not supported by the native backend")
+}
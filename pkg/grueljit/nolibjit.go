@@ -0,0 +1,41 @@
+//go:build nolibjit
+
+package grueljit
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/yesh0/gruel/internal/ir"
+)
+
+// Whether LibJIT is linked, unless built with the nolibjit tag
+const libjit = false
+
+func (f *Function) compileOpcodes(ir *ir.IrBuilder) error {
+	return fmt.Errorf("built without LibJIT")
+}
+
+func freeFunction(function uint64) {
+}
+
+func (f *Function) callLibJit(params []uint64, ctx *hostContext) uint64 {
+	return 0
+}
+
+var handles atomic.Uint64
+
+// Identifies host functions in compiled code, which are never called without LibJIT
+func newHandle(f *hostFunction) uint64 {
+	return handles.Add(1)
+}
+
+func registerIntrinsic(name string, opcode int, signature Signature, function unsafe.Pointer) error {
+	return fmt.Errorf("intrinsics are not supported without LibJIT")
+}
+
+// Returns false if the code is interpreted, which the native backend never does
+func IsJit() bool {
+	return true
+}
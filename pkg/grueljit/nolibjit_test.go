@@ -0,0 +1,9 @@
+//go:build nolibjit
+
+package grueljit_test
+
+import "testing"
+
+func requireLibJit(t *testing.T) {
+	t.Skip("built without LibJIT")
+}
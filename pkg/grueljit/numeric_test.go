@@ -0,0 +1,170 @@
+package grueljit_test
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+var sizedSymbols = map[string]byte{
+	"i8":  grueljit.TypeInt8,
+	"i16": grueljit.TypeInt16,
+	"i32": grueljit.TypeInt32,
+	"u8":  grueljit.TypeUint8,
+	"u16": grueljit.TypeUint16,
+	"u32": grueljit.TypeUint32,
+	"u64": grueljit.TypeUint64,
+	"f32": grueljit.TypeFloat32,
+	"i":   grueljit.TypeInt,
+	"f":   grueljit.TypeFloat,
+}
+
+func assertSized(t *testing.T, expr string, args map[string]any, expected any) {
+	f, err := grueljit.Compile(expr, sizedSymbols)
+	assert.Nil(t, err, expr)
+	if err != nil {
+		return
+	}
+	v, err := f.Call(args)
+	assert.Nil(t, err, expr)
+	assert.Equal(t, expected, v, expr)
+	f.Free()
+}
+
+func TestSizedTypes(t *testing.T) {
+	requireLibJit(t)
+	assertSized(t, "(+ i8 1)", map[string]any{"i8": int8(127)}, int8(-128))
+	assertSized(t, "(* i16 2)", map[string]any{"i16": int16(20000)}, int16(-25536))
+	assertSized(t, "(+ i32 i32)", map[string]any{"i32": int32(math.MaxInt32)}, int32(-2))
+	assertSized(t, "(- u8 1)", map[string]any{"u8": uint8(0)}, uint8(255))
+	assertSized(t, "(* u16 u16)", map[string]any{"u16": uint16(300)}, uint16(24464))
+	assertSized(t, "(+ u32 1)", map[string]any{"u32": uint32(math.MaxUint32)}, uint32(0))
+	assertSized(t, "(>> u64 60)", map[string]any{"u64": uint64(math.MaxUint64)}, uint64(15))
+	assertSized(t, "(/ u64 2)", map[string]any{"u64": uint64(math.MaxUint64)}, uint64(math.MaxUint64/2))
+	assertSized(t, "(> u64 1)", map[string]any{"u64": uint64(math.MaxUint64)}, uint64(1))
+	assertSized(t, "(>> i8 1)", map[string]any{"i8": int8(-128)}, int8(-64))
+	assertSized(t, "(<< i8 i)", map[string]any{"i8": int8(1), "i": 7}, int8(-128))
+	assertSized(t, "(* f32 0.1)", map[string]any{"f32": float32(3)}, float32(3)*float32(0.1))
+	assertSized(t, "i8", map[string]any{"i8": -128}, int8(-128))
+	assertSized(t, "u64", map[string]any{"u64": uint(math.MaxUint64)}, uint64(math.MaxUint64))
+	assertSized(t, "u32", map[string]any{"u32": 4e9}, uint32(4e9))
+	assertSized(t, "f", map[string]any{"f": -1}, -1.)
+
+	for _, expr := range []string{"(+ i8 i16)", "(+ i8 i)", "(+ f32 f)", "(+ i8 300)", "(+ u8 -1)", "(== u8 u16)",
+		"(== u64 -1)"} {
+		_, err := grueljit.Compile(expr, sizedSymbols)
+		assert.NotNil(t, err, expr)
+	}
+}
+
+func TestFloatBitwise(t *testing.T) {
+	for expr, msg := range map[string]string{
+		"(& f 1)":     "operator & does not accept float and int",
+		"(| 1 f)":     "operator | does not accept int and float",
+		"(^ f f)":     "operator ^ does not accept float and float",
+		"(<< f 1)":    "operator << does not accept float and int",
+		"(>> 1 f)":    "operator >> does not accept int and float",
+		"(>>> f32 1)": "operator >>> does not accept float32 and int",
+	} {
+		_, err := grueljit.Compile(expr, sizedSymbols)
+		assert.EqualError(t, err, msg, expr)
+	}
+}
+
+func TestSizedArgumentRanges(t *testing.T) {
+	requireLibJit(t)
+	for msg, args := range map[string]map[string]any{
+		"511 overflows int8":                   {"i8": 511},
+		"-1 overflows uint16":                  {"u16": -1},
+		"-1 overflows uint64":                  {"u64": int64(-1)},
+		"18446744073709551615 overflows int32": {"i32": uint64(math.MaxUint64)},
+		"300.5 overflows uint8":                {"u8": 300.5},
+		"1e+20 overflows uint64":               {"u64": 1e20},
+		"NaN overflows int":                    {"i": math.NaN()},
+		"1e+19 overflows int":                  {"i": 1e19},
+	} {
+		for name := range args {
+			f, err := grueljit.Compile(name, sizedSymbols)
+			assert.Nil(t, err)
+			_, err = f.Call(args)
+			assert.EqualError(t, err, msg)
+			f.Free()
+		}
+	}
+}
+
+func TestCasts(t *testing.T) {
+	requireLibJit(t)
+	assertSized(t, "(->int8 i)", map[string]any{"i": 200}, int8(-56))
+	assertSized(t, "(->uint i)", map[string]any{"i": -1}, uint64(math.MaxUint64))
+	assertSized(t, "(->int f)", map[string]any{"f": -2.75}, uint64(0xfffffffffffffffe))
+	assertSized(t, "(->float i32)", map[string]any{"i32": int32(-3)}, -3.)
+	assertSized(t, "(->float32 f)", map[string]any{"f": 0.1}, float32(0.1))
+	assertSized(t, "(+ (->int u8) i)", map[string]any{"u8": uint8(255), "i": 1}, uint64(256))
+	assertResult(t, "(->int 1.5d)", 1)
+	assertResult(t, "(->float 1.5d)", 1.5)
+}
+
+func TestFormat(t *testing.T) {
+	requireLibJit(t)
+	floats := []float64{0, 1, -0.5, 1e6, 123456.7, 1e-5, 1.0 / 3, math.MaxFloat64, math.Inf(-1), math.NaN()}
+	for _, v := range floats {
+		assertSized(t, "(->string f)", map[string]any{"f": v}, strconv.FormatFloat(v, 'g', -1, 64))
+		assertSized(t, "(->string f32)", map[string]any{"f32": float32(v)},
+			strconv.FormatFloat(float64(float32(v)), 'g', -1, 32))
+	}
+	for _, v := range []int64{0, -1, math.MinInt64, math.MaxInt64} {
+		assertSized(t, "(->string i)", map[string]any{"i": v}, strconv.FormatInt(v, 10))
+		assertSized(t, "(->string u64)", map[string]any{"u64": uint64(v)}, strconv.FormatUint(uint64(v), 10))
+	}
+	assertSized(t, "(->string i8)", map[string]any{"i8": int8(-8)}, "-8")
+	assertSized(t, "(->string (> i 0))", map[string]any{"i": 1}, "true")
+	assertSized(t, "(->string -12.50d)", nil, "-12.5")
+	assertSized(t, "(->string \"text\")", nil, "text")
+	assertSized(t, "(== (->string i) \"42\" )", map[string]any{"i": 42}, uint64(1))
+	assertSized(t, "(index (->string f) (->string i))", map[string]any{"f": 0.25, "i": 25}, uint64(2))
+}
+
+func TestNumericLiterals(t *testing.T) {
+	for expr, expected := range map[string]any{
+		"(+ 1_000 0b10 0o10 0x_10)": uint64(1026),
+		"(* 2 10i)":                 uint64(20),
+		"1e9":                       1e9,
+		"(+ 0x1p-2 .5f)":            0.75,
+		"(> inf 1e308)":             uint64(1),
+		"(!= nan nan)":              uint64(1),
+		"(!= -nan +nan)":            uint64(1),
+		"(- 1_000.5 10f)":           990.5,
+	} {
+		f, err := grueljit.Compile(expr, nil)
+		assert.Nil(t, err, expr)
+		if err != nil {
+			continue
+		}
+		v, err := f.Call(nil)
+		assert.Nil(t, err, expr)
+		assert.Equal(t, expected, v, expr)
+		f.Free()
+	}
+
+	_, err := grueljit.Compile("(+ 1 1e)", nil)
+	assert.EqualError(t, err, "1:6: invalid number 1e")
+}
+
+func TestUnsignedLiterals(t *testing.T) {
+	requireLibJit(t)
+	assertSized(t, "(+ u64 18446744073709551615u)", map[string]any{"u64": uint64(1)}, uint64(0))
+	assertSized(t, "(>> 10u 1)", nil, uint64(5))
+	assertSized(t, "(== u64 10u)", map[string]any{"u64": uint64(10)}, uint64(1))
+	_, err := grueljit.Compile("(+ i 10u)", sizedSymbols)
+	assert.NotNil(t, err)
+}
+
+func TestUnsignedLiteralsNative(t *testing.T) {
+	// Literals are typed constants rather than casts.
+	_, err := grueljit.Compile("(>> 10u 1)", nil, grueljit.WithNativeBackend())
+	assert.EqualError(t, err, "type uint64 is not supported by the native backend")
+}
@@ -0,0 +1,89 @@
+package grueljit
+
+import (
+	"time"
+
+	"github.com/yesh0/gruel/internal/ir"
+)
+
+// Compilation options
+type Option func(*options)
+
+type options struct {
+	ir       ir.Options
+	registry *Registry
+	native   bool
+	coverage bool
+}
+
+func collectOptions(opts []Option) options {
+	o := options{registry: defaultRegistry}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.ir.Functions = o.registry.snapshot()
+	return o
+}
+
+// Sets the time zone for timestamp literals and calendar operators
+//
+// Calendar operators like `hour` and `weekday` are evaluated in UTC by default.
+func WithLocation(loc *time.Location) Option {
+	return func(o *options) {
+		o.ir.Location = loc
+	}
+}
+
+// Disables runtime checks
+//
+// Integer division by zero then yields 0 instead of a RuntimeError,
+// shifts by invalid amounts give whatever the CPU gives, and decimal results
+// out of range are clamped (NaN converting to zero).
+func WithLenientMath() Option {
+	return func(o *options) {
+		o.ir.Lenient = true
+	}
+}
+
+// Reports NaN results of floating point operators as runtime errors
+func WithStrictNaN() Option {
+	return func(o *options) {
+		o.ir.StrictNaN = true
+	}
+}
+
+// Resolves host functions from the registry instead of the default one
+func WithRegistry(r *Registry) Option {
+	return func(o *options) {
+		o.registry = r
+	}
+}
+
+// Compiles into x86-64 machine code directly instead of with LibJIT
+//
+// The native backend supports arithmetic, comparison and logic operators
+// on booleans, integers, floats, timestamps, durations and decimals
+// (except decimal multiplication and division), along with `len`, `index`
+// and string equality. Other operators, sized types, host functions and intrinsics
+// fail to compile. It is always used in builds with the nolibjit tag,
+// which need neither LibJIT nor cgo.
+func WithNativeBackend() Option {
+	return func(o *options) {
+		o.native = true
+	}
+}
+
+// Counts how often each boolean sub-expression is true or false, see Function.Coverage
+//
+// Comparisons, logic operators and boolean parameters are compiled into
+// functions of their own, evaluated along with the function by every Call,
+// which makes calls several times slower. CallRaw is not counted.
+//
+// Since sub-expressions are evaluated again, a host function would run once
+// more for every sub-expression containing it, so rules calling host functions
+// fail to compile with coverage.
+func WithCoverage() Option {
+	return func(o *options) {
+		o.coverage = true
+	}
+}
@@ -0,0 +1,136 @@
+package grueljit
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Declares the fields of a nested record parameter
+//
+// Fields are stored in the symbol table as dotted paths ("user.age"),
+// each of which gets its own parameter slot at compile time.
+// Field names may themselves be dotted to declare deeper records.
+func DeclareRecord(symbols map[string]byte, name string, fields map[string]byte) map[string]byte {
+	if symbols == nil {
+		symbols = make(map[string]byte, len(fields))
+	}
+	for field, t := range fields {
+		symbols[name+"."+field] = t
+	}
+	return symbols
+}
+
+// Splits dotted parameter names into paths, indexed by parameter indices
+//
+// Plain parameters get a nil path.
+func splitPaths(argMap map[string]int) [][]string {
+	var paths [][]string
+	for name, index := range argMap {
+		if !strings.Contains(name, ".") {
+			continue
+		}
+		if paths == nil {
+			paths = make([][]string, len(argMap))
+		}
+		paths[index] = strings.Split(name, ".")
+	}
+	return paths
+}
+
+// Looks up a parameter value, walking through nested records if needed
+func (f *Function) lookup(args map[string]any, name string, index int) (any, bool) {
+	value, ok := args[name]
+	if ok || f.paths == nil || f.paths[index] == nil {
+		return value, ok
+	}
+	return lookupPath(args, f.paths[index])
+}
+
+// Walks through nested records along the path
+func lookupPath(args map[string]any, path []string) (any, bool) {
+	value, ok := args[path[0]]
+	for _, field := range path[1:] {
+		if !ok {
+			return nil, false
+		}
+		value, ok = lookupField(value, field)
+	}
+	return value, ok
+}
+
+// Caches struct field indices by struct type and field name
+var fieldIndices sync.Map
+
+type fieldKey struct {
+	t    reflect.Type
+	name string
+}
+
+// Looks up a field in a map[string]any or (a pointer to) a struct
+//
+// Struct fields are matched by their `gruel:"name"` tags first
+// and then by their names, case-insensitively if no exact match is found.
+func lookupField(record any, field string) (any, bool) {
+	if m, ok := record.(map[string]any); ok {
+		v, ok := m[field]
+		return v, ok
+	}
+	v := reflect.ValueOf(record)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		item := v.MapIndex(reflect.ValueOf(field).Convert(v.Type().Key()))
+		if !item.IsValid() {
+			return nil, false
+		}
+		return item.Interface(), true
+	case reflect.Struct:
+		key := fieldKey{v.Type(), field}
+		cached, ok := fieldIndices.Load(key)
+		if !ok {
+			cached = structField(v.Type(), field)
+			fieldIndices.Store(key, cached)
+		}
+		index := cached.([]int)
+		if index == nil {
+			return nil, false
+		}
+		item, err := v.FieldByIndexErr(index)
+		if err != nil || !item.CanInterface() {
+			return nil, false
+		}
+		return item.Interface(), true
+	default:
+		return nil, false
+	}
+}
+
+// Finds the index of a struct field, returning nil if not found
+func structField(t reflect.Type, field string) []int {
+	fields := reflect.VisibleFields(t)
+	for _, f := range fields {
+		if f.Tag.Get("gruel") == field && f.IsExported() {
+			return f.Index
+		}
+	}
+	for _, f := range fields {
+		if f.Name == field && f.IsExported() {
+			return f.Index
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.Name, field) && f.IsExported() {
+			return f.Index
+		}
+	}
+	return nil
+}
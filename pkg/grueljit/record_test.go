@@ -0,0 +1,89 @@
+package grueljit_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+type address struct {
+	City string
+	Zip  int `gruel:"zip-code"`
+}
+
+type user struct {
+	Age     int
+	Address *address
+}
+
+func TestRecordFields(t *testing.T) {
+	symbols := grueljit.DeclareRecord(nil, "user", map[string]byte{
+		"age":              grueljit.TypeInt,
+		"address.City":     grueljit.TypeString,
+		"address.zip-code": grueljit.TypeInt,
+	})
+	for _, expr := range []string{
+		"(&& (> user.age 18) (== user.address.City \"Paris\"))",
+		"(&& (> (get user \"age\") 18) (== (get (get user \"address\") \"City\") \"Paris\"))",
+	} {
+		f, err := grueljit.Compile(expr, symbols)
+		assert.Nil(t, err)
+
+		result, err := f.Call(map[string]any{
+			"user": map[string]any{
+				"age":     20,
+				"address": map[string]any{"City": "Paris"},
+			},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), result)
+
+		result, err = f.Call(map[string]any{
+			"user": map[string]any{
+				"age":     20,
+				"address": &address{City: "Berlin"},
+			},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(0), result)
+
+		// Flattened values still work.
+		result, err = f.Call(map[string]any{
+			"user.age":          30,
+			"user.address.City": "Paris",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), result)
+
+		_, err = f.Call(map[string]any{"user": map[string]any{"age": 20}})
+		assert.NotNil(t, err)
+		f.Free()
+	}
+
+	f, err := grueljit.Compile("(+ user.address.zip-code user.age)", symbols)
+	assert.Nil(t, err)
+	result, err := f.Call(map[string]any{
+		"user": user{Age: 1, Address: &address{Zip: 75000}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(75001), result)
+	f.Free()
+}
+
+func TestRecordErrors(t *testing.T) {
+	symbols := map[string]byte{"user.age": grueljit.TypeInt}
+	_, err := grueljit.Compile("(> user 18)", symbols)
+	assert.NotNil(t, err)
+	_, err = grueljit.Compile("(> (get user age) 18)", symbols)
+	assert.NotNil(t, err)
+	_, err = grueljit.Compile("(> (get user) 18)", symbols)
+	assert.NotNil(t, err)
+	_, err = grueljit.Compile("1", map[string]byte{
+		"user":     grueljit.TypeInt,
+		"user.age": grueljit.TypeInt,
+	})
+	assert.NotNil(t, err)
+	_, err = grueljit.Compile("1", map[string]byte{"user..age": grueljit.TypeInt})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,315 @@
+package grueljit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/yesh0/gruel/internal/gruelparser"
+	"github.com/yesh0/gruel/internal/ir"
+	"github.com/yesh0/gruel/pkg/gruelast"
+)
+
+// A named rule loaded from a rule file
+type Rule struct {
+	Name string
+	// Left to callers, like for ordering rules, 0 by default
+	Priority int64
+	Tags     []string
+	// The body with constants inlined, positioned in the file
+	Body gruelast.Node
+	// The compiled body, freed along with the rule set, nil from ParseRules
+	Function *Function
+}
+
+// Rules loaded from a rule file
+type RuleSet struct {
+	// The declared variables, as passed to Compile
+	Symbols map[string]byte
+	// The rules in the order of the file
+	Rules []*Rule
+	// The file, which positions point into
+	Source string
+}
+
+// Compiles the rules of a rule file
+//
+// A rule file holds declarations, constants and rules, like:
+//
+//	(declare (amount float) (country string) (user.age int))
+//	(const limit 1000.0)
+//	(rule large-payment :priority 10 :tags (fraud payments)
+//	  (&& (> amount limit) (!= country "NZ")))
+//
+// Declarations apply to the whole file, with dotted names for record fields.
+// Constants may use constants defined before them and are inlined into the
+// rules that follow. Errors are *gruelast.SyntaxError, positioned in the file.
+func LoadRules(r io.Reader, opts ...Option) (*RuleSet, error) {
+	o := collectOptions(opts)
+	l, err := parseRules(r, o)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range l.set.Rules {
+		f, err := compileAst(l.source, rule.Body, l.set.Symbols, o)
+		if err != nil {
+			l.set.Free()
+			return nil, l.errorf(rule.Body.Pos, "rule %s: %v", rule.Name, err)
+		}
+		rule.Function = f
+	}
+	return l.set, nil
+}
+
+// Parses a rule file like LoadRules, without compiling the rules
+//
+// Declarations and constants are checked, while rule bodies are only parsed,
+// so that tools may inspect them. Options are used to type-check constants.
+func ParseRules(r io.Reader, opts ...Option) (*RuleSet, error) {
+	l, err := parseRules(r, collectOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	return l.set, nil
+}
+
+func parseRules(r io.Reader, o options) (*ruleLoader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	l := &ruleLoader{
+		source:    string(data),
+		constants: make(map[string]gruelparser.GruelAstNode),
+		names:     make(map[string]bool),
+		set:       &RuleSet{Symbols: make(map[string]byte), Source: string(data)},
+		o:         o,
+	}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Compiles the rules of a rule file, see LoadRules
+func LoadFile(path string, opts ...Option) (*RuleSet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	set, err := LoadRules(file, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", path, err)
+	}
+	return set, nil
+}
+
+// Returns the rule with the name, or nil if not found
+func (s *RuleSet) Rule(name string) *Rule {
+	for _, rule := range s.Rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Frees the functions of all rules
+func (s *RuleSet) Free() {
+	for _, rule := range s.Rules {
+		if rule.Function != nil {
+			rule.Function.Free()
+		}
+	}
+}
+
+type ruleLoader struct {
+	source    string
+	constants map[string]gruelparser.GruelAstNode
+	// The names of the rules so far
+	names map[string]bool
+	set   *RuleSet
+	o     options
+}
+
+// An error at a position of the file
+func (l *ruleLoader) errorf(pos int, format string, args ...any) error {
+	return &gruelparser.SyntaxError{
+		Pos:    pos,
+		Line:   strings.Count(l.source[:pos], "\n") + 1,
+		Column: pos - strings.LastIndexByte(l.source[:pos], '\n'),
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+func (l *ruleLoader) load() error {
+	forms, err := gruelparser.ParseAll(l.source)
+	var syntaxErr *gruelparser.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		// Already positioned
+		return err
+	} else if err != nil {
+		pos := forms[len(forms)-1].Pos
+		if err == io.ErrUnexpectedEOF {
+			pos = len(strings.TrimRight(l.source, " \t\r\n"))
+		}
+		return l.errorf(pos, "%v", err)
+	}
+	// Declarations come first so that rules may precede them.
+	for i := range forms {
+		if forms[i].Type == gruelparser.TypeParenthesis && forms[i].Value == "declare" {
+			if err := l.declare(&forms[i]); err != nil {
+				return err
+			}
+		}
+	}
+	for i := range forms {
+		form := &forms[i]
+		if form.Type != gruelparser.TypeParenthesis {
+			return l.errorf(form.Pos, "expecting declare, const or rule forms")
+		}
+		switch form.Value {
+		case "declare":
+		case "const":
+			err = l.constant(form)
+		case "rule":
+			err = l.rule(form)
+		default:
+			err = l.errorf(form.Pos, "unknown form %s", form.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Declares variables like (declare (amount float) (user.age int))
+func (l *ruleLoader) declare(form *gruelparser.GruelAstNode) error {
+	for _, param := range form.Parameters {
+		if param.Type != gruelparser.TypeParenthesis || len(param.Parameters) != 1 ||
+			param.Parameters[0].Type != gruelparser.TypeSymbol {
+			return l.errorf(param.Pos, "expecting declarations like (name type)")
+		}
+		t, err := ParseType(param.Parameters[0].Value)
+		if err != nil {
+			return l.errorf(param.Parameters[0].Pos, "%v", err)
+		}
+		if _, ok := l.set.Symbols[param.Value]; ok {
+			return l.errorf(param.Pos, "%s is already declared", param.Value)
+		}
+		l.set.Symbols[param.Value] = t
+	}
+	return nil
+}
+
+// Defines a constant like (const limit 1000.0)
+func (l *ruleLoader) constant(form *gruelparser.GruelAstNode) error {
+	if len(form.Parameters) != 2 || form.Parameters[0].Type != gruelparser.TypeSymbol {
+		return l.errorf(form.Pos, "expecting (const name value)")
+	}
+	name := form.Parameters[0].Value
+	_, declared := l.set.Symbols[name]
+	_, defined := l.constants[name]
+	if declared || defined {
+		return l.errorf(form.Parameters[0].Pos, "%s is already declared", name)
+	}
+	value := l.inline(form.Parameters[1])
+	// Symbols other than constants fail here.
+	if _, err := ir.Compile(&value, nil, l.o.ir); err != nil {
+		return l.errorf(form.Parameters[1].Pos, "constant %s: %v", name, err)
+	}
+	l.constants[name] = value
+	return nil
+}
+
+// Reads a rule like (rule name :priority 10 :tags (a b) body)
+func (l *ruleLoader) rule(form *gruelparser.GruelAstNode) error {
+	params := form.Parameters
+	if len(params) < 2 || (params[0].Type != gruelparser.TypeSymbol && params[0].Type != gruelparser.TypeString) {
+		return l.errorf(form.Pos, "expecting (rule name [:priority n] [:tags (tag ...)] body)")
+	}
+	rule := &Rule{Name: params[0].Value}
+	if l.names[rule.Name] {
+		return l.errorf(params[0].Pos, "rule %s is already defined", rule.Name)
+	}
+	params = params[1:]
+	for len(params) > 1 && params[0].Type == gruelparser.TypeSymbol && strings.HasPrefix(params[0].Value, ":") {
+		key, value := &params[0], &params[1]
+		params = params[2:]
+		switch key.Value {
+		case ":priority":
+			priority, err := strconv.ParseInt(value.Value, 0, 64)
+			if value.Type != gruelparser.TypeInt || err != nil {
+				return l.errorf(value.Pos, "expecting an integer priority")
+			}
+			rule.Priority = priority
+		case ":tags":
+			tags, err := l.tags(value)
+			if err != nil {
+				return err
+			}
+			rule.Tags = tags
+		default:
+			return l.errorf(key.Pos, "unknown rule attribute %s", key.Value)
+		}
+	}
+	if len(params) != 1 {
+		return l.errorf(form.Pos, "rule %s expects a single body", rule.Name)
+	}
+
+	rule.Body = l.inline(params[0])
+	l.names[rule.Name] = true
+	l.set.Rules = append(l.set.Rules, rule)
+	return nil
+}
+
+// Reads tags written as a single word or a list like (fraud "card payments")
+func (l *ruleLoader) tags(node *gruelparser.GruelAstNode) ([]string, error) {
+	var tags []string
+	nodes := []gruelparser.GruelAstNode{*node}
+	if node.Type == gruelparser.TypeParenthesis {
+		tags, nodes = []string{node.Value}, node.Parameters
+	}
+	for _, tag := range nodes {
+		if tag.Type != gruelparser.TypeSymbol && tag.Type != gruelparser.TypeString {
+			return nil, l.errorf(tag.Pos, "expecting tags like (tag ...)")
+		}
+		tags = append(tags, tag.Value)
+	}
+	return tags, nil
+}
+
+// Substitutes constants, keeping the positions of their names
+// so that errors and explanations point at the uses
+func (l *ruleLoader) inline(node gruelparser.GruelAstNode) gruelparser.GruelAstNode {
+	switch {
+	case node.Type == gruelparser.TypeSymbol:
+		if value, ok := l.constants[node.Value]; ok {
+			return relocate(value, node.Pos, node.End)
+		}
+	case node.Type == gruelparser.TypeParenthesis && node.Value != "get":
+		params := make([]gruelparser.GruelAstNode, len(node.Parameters))
+		for i := range node.Parameters {
+			params[i] = l.inline(node.Parameters[i])
+		}
+		node.Parameters = params
+	}
+	return node
+}
+
+func relocate(node gruelparser.GruelAstNode, pos, end int) gruelparser.GruelAstNode {
+	node.Pos, node.End = pos, end
+	if node.Parameters != nil {
+		params := make([]gruelparser.GruelAstNode, len(node.Parameters))
+		for i := range node.Parameters {
+			params[i] = relocate(node.Parameters[i], pos, end)
+		}
+		node.Parameters = params
+	}
+	return node
+}
@@ -0,0 +1,156 @@
+package grueljit_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/gruelast"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+const ruleFile = `; Payment rules
+(declare (amount float) (country string) (user.age int))
+
+(const limit 1000.0)
+(const double-limit (* limit 2.0))
+
+(rule large-payment :priority 10 :tags (fraud "card payments")
+  (&& (> amount limit) (!= country "NZ")))
+
+#| Tags may be single words. |#
+(rule "huge payment" :tags review
+  (> amount double-limit))
+
+(rule minor (< (get user "age") 18))
+
+; Rules may come before declarations.
+(rule per-user (/ 100 ratio))
+(declare (ratio int))
+`
+
+func TestLoadRules(t *testing.T) {
+	set, err := grueljit.LoadRules(strings.NewReader(ruleFile))
+	assert.Nil(t, err)
+	defer set.Free()
+	assert.Equal(t, map[string]byte{
+		"amount":   grueljit.TypeFloat,
+		"country":  grueljit.TypeString,
+		"user.age": grueljit.TypeInt,
+		"ratio":    grueljit.TypeInt,
+	}, set.Symbols)
+
+	var names []string
+	for _, rule := range set.Rules {
+		names = append(names, rule.Name)
+	}
+	assert.Equal(t, []string{"large-payment", "huge payment", "minor", "per-user"}, names)
+	large := set.Rule("large-payment")
+	assert.Equal(t, int64(10), large.Priority)
+	assert.Equal(t, []string{"fraud", "card payments"}, large.Tags)
+	assert.Equal(t, []string{"review"}, set.Rule("huge payment").Tags)
+	assert.Equal(t, int64(0), set.Rule("minor").Priority)
+	assert.Nil(t, set.Rule("missing"))
+
+	args := map[string]any{"amount": 1500.0, "country": "AU"}
+	v, err := large.Function.Call(args)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), v)
+	v, err = set.Rule("huge payment").Function.Call(args)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), v)
+	v, err = set.Rule("minor").Function.Call(map[string]any{"user": map[string]any{"age": 17}})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), v)
+
+	// Runtime errors point into the file.
+	_, err = set.Rule("per-user").Function.Call(map[string]any{"ratio": 0})
+	assert.EqualError(t, err, "runtime error at 17:16: division by zero in /")
+}
+
+func TestLoadRulesErrors(t *testing.T) {
+	for source, msg := range map[string]string{
+		"(rule a 1)\n(rule a 2)":               "2:7: rule a is already defined",
+		"(rule a :priority x 1)":               "1:19: expecting an integer priority",
+		"(rule a :owner x 1)":                  "1:9: unknown rule attribute :owner",
+		"(rule a :tags (x 1) 1)":               "1:18: expecting tags like (tag ...)",
+		"(rule a)":                             "1:1: expecting (rule name [:priority n] [:tags (tag ...)] body)",
+		"(rule a 1 2)":                         "1:1: rule a expects a single body",
+		"(rule a (+ x 1))":                     "1:9: rule a: symbol x not found",
+		"(declare (x int) (x float))":          "1:18: x is already declared",
+		"(declare (x integer))":                "1:13: unknown type integer",
+		"(declare x)":                          "1:10: expecting declarations like (name type)",
+		"(declare (x int))\n(const x 1)":       "2:8: x is already declared",
+		"(declare (x int))\n(const y (+ x 1))": "2:10: constant y: symbol x not found",
+		"(const y 1 2)":                        "1:1: expecting (const name value)",
+		"1":                                    "1:1: expecting declare, const or rule forms",
+		"(define x 1)":                         "1:1: unknown form define",
+		"(rule a\n  (+ 1 2)":                   "2:10: unexpected EOF",
+	} {
+		_, err := grueljit.LoadRules(strings.NewReader(source))
+		assert.EqualError(t, err, msg, source)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.gruel")
+	assert.Nil(t, os.WriteFile(path, []byte(ruleFile), 0o644))
+	set, err := grueljit.LoadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(set.Rules))
+	set.Free()
+
+	assert.Nil(t, os.WriteFile(path, []byte("(rule a b)"), 0o644))
+	_, err = grueljit.LoadFile(path)
+	assert.EqualError(t, err, path+":1:9: rule a: symbol b not found")
+}
+
+func TestLoadRulesCoverage(t *testing.T) {
+	set, err := grueljit.LoadRules(strings.NewReader(
+		"(const positive (> 1 0))\n(declare (x int))\n(rule a (&& positive (> x 1)))"), grueljit.WithCoverage())
+	assert.Nil(t, err)
+	defer set.Free()
+	f := set.Rules[0].Function
+	_, err = f.Call(map[string]any{"x": 2})
+	assert.Nil(t, err)
+	// Inlined constants are reported by their names.
+	assert.Equal(t, []string{"(&& positive (> x 1))", "positive", "(> x 1)"}, conditionTexts(f))
+	sb := strings.Builder{}
+	assert.Nil(t, f.Coverage().WriteHTML(&sb))
+	assert.Contains(t, sb.String(), `<span class="partial" title="true 1, false 0">positive</span>`)
+}
+
+func conditionTexts(f *grueljit.Function) []string {
+	var texts []string
+	for _, cond := range f.Coverage().Conditions() {
+		texts = append(texts, cond.Text)
+	}
+	return texts
+}
+
+func TestParseRules(t *testing.T) {
+	set, err := grueljit.ParseRules(strings.NewReader(ruleFile))
+	assert.Nil(t, err)
+	defer set.Free()
+	assert.Equal(t, 4, len(set.Rules))
+	assert.Equal(t, ruleFile, set.Source)
+	large := set.Rule("large-payment")
+	assert.Nil(t, large.Function)
+	// Constants are inlined, keeping the positions of their uses.
+	assert.Equal(t, `(&& (> amount 1000.0) (!= country "NZ"))`, large.Body.String())
+	assert.Equal(t, "limit", set.Source[large.Body.Parameters[0].Parameters[1].Pos:large.Body.Parameters[0].Parameters[1].End])
+
+	// Rule bodies are not compiled.
+	set, err = grueljit.ParseRules(strings.NewReader("(rule a (+ x 1))"))
+	assert.Nil(t, err)
+	assert.Equal(t, "(+ x 1)", set.Rules[0].Body.String())
+
+	_, err = grueljit.ParseRules(strings.NewReader("(declare (x int))\n(const y (+ x 1))"))
+	var syntaxErr *gruelast.SyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, 2, syntaxErr.Line)
+	assert.Equal(t, 10, syntaxErr.Column)
+	assert.Equal(t, "constant y: symbol x not found", syntaxErr.Msg)
+}
@@ -0,0 +1,100 @@
+package grueljit
+
+import (
+	"fmt"
+
+	"github.com/yesh0/gruel/internal/ir"
+)
+
+// Compiles a new function with some parameters bound to constants
+//
+// Bindings are converted like the arguments of Call, rejecting values out of
+// the range of sized parameters, and a record binds all of its fields.
+// The new function takes the remaining parameters and is compiled with the
+// options of the function, so that a rule template like `(> amount threshold)`
+// may be instantiated per tenant. The function itself is left untouched.
+//
+// Bound parameters become constants in place of the parameter loads,
+// and built-in operators on constants only are evaluated once here, so that
+// `(== region "eu")` with region bound compiles to a constant. Operators
+// failing their runtime checks, like divisions by zero, are kept and fail
+// at every call instead.
+func (f *Function) Specialize(bindings map[string]any) (*Function, error) {
+	if !f.acquire() {
+		return nil, ErrFreed
+	}
+	defer f.release()
+
+	values := make(map[string]ir.Binding)
+	used := make(map[string]bool, len(bindings))
+	for name, index := range f.arg_map {
+		value, ok := f.lookup(bindings, name, index)
+		if !ok {
+			continue
+		}
+		if _, ok := bindings[name]; ok {
+			used[name] = true
+		} else {
+			used[f.paths[index][0]] = true
+		}
+		target := f.arg_types[index]
+		if v, ok := value.(string); ok && target == TypeString {
+			values[name] = ir.Binding{String: v}
+			continue
+		}
+		converted, err := convertArg(value, target)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", name, err)
+		}
+		values[name] = ir.Binding{Value: converted}
+	}
+	for name := range bindings {
+		if !used[name] {
+			return nil, fmt.Errorf("parameter %s not found", name)
+		}
+	}
+
+	builder, err := f.program.Bind(values)
+	if err != nil {
+		return nil, err
+	}
+	builder = builder.Fold(f.evaluate)
+	g, err := newFunction(builder, "", f.options)
+	if err != nil {
+		return nil, err
+	}
+	// The check sites are unchanged.
+	g.errors = f.errors
+	if e := f.explain; e != nil {
+		merged := make(map[string]any, len(e.bindings)+len(bindings))
+		for name, value := range e.bindings {
+			merged[name] = value
+		}
+		for name, value := range bindings {
+			merged[name] = value
+		}
+		g.explain = &explainer{source: e.source, ast: e.ast, symbols: e.symbols,
+			options: e.options, bindings: merged}
+		if f.coverage != nil {
+			// Sub-expressions take all parameters, with the bound ones filled in.
+			g.coverage, err = newCoverage(g, e.source, e.ast, e.symbols, f.options)
+			if err != nil {
+				g.Free()
+				return nil, err
+			}
+			g.coverage.bindings = merged
+		}
+	}
+	return g, nil
+}
+
+// Runs a program without parameters, compiled like the function
+func (f *Function) evaluate(program *ir.IrBuilder) (uint64, bool) {
+	g, err := newFunction(program, "", f.options)
+	if err != nil {
+		return 0, false
+	}
+	defer g.Free()
+	result, err := g.CallRaw(make([]uint64, g.scratch))
+	return result, err == nil
+}
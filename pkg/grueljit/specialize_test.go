@@ -0,0 +1,179 @@
+package grueljit_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+func TestSpecialize(t *testing.T) {
+	f, err := grueljit.Compile("(> amount threshold)", map[string]byte{
+		"amount":    grueljit.TypeInt,
+		"threshold": grueljit.TypeInt,
+	})
+	assert.Nil(t, err)
+	g, err := f.Specialize(map[string]any{"threshold": 100})
+	assert.Nil(t, err)
+	v, err := g.Call(map[string]any{"amount": 101})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), v)
+	v, err = g.Call(map[string]any{"amount": 100})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), v)
+	_, err = g.Call(map[string]any{"threshold": 1})
+	assert.EqualError(t, err, "parameter amount not found")
+
+	// The original function is left untouched.
+	v, err = f.Call(map[string]any{"amount": 1, "threshold": 0})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), v)
+
+	_, err = f.Specialize(map[string]any{"limit": 1})
+	assert.EqualError(t, err, "parameter limit not found")
+	_, err = f.Specialize(map[string]any{"threshold": "1"})
+	assert.EqualError(t, err, "parameter threshold: unsupported conversion from string")
+	g.Free()
+	f.Free()
+	_, err = f.Specialize(map[string]any{"threshold": 1})
+	assert.Equal(t, grueljit.ErrFreed, err)
+}
+
+func TestSpecializeSized(t *testing.T) {
+	requireLibJit(t)
+	f, err := grueljit.Compile("(+ a b)", map[string]byte{
+		"a": grueljit.TypeInt8,
+		"b": grueljit.TypeInt8,
+	})
+	assert.Nil(t, err)
+	_, err = f.Specialize(map[string]any{"a": 300})
+	assert.EqualError(t, err, "parameter a: 300 overflows int8")
+	g, err := f.Specialize(map[string]any{"a": -100})
+	assert.Nil(t, err)
+	v, err := g.Call(map[string]any{"b": 100})
+	assert.Nil(t, err)
+	assert.Equal(t, int8(0), v)
+	f.Free()
+	g.Free()
+}
+
+func TestSpecializeFloats(t *testing.T) {
+	f, err := grueljit.Compile("(* x y z)", map[string]byte{
+		"x": grueljit.TypeFloat,
+		"y": grueljit.TypeFloat,
+		"z": grueljit.TypeFloat,
+	})
+	assert.Nil(t, err)
+	g, err := f.Specialize(map[string]any{"y": 2})
+	assert.Nil(t, err)
+	h, err := g.Specialize(map[string]any{"z": 0.5})
+	assert.Nil(t, err)
+	v, err := h.Call(map[string]any{"x": 3})
+	assert.Nil(t, err)
+	assert.Equal(t, 3.0, v)
+	f.Free()
+	g.Free()
+	h.Free()
+}
+
+func TestSpecializeRecords(t *testing.T) {
+	f, err := grueljit.Compile("(- (* user.age 2) (/ offset divisor))", map[string]byte{
+		"user.age": grueljit.TypeInt,
+		"offset":   grueljit.TypeInt,
+		"divisor":  grueljit.TypeInt,
+	})
+	assert.Nil(t, err)
+	g, err := f.Specialize(map[string]any{"user": map[string]any{"age": 20}, "divisor": 0})
+	assert.Nil(t, err)
+	_, err = g.Call(map[string]any{"offset": 1})
+	assert.EqualError(t, err, "runtime error at 1:19: division by zero in /")
+	f.Free()
+	g.Free()
+}
+
+func TestSpecializeStrings(t *testing.T) {
+	requireLibJit(t)
+	f, err := grueljit.Compile(`(== (concat prefix name) "ab")`, map[string]byte{
+		"prefix": grueljit.TypeString,
+		"name":   grueljit.TypeString,
+	})
+	assert.Nil(t, err)
+	g, err := f.Specialize(map[string]any{"prefix": "a"})
+	assert.Nil(t, err)
+	v, err := g.Call(map[string]any{"name": "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), v)
+	v, err = g.Call(map[string]any{"name": "c"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), v)
+	f.Free()
+	g.Free()
+}
+
+func TestSpecializeOptions(t *testing.T) {
+	f, err := grueljit.Compile("(&& (> amount threshold) flagged)", map[string]byte{
+		"amount":    grueljit.TypeInt,
+		"threshold": grueljit.TypeInt,
+		"flagged":   grueljit.TypeBool,
+	}, grueljit.WithCoverage(), grueljit.WithNativeBackend())
+	assert.Nil(t, err)
+	defer f.Free()
+	g, err := f.Specialize(map[string]any{"threshold": 100})
+	assert.Nil(t, err)
+	defer g.Free()
+	for _, amount := range []int{50, 150, 200} {
+		_, err := g.Call(map[string]any{"amount": amount, "flagged": true})
+		assert.Nil(t, err)
+	}
+	// Counters are kept per function.
+	assert.Equal(t, []grueljit.Condition{
+		{Text: "(&& (> amount threshold) flagged)", Pos: 0, End: 33, True: 2, False: 1},
+		{Text: "(> amount threshold)", Pos: 4, End: 24, True: 2, False: 1},
+		{Text: "flagged", Pos: 25, End: 32, True: 3},
+	}, g.Coverage().Conditions())
+	assert.Empty(t, f.Coverage().Conditions()[0].True)
+}
+
+func TestSpecializeCoverageBindings(t *testing.T) {
+	f, err := grueljit.Compile("(&& (> amount threshold) (> amount 0))", map[string]byte{
+		"amount":    grueljit.TypeInt,
+		"threshold": grueljit.TypeInt,
+	}, grueljit.WithCoverage(), grueljit.WithNativeBackend())
+	assert.Nil(t, err)
+	defer f.Free()
+	g, err := f.Specialize(map[string]any{"threshold": 100})
+	assert.Nil(t, err)
+	defer g.Free()
+	// The bound threshold is used, whatever the arguments say.
+	v, err := g.Call(map[string]any{"amount": 50, "threshold": 0})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), v)
+	conditions := g.Coverage().Conditions()
+	assert.Equal(t, "(> amount threshold)", conditions[1].Text)
+	assert.Equal(t, [2]uint64{0, 1}, [2]uint64{conditions[1].True, conditions[1].False})
+}
+
+func TestSpecializeFolding(t *testing.T) {
+	f, err := grueljit.Compile("(&& (> amount (* (+ threshold 1) 2)) (> (/ amount (- threshold 5)) 0))",
+		map[string]byte{
+			"amount":    grueljit.TypeInt,
+			"threshold": grueljit.TypeInt,
+		}, grueljit.WithNativeBackend())
+	assert.Nil(t, err)
+	defer f.Free()
+	g, err := f.Specialize(map[string]any{"threshold": 99})
+	assert.Nil(t, err)
+	defer g.Free()
+	for amount, expected := range map[int]uint64{200: 0, 201: 1} {
+		v, err := g.Call(map[string]any{"amount": amount})
+		assert.Nil(t, err)
+		assert.Equal(t, expected, v, amount)
+	}
+
+	// Failing sub-expressions are kept to fail at every call.
+	g, err = f.Specialize(map[string]any{"threshold": 5})
+	assert.Nil(t, err)
+	defer g.Free()
+	_, err = g.Call(map[string]any{"amount": 100})
+	assert.EqualError(t, err, "runtime error at 1:41: division by zero in /")
+}
@@ -0,0 +1,105 @@
+package grueljit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yesh0/gruel/pkg/grueljit"
+)
+
+func TestTimeArithmetic(t *testing.T) {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := created.Add(90 * time.Minute)
+	symbols := map[string]byte{
+		"created": grueljit.TypeTime,
+		"now":     grueljit.TypeTime,
+		"timeout": grueljit.TypeDuration,
+	}
+	args := map[string]any{"created": created, "now": now, "timeout": 5 * time.Minute}
+
+	f, err := grueljit.Compile("(- now created)", symbols)
+	assert.Nil(t, err)
+	assert.Equal(t, grueljit.TypeDuration, f.ResultType())
+	v, err := f.Call(args)
+	assert.Nil(t, err)
+	assert.Equal(t, 90*time.Minute, v)
+	f.Free()
+
+	f, err = grueljit.Compile("(+ created timeout 1h)", symbols)
+	assert.Nil(t, err)
+	assert.Equal(t, grueljit.TypeTime, f.ResultType())
+	v, err = f.Call(args)
+	assert.Nil(t, err)
+	assert.True(t, created.Add(65*time.Minute).Equal(v.(time.Time)))
+	f.Free()
+
+	f, err = grueljit.Compile("(> (- now created) 1h)", symbols)
+	assert.Nil(t, err)
+	v, err = f.Call(args)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), v)
+	f.Free()
+
+	f, err = grueljit.Compile("(< now #t\"2026-01-01T01:00:00Z\")", symbols)
+	assert.Nil(t, err)
+	v, err = f.Call(args)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), v)
+	_, err = f.Call(map[string]any{"now": 1})
+	assert.NotNil(t, err)
+	f.Free()
+
+	for _, expr := range []string{
+		"(+ now created)", "(< now timeout)", "(+ now 1)", "(* timeout 1.5)", "(sqrt now)",
+	} {
+		_, err = grueljit.Compile(expr, symbols)
+		assert.NotNil(t, err, expr)
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	requireLibJit(t)
+	paris, err := time.LoadLocation("Europe/Paris")
+	assert.Nil(t, err)
+	symbols := map[string]byte{"t": grueljit.TypeTime}
+	times := []time.Time{
+		time.Date(2026, 1, 1, 0, 30, 0, 0, time.UTC),
+		time.Date(2026, 7, 15, 23, 59, 0, 0, time.UTC),
+		time.Date(1969, 12, 31, 23, 0, 0, 0, time.UTC),
+		time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC),
+	}
+	for _, loc := range []*time.Location{time.UTC, paris} {
+		for op, expected := range map[string]func(time.Time) int{
+			"hour":         time.Time.Hour,
+			"minute":       time.Time.Minute,
+			"weekday":      func(t time.Time) int { return int(t.Weekday()) },
+			"day-of-month": time.Time.Day,
+			"month":        func(t time.Time) int { return int(t.Month()) },
+			"year":         time.Time.Year,
+		} {
+			f, err := grueljit.Compile("("+op+" t)", symbols, grueljit.WithLocation(loc))
+			assert.Nil(t, err)
+			for _, v := range times {
+				result, err := f.Call(map[string]any{"t": v})
+				assert.Nil(t, err)
+				assert.Equal(t, uint64(expected(v.In(loc))), result, "%s %v", op, v)
+			}
+			f.Free()
+		}
+	}
+
+	// Business hours in Paris
+	f, err := grueljit.Compile(
+		"(&& (>= (hour t) 9) (< (hour t) 18) (> (weekday t) 0) (< (weekday t) 6))",
+		symbols, grueljit.WithLocation(paris),
+	)
+	assert.Nil(t, err)
+	v, err := f.Call(map[string]any{"t": time.Date(2026, 10, 19, 14, 30, 0, 0, time.UTC)})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), v)
+	v, err = f.Call(map[string]any{"t": time.Date(2026, 10, 19, 16, 30, 0, 0, paris).Add(2 * time.Hour)})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), v)
+	f.Free()
+}
@@ -0,0 +1,182 @@
+// This package supports Go code generated from rules by `gruel gen-go`.
+//
+// Helpers here mirror what the JIT does for operators without
+// a direct Go counterpart, so that generated functions give the same results.
+package gruelrt
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Kinds of runtime faults, numbered like grueljit.Fault
+type Fault byte
+
+const (
+	// Integer (or decimal) division by zero
+	FaultDivisionByZero Fault = iota + 1
+	// Negative shift counts or counts not less than the width of the value
+	FaultShift
+	// NaN results, only reported for rules generated with strict NaN checks
+	FaultNaN
+	// Decimal results out of range, numbered after host function errors,
+	// which generated code never reports
+	FaultOverflow Fault = iota + 2
+)
+
+// Implements fmt.Stringer
+func (f Fault) String() string {
+	switch f {
+	case FaultDivisionByZero:
+		return "division by zero"
+	case FaultShift:
+		return "invalid shift amount"
+	case FaultNaN:
+		return "NaN result"
+	case FaultOverflow:
+		return "decimal overflow"
+	default:
+		return fmt.Sprintf("fault %d", byte(f))
+	}
+}
+
+// An error raised by generated code, formatted like grueljit.RuntimeError
+type RuntimeError struct {
+	Fault Fault
+	// The operator that failed, like "/"
+	Operator string
+	// The position of the operator in the source, starting from 1
+	Line   int
+	Column int
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("runtime error at %d:%d: %s in %s", e.Line, e.Column, e.Fault, e.Operator)
+}
+
+// Numeric types that rules compute with
+type Number interface {
+	~int8 | ~int16 | ~int32 | ~int64 | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~float32 | ~float64
+}
+
+// Converts a boolean into 1 or 0
+func Int(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Compares two numbers into -1, 0 or 1, with NaN being less than anything
+func Cmpl[T Number](a, b T) int64 {
+	switch {
+	case a > b:
+		return 1
+	case a == b:
+		return 0
+	default:
+		return -1
+	}
+}
+
+// Compares two numbers into -1, 0 or 1, with NaN being greater than anything
+func Cmpg[T Number](a, b T) int64 {
+	switch {
+	case a < b:
+		return -1
+	case a == b:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// Returns the smaller number, or NaN if either one is NaN
+func Min[T Number](a, b T) T {
+	switch {
+	case a != a:
+		return a
+	case b != b || b < a:
+		return b
+	default:
+		return a
+	}
+}
+
+// Returns the greater number, or NaN if either one is NaN
+func Max[T Number](a, b T) T {
+	switch {
+	case a != a:
+		return a
+	case b != b || b > a:
+		return b
+	default:
+		return a
+	}
+}
+
+// Returns the absolute value of an integer, wrapping around for the minimum
+func Abs[T Number](a T) T {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// Returns -1, 0 or 1 by the sign, and 0 for NaN
+func Sign[T Number](a T) int64 {
+	switch {
+	case a > 0:
+		return 1
+	case a < 0:
+		return -1
+	default:
+		return 0
+	}
+}
+
+var locations sync.Map
+
+// Loads a time zone by name once, panicking if not found
+func Location(name string) *time.Location {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	locations.Store(name, loc)
+	return loc
+}
+
+// Converts Unix nanoseconds into a time in the zone
+func Time(nanos int64, loc *time.Location) time.Time {
+	return time.Unix(0, nanos).In(loc)
+}
+
+func Hour(nanos int64, loc *time.Location) int64 {
+	return int64(Time(nanos, loc).Hour())
+}
+
+func Minute(nanos int64, loc *time.Location) int64 {
+	return int64(Time(nanos, loc).Minute())
+}
+
+// Sunday being 0, like time.Weekday
+func Weekday(nanos int64, loc *time.Location) int64 {
+	return int64(Time(nanos, loc).Weekday())
+}
+
+func DayOfMonth(nanos int64, loc *time.Location) int64 {
+	return int64(Time(nanos, loc).Day())
+}
+
+func Month(nanos int64, loc *time.Location) int64 {
+	return int64(Time(nanos, loc).Month())
+}
+
+func Year(nanos int64, loc *time.Location) int64 {
+	return int64(Time(nanos, loc).Year())
+}